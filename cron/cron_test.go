@@ -0,0 +1,75 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2018, time.January, 10, 10, 30, 15, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		expr     string
+		expected time.Time
+	}{
+		{"EveryMinute", "* * * * *", time.Date(2018, time.January, 10, 10, 31, 0, 0, time.UTC)},
+		{"Daily", "0 6 * * *", time.Date(2018, time.January, 11, 6, 0, 0, 0, time.UTC)},
+		{"LaterToday", "45 10 * * *", time.Date(2018, time.January, 10, 10, 45, 0, 0, time.UTC)},
+		{"Step", "*/20 * * * *", time.Date(2018, time.January, 10, 10, 40, 0, 0, time.UTC)},
+		{"ValueStep", "5/30 * * * *", time.Date(2018, time.January, 10, 10, 35, 0, 0, time.UTC)},
+		{"RangeAndList", "0 9-11,14 * * *", time.Date(2018, time.January, 10, 11, 0, 0, 0, time.UTC)},
+		{"Weekdays", "0 2 * * mon-fri", time.Date(2018, time.January, 11, 2, 0, 0, 0, time.UTC)},
+		{"SundayAsSeven", "0 2 * * 7", time.Date(2018, time.January, 14, 2, 0, 0, 0, time.UTC)},
+		{"MonthName", "0 0 1 MAR *", time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{"DayOfMonthOrWeek", "0 0 20 * sat", time.Date(2018, time.January, 13, 0, 0, 0, 0, time.UTC)},
+		{"LeapDay", "0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"Never", "0 0 30 2 *", time.Time{}},
+		{"Hourly", "@hourly", time.Date(2018, time.January, 10, 11, 0, 0, 0, time.UTC)},
+		{"Weekly", "@weekly", time.Date(2018, time.January, 14, 0, 0, 0, 0, time.UTC)},
+		{"Yearly", "@yearly", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, d := range testCases {
+		schedule, err := Parse(d.expr)
+		if !assert.NoError(t, err, "Test %s failed, unexpected error", d.name) {
+			continue
+		}
+		assert.Equal(t, d.expected, schedule.Next(from), "Test %s failed, wrong next time", d.name)
+		assert.Equal(t, d.expr, schedule.String())
+	}
+}
+
+func TestScheduleNextAcrossDaylightSaving(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+	schedule, err := Parse("30 1 * * *")
+	assert.NoError(t, err)
+	// 01:30 does not exist on the day the clocks go forward, so it fires the next day
+	from := time.Date(2018, time.March, 24, 12, 0, 0, 0, london)
+	assert.Equal(t, time.Date(2018, time.March, 26, 1, 30, 0, 0, london), schedule.Next(from))
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expr     string
+		expected string
+	}{
+		{"TooFewFields", "0 6 * *", `cron expression "0 6 * *" should have 5 fields: minute hour day-of-month month day-of-week`},
+		{"OutOfRange", "60 * * * *", "minute: 60 is out of range 0-59"},
+		{"NotANumber", "0 six * * *", `hour: "six" is not a number`},
+		{"Backwards", "0 0 * * fri-mon", `day of week: range "fri-mon" runs backwards`},
+		{"BadStep", "*/0 * * * *", `minute: step in "*/0" is not a positive number`},
+		{"UnknownMacro", "@fortnightly", `cron expression "@fortnightly" should have 5 fields: minute hour day-of-month month day-of-week`},
+	}
+	for _, d := range testCases {
+		_, err := Parse(d.expr)
+		if assert.Error(t, err, "Test %s failed, expected an error", d.name) {
+			assert.Equal(t, d.expected, err.Error(), "Test %s failed, wrong error", d.name)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaDiff - the differences between the tables of two schemas
+type SchemaDiff struct {
+	AddedTables   []Table
+	RemovedTables []Table
+	ChangedTables []TableDiff
+}
+
+// TableDiff - the differences between two definitions of a table
+type TableDiff struct {
+	// Table is the new definition of the table
+	Table          Table
+	AddedColumns   []Column
+	RemovedColumns []Column
+	ChangedColumns []ColumnChange
+	// Reordered is set when the columns kept are in a different order, or columns are added before them.
+	// Data files are loaded by position so the columns of the table must be in the order of the new schema.
+	Reordered         bool
+	PrimaryKeyChanged bool
+	AddedIndexes      []Index
+	RemovedIndexes    []Index
+}
+
+// ColumnChange - a column whose type, nullability or default has changed
+type ColumnChange struct {
+	Old Column
+	New Column
+}
+
+// Diff - compares the tables of an old schema with those of a new one. Tables and columns are matched
+// by name ignoring case, and indexes by their name and columns.
+func Diff(old []Table, new []Table) SchemaDiff {
+	var diff SchemaDiff
+	for _, newTable := range new {
+		oldTable, ok := findTable(old, newTable.Name)
+		if !ok {
+			diff.AddedTables = append(diff.AddedTables, newTable)
+			continue
+		}
+		if tableDiff := diffTable(oldTable, newTable); !tableDiff.IsEmpty() {
+			diff.ChangedTables = append(diff.ChangedTables, tableDiff)
+		}
+	}
+	for _, oldTable := range old {
+		if _, ok := findTable(new, oldTable.Name); !ok {
+			diff.RemovedTables = append(diff.RemovedTables, oldTable)
+		}
+	}
+	return diff
+}
+
+// IsEmpty - whether the schemas define the same tables
+func (d SchemaDiff) IsEmpty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+// IsDestructive - whether the changes can only be made by rebuilding tables, losing their data
+func (d SchemaDiff) IsDestructive() bool {
+	if len(d.RemovedTables) > 0 {
+		return true
+	}
+	for _, table := range d.ChangedTables {
+		if table.IsDestructive() {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes - a description of each change, for logging
+func (d SchemaDiff) Changes() []string {
+	var changes []string
+	for _, table := range d.AddedTables {
+		changes = append(changes, fmt.Sprintf("table %s added", table.Name))
+	}
+	for _, table := range d.RemovedTables {
+		changes = append(changes, fmt.Sprintf("table %s removed", table.Name))
+	}
+	for _, table := range d.ChangedTables {
+		changes = append(changes, table.Changes()...)
+	}
+	return changes
+}
+
+func (d SchemaDiff) String() string {
+	if d.IsEmpty() {
+		return "no changes"
+	}
+	return strings.Join(d.Changes(), ", ")
+}
+
+// IsEmpty - whether the definitions of the table are the same
+func (d TableDiff) IsEmpty() bool {
+	return len(d.AddedColumns) == 0 && len(d.RemovedColumns) == 0 && len(d.ChangedColumns) == 0 &&
+		!d.Reordered && !d.PrimaryKeyChanged && len(d.AddedIndexes) == 0 && len(d.RemovedIndexes) == 0
+}
+
+// IsDestructive - whether the table must be rebuilt to change it. Only columns that can be appended
+// to a table holding rows, i.e. that are nullable or have a default, and new indexes can be added in place.
+func (d TableDiff) IsDestructive() bool {
+	if len(d.RemovedColumns) > 0 || len(d.ChangedColumns) > 0 || d.Reordered || d.PrimaryKeyChanged || len(d.RemovedIndexes) > 0 {
+		return true
+	}
+	for _, column := range d.AddedColumns {
+		if !column.Nullable && column.Default == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes - a description of each change to the table, for logging
+func (d TableDiff) Changes() []string {
+	var changes []string
+	name := d.Table.Name
+	for _, column := range d.AddedColumns {
+		changes = append(changes, fmt.Sprintf("column %s.%s added", name, column.Name))
+	}
+	for _, column := range d.RemovedColumns {
+		changes = append(changes, fmt.Sprintf("column %s.%s removed", name, column.Name))
+	}
+	for _, change := range d.ChangedColumns {
+		changes = append(changes, fmt.Sprintf("column %s.%s changed from %s to %s", name, change.New.Name, describeColumn(change.Old), describeColumn(change.New)))
+	}
+	if d.Reordered {
+		changes = append(changes, fmt.Sprintf("columns of %s reordered", name))
+	}
+	if d.PrimaryKeyChanged {
+		changes = append(changes, fmt.Sprintf("primary key of %s changed", name))
+	}
+	for _, index := range d.AddedIndexes {
+		changes = append(changes, fmt.Sprintf("index %s on %s added", describeIndex(index), name))
+	}
+	for _, index := range d.RemovedIndexes {
+		changes = append(changes, fmt.Sprintf("index %s on %s removed", describeIndex(index), name))
+	}
+	return changes
+}
+
+func diffTable(old Table, new Table) TableDiff {
+	diff := TableDiff{Table: new}
+	for _, column := range new.Columns {
+		oldColumn, ok := old.Column(column.Name)
+		if !ok {
+			diff.AddedColumns = append(diff.AddedColumns, column)
+			continue
+		}
+		if !sameColumn(oldColumn, column) {
+			diff.ChangedColumns = append(diff.ChangedColumns, ColumnChange{Old: oldColumn, New: column})
+		}
+	}
+	var kept []string
+	for _, column := range old.Columns {
+		if _, ok := new.Column(column.Name); !ok {
+			diff.RemovedColumns = append(diff.RemovedColumns, column)
+			continue
+		}
+		kept = append(kept, column.Name)
+	}
+	// the kept columns must be the first columns of the new table, in the same order
+	for i, name := range kept {
+		if !strings.EqualFold(new.Columns[i].Name, name) {
+			diff.Reordered = true
+			break
+		}
+	}
+	diff.PrimaryKeyChanged = !sameNames(old.PrimaryKey, new.PrimaryKey)
+
+	for _, index := range new.Indexes {
+		if !containsIndex(old.Indexes, index) {
+			diff.AddedIndexes = append(diff.AddedIndexes, index)
+		}
+	}
+	for _, index := range old.Indexes {
+		if !containsIndex(new.Indexes, index) {
+			diff.RemovedIndexes = append(diff.RemovedIndexes, index)
+		}
+	}
+	return diff
+}
+
+func findTable(tables []Table, name string) (Table, bool) {
+	for _, table := range tables {
+		if strings.EqualFold(table.Name, name) {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
+
+// sameColumn compares column definitions ignoring the case and spacing of types
+func sameColumn(a Column, b Column) bool {
+	return normaliseType(a.Type) == normaliseType(b.Type) && a.Nullable == b.Nullable && a.Default == b.Default
+}
+
+func normaliseType(t string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(t), " "))
+}
+
+func sameNames(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsIndex - whether the same index is in the list; named indexes must also match by name
+func containsIndex(indexes []Index, index Index) bool {
+	for _, other := range indexes {
+		if other.Unique == index.Unique && sameNames(other.Columns, index.Columns) && strings.EqualFold(other.Name, index.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeColumn(c Column) string {
+	description := c.Type
+	if !c.Nullable {
+		description += " NOT NULL"
+	}
+	if c.Default != "" {
+		description += " DEFAULT " + c.Default
+	}
+	return description
+}
+
+func describeIndex(i Index) string {
+	name := i.Name
+	if name == "" {
+		name = "(" + strings.Join(i.Columns, ", ") + ")"
+	}
+	if i.Unique {
+		return "unique " + name
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package ddl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const baseSchema = `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type),
+ KEY ppl_names_value (people_name_value));
+CREATE TABLE ppl_jobs (job_id INT NOT NULL);`
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name        string
+		schema      string
+		changes     []string
+		destructive bool
+	}{
+		{
+			name: "Unchanged",
+			schema: `create table PPL_NAMES (
+ factset_person_id char(8) not null,
+ people_name_type varchar( 35 ) not null,
+ people_name_value varchar(100),
+ primary key (factset_person_id, people_name_type));
+CREATE INDEX ppl_names_value ON ppl_names (people_name_value);
+CREATE TABLE ppl_jobs (job_id INT NOT NULL);`,
+		},
+		{
+			name: "AddedTableColumnsAndIndex",
+			schema: baseSchema + `
+CREATE TABLE ppl_aliases (alias VARCHAR(10));
+ALTER TABLE ppl_names ADD people_name_source VARCHAR(20), ADD people_name_rank INT NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX ppl_names_source ON ppl_names (people_name_source);`,
+			changes: []string{
+				"table ppl_aliases added",
+				"column ppl_names.people_name_source added",
+				"column ppl_names.people_name_rank added",
+				"index unique ppl_names_source on ppl_names added",
+			},
+		},
+		{
+			name:        "RemovedTable",
+			schema:      `CREATE TABLE ppl_jobs (job_id INT NOT NULL);`,
+			changes:     []string{"table ppl_names removed"},
+			destructive: true,
+		},
+		{
+			name: "RemovedAndChangedColumns",
+			schema: `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(50) NOT NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type));
+CREATE TABLE ppl_jobs (job_id INT NOT NULL);`,
+			changes: []string{
+				"column ppl_names.people_name_value removed",
+				"column ppl_names.people_name_type changed from VARCHAR(35) NOT NULL to VARCHAR(50) NOT NULL",
+				"index ppl_names_value on ppl_names removed",
+			},
+			destructive: true,
+		},
+		{
+			name: "ColumnAddedBeforeExistingColumns",
+			schema: `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_source VARCHAR(20),
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type),
+ KEY ppl_names_value (people_name_value));
+CREATE TABLE ppl_jobs (job_id INT NOT NULL);`,
+			changes: []string{
+				"column ppl_names.people_name_source added",
+				"columns of ppl_names reordered",
+			},
+			destructive: true,
+		},
+		{
+			name:        "NotNullColumnWithoutDefault",
+			schema:      baseSchema + "\nALTER TABLE ppl_jobs ADD COLUMN job_title VARCHAR(100) NOT NULL;",
+			changes:     []string{"column ppl_jobs.job_title added"},
+			destructive: true,
+		},
+		{
+			name: "PrimaryKeyChanged",
+			schema: `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID),
+ KEY ppl_names_value (people_name_value));
+CREATE TABLE ppl_jobs (job_id INT NOT NULL);`,
+			changes:     []string{"primary key of ppl_names changed"},
+			destructive: true,
+		},
+	}
+
+	base, err := Parse(baseSchema)
+	assert.NoError(t, err)
+	for _, d := range testCases {
+		schema, err := Parse(d.schema)
+		assert.NoError(t, err, "Test %s failed, unexpected error", d.name)
+		diff := Diff(base.Tables, schema.Tables)
+		assert.Equal(t, d.changes, diff.Changes(), "Test %s failed, wrong changes", d.name)
+		assert.Equal(t, len(d.changes) == 0, diff.IsEmpty(), "Test %s failed, wrong emptiness", d.name)
+		assert.Equal(t, d.destructive, diff.IsDestructive(), "Test %s failed, wrong destructiveness", d.name)
+	}
+}
+
+func TestSchemaDiffString(t *testing.T) {
+	assert.Equal(t, "no changes", SchemaDiff{}.String())
+	diff := SchemaDiff{AddedTables: []Table{{Name: "a"}}, RemovedTables: []Table{{Name: "b"}}}
+	assert.Equal(t, "table a added, table b removed", diff.String())
+}
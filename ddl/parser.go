@@ -0,0 +1,575 @@
+package ddl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// StatementKind - what a statement of a schema script does
+type StatementKind int
+
+const (
+	// Other - a statement the parser does not model, e.g. SET or DROP TABLE
+	Other StatementKind = iota
+	// CreateTable - a CREATE TABLE statement
+	CreateTable
+	// CreateIndex - a CREATE INDEX statement
+	CreateIndex
+	// AlterTable - an ALTER TABLE statement adding columns or keys
+	AlterTable
+)
+
+// Schema - the tables created by a schema script, and the statements of the script in order
+type Schema struct {
+	Statements []ParsedStatement
+	Tables     []Table
+}
+
+// ParsedStatement - a statement of a schema script and the table it creates or changes, if any
+type ParsedStatement struct {
+	Statement
+	Kind StatementKind
+	// Table is the name of the table the statement creates or changes
+	Table string
+}
+
+// Table - a table created by a schema script
+type Table struct {
+	// Name is the name of the table as written, qualified names are joined with a '.'
+	Name        string
+	IfNotExists bool
+	Columns     []Column
+	PrimaryKey  []string
+	Indexes     []Index
+	// Statement is the text of the CREATE TABLE statement
+	Statement string
+}
+
+// Column - a column of a table
+type Column struct {
+	Name string
+	// Type is the type as written, e.g. VARCHAR(35) or DECIMAL(10,2) UNSIGNED
+	Type     string
+	Nullable bool
+	// Default is the default value as written, or empty when the column has none
+	Default string
+}
+
+// Index - a secondary index or unique key of a table
+type Index struct {
+	// Name may be empty for keys declared without one
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// Table - the table with the name, compared ignoring case as MySQL and FactSet do
+func (s Schema) Table(name string) (Table, bool) {
+	for _, table := range s.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return table, true
+		}
+	}
+	return Table{}, false
+}
+
+// TableNames - the names of the tables in the order they are created
+func (s Schema) TableNames() []string {
+	names := make([]string, len(s.Tables))
+	for i, table := range s.Tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// Column - the column with the name, compared ignoring case
+func (t Table) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// ColumnNames - the names of the columns in table order
+func (t Table) ColumnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Parse - parses a schema script. CREATE TABLE statements are modelled along with the keys added to
+// their tables by CREATE INDEX and ALTER TABLE ... ADD; other statements are kept but not interpreted.
+func Parse(script string) (Schema, error) {
+	statements, err := SplitStatements(script)
+	if err != nil {
+		return Schema{}, err
+	}
+	var schema Schema
+	for _, statement := range statements {
+		parsed, err := parseStatement(&schema, statement)
+		if err != nil {
+			return Schema{}, err
+		}
+		schema.Statements = append(schema.Statements, parsed)
+	}
+	return schema, nil
+}
+
+// ParseCreateTable - parses a single CREATE TABLE statement, e.g. one stored from an earlier schema
+func ParseCreateTable(statement string) (Table, error) {
+	schema, err := Parse(statement)
+	if err != nil {
+		return Table{}, err
+	}
+	if len(schema.Statements) != 1 || schema.Statements[0].Kind != CreateTable {
+		return Table{}, fmt.Errorf("not a single CREATE TABLE statement")
+	}
+	return schema.Tables[0], nil
+}
+
+func parseStatement(schema *Schema, statement Statement) (ParsedStatement, error) {
+	p := &parser{tokens: statement.Tokens}
+	parsed := ParsedStatement{Statement: statement}
+	switch {
+	case p.accept("CREATE"):
+		p.accept("TEMPORARY")
+		if p.accept("TABLE") {
+			table, err := p.createTable()
+			if err != nil {
+				return parsed, err
+			}
+			if _, exists := schema.Table(table.Name); exists {
+				return parsed, fmt.Errorf("line %d: table %s is created more than once", statement.Tokens[0].Line, table.Name)
+			}
+			table.Statement = statement.Text
+			schema.Tables = append(schema.Tables, table)
+			parsed.Kind, parsed.Table = CreateTable, table.Name
+			return parsed, nil
+		}
+		unique := p.accept("UNIQUE")
+		if !unique && !p.accept("FULLTEXT") {
+			p.accept("SPATIAL")
+		}
+		if !p.accept("INDEX") {
+			return parsed, nil
+		}
+		tableName, index, err := p.createIndex(unique)
+		if err != nil {
+			return parsed, err
+		}
+		if table := schema.table(tableName); table != nil {
+			table.Indexes = append(table.Indexes, index)
+		}
+		parsed.Kind, parsed.Table = CreateIndex, tableName
+	case p.accept("ALTER"):
+		if !p.accept("TABLE") {
+			return parsed, nil
+		}
+		tableName, err := p.name()
+		if err != nil {
+			return parsed, err
+		}
+		table := schema.table(tableName)
+		if table == nil {
+			table = &Table{Name: tableName}
+		}
+		if err := p.alterTable(table); err != nil {
+			return parsed, err
+		}
+		parsed.Kind, parsed.Table = AlterTable, tableName
+	}
+	return parsed, nil
+}
+
+// table - a pointer to the named table so that later statements can add to it
+func (s *Schema) table(name string) *Table {
+	for i := range s.Tables {
+		if strings.EqualFold(s.Tables[i].Name, name) {
+			return &s.Tables[i]
+		}
+	}
+	return nil
+}
+
+type parser struct {
+	tokens []Token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() Token {
+	if p.done() {
+		return Token{Kind: Semicolon}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept moves past the next token if it is the keyword
+func (p *parser) accept(keyword string) bool {
+	if p.peek().Is(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptPunctuation(c string) bool {
+	if p.peek().IsPunctuation(c) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if len(p.tokens) > 0 {
+		line = p.tokens[len(p.tokens)-1].Line
+		if !p.done() {
+			line = p.tokens[p.pos].Line
+		}
+	}
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// name reads a possibly qualified table, column or index name
+func (p *parser) name() (string, error) {
+	var parts []string
+	for {
+		token := p.peek()
+		if !token.IsName() {
+			if p.done() {
+				return "", p.errorf("expected a name at the end of the statement")
+			}
+			return "", p.errorf("expected a name but found %q", token.Raw)
+		}
+		p.pos++
+		parts = append(parts, token.Value)
+		if !p.acceptPunctuation(".") {
+			return strings.Join(parts, "."), nil
+		}
+	}
+}
+
+// createTable reads the rest of CREATE TABLE [IF NOT EXISTS] name (definitions) [options]
+func (p *parser) createTable() (Table, error) {
+	var table Table
+	if p.accept("IF") {
+		if !p.accept("NOT") || !p.accept("EXISTS") {
+			return table, p.errorf("expected IF NOT EXISTS")
+		}
+		table.IfNotExists = true
+	}
+	name, err := p.name()
+	if err != nil {
+		return table, err
+	}
+	table.Name = name
+	if p.accept("LIKE") {
+		return table, nil
+	}
+	if !p.acceptPunctuation("(") {
+		return table, p.errorf("expected the column definitions of table %s", name)
+	}
+	definitions, err := p.list()
+	if err != nil {
+		return table, err
+	}
+	for _, definition := range definitions {
+		if err := definition.addTo(&table); err != nil {
+			return table, err
+		}
+	}
+	if len(table.Columns) == 0 {
+		return table, p.errorf("table %s has no columns", name)
+	}
+	return table, nil
+}
+
+// createIndex reads the rest of CREATE INDEX name [USING type] ON table (columns)
+func (p *parser) createIndex(unique bool) (string, Index, error) {
+	name, err := p.name()
+	if err != nil {
+		return "", Index{}, err
+	}
+	if p.accept("USING") {
+		p.pos++
+	}
+	if !p.accept("ON") {
+		return "", Index{}, p.errorf("expected ON after index %s", name)
+	}
+	tableName, err := p.name()
+	if err != nil {
+		return "", Index{}, err
+	}
+	columns, err := p.keyColumns()
+	if err != nil {
+		return "", Index{}, err
+	}
+	return tableName, Index{Name: name, Columns: columns, Unique: unique}, nil
+}
+
+// alterTable reads ADD clauses of ALTER TABLE, ignoring any other changes
+func (p *parser) alterTable(table *Table) error {
+	for !p.done() {
+		if !p.accept("ADD") {
+			p.skipClause()
+			continue
+		}
+		p.accept("COLUMN")
+		start := p.pos
+		p.skipClause()
+		end := p.pos
+		if p.tokens[end-1].IsPunctuation(",") {
+			end--
+		}
+		if end == start {
+			return p.errorf("expected a column or key after ADD")
+		}
+		if err := (definition{tokens: p.tokens[start:end]}).addTo(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipClause moves past the tokens up to the next comma outside of brackets
+func (p *parser) skipClause() {
+	depth := 0
+	for ; !p.done(); p.pos++ {
+		token := p.peek()
+		switch {
+		case token.IsPunctuation("("):
+			depth++
+		case token.IsPunctuation(")"):
+			depth--
+		case token.IsPunctuation(",") && depth == 0:
+			p.pos++
+			return
+		}
+	}
+}
+
+// list reads the comma separated definitions up to the closing bracket, after the opening one
+func (p *parser) list() ([]definition, error) {
+	var definitions []definition
+	depth := 0
+	start := p.pos
+	for ; !p.done(); p.pos++ {
+		token := p.peek()
+		switch {
+		case token.IsPunctuation("("):
+			depth++
+		case token.IsPunctuation(")") && depth > 0:
+			depth--
+		case (token.IsPunctuation(",") || token.IsPunctuation(")")) && depth == 0:
+			if p.pos == start {
+				return nil, p.errorf("empty definition")
+			}
+			definitions = append(definitions, definition{tokens: p.tokens[start:p.pos]})
+			start = p.pos + 1
+			if token.IsPunctuation(")") {
+				p.pos++
+				return definitions, nil
+			}
+		}
+	}
+	return nil, p.errorf("brackets are not closed")
+}
+
+// keyColumns reads a bracketed list of key columns, ignoring prefix lengths and sort orders
+func (p *parser) keyColumns() ([]string, error) {
+	if !p.acceptPunctuation("(") {
+		return nil, p.errorf("expected a list of columns")
+	}
+	parts, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(parts))
+	for i, part := range parts {
+		if !part.tokens[0].IsName() {
+			return nil, p.errorf("expected a column name but found %q", part.tokens[0].Raw)
+		}
+		columns[i] = part.tokens[0].Value
+	}
+	return columns, nil
+}
+
+// definition - the tokens of a column or key definition inside CREATE TABLE
+type definition struct {
+	tokens []Token
+}
+
+// addTo adds the column or key the definition declares to the table
+func (d definition) addTo(table *Table) error {
+	p := &parser{tokens: d.tokens}
+	if p.accept("CONSTRAINT") && !p.peek().Is("PRIMARY") && !p.peek().Is("UNIQUE") &&
+		!p.peek().Is("FOREIGN") && !p.peek().Is("CHECK") {
+		p.pos++
+	}
+	switch {
+	case p.accept("PRIMARY"):
+		if !p.accept("KEY") {
+			return p.errorf("expected PRIMARY KEY")
+		}
+		p.skipIndexType()
+		columns, err := p.keyColumns()
+		if err != nil {
+			return err
+		}
+		if len(table.PrimaryKey) > 0 {
+			return p.errorf("table %s has more than one primary key", table.Name)
+		}
+		table.PrimaryKey = columns
+		return nil
+	case p.peek().Is("UNIQUE"), p.peek().Is("KEY"), p.peek().Is("INDEX"),
+		p.peek().Is("FULLTEXT"), p.peek().Is("SPATIAL"):
+		index := Index{Unique: p.accept("UNIQUE")}
+		if !index.Unique && !p.accept("FULLTEXT") {
+			p.accept("SPATIAL")
+		}
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+		if p.peek().IsName() && !p.peek().Is("USING") {
+			index.Name, _ = p.name()
+		}
+		p.skipIndexType()
+		columns, err := p.keyColumns()
+		if err != nil {
+			return err
+		}
+		index.Columns = columns
+		table.Indexes = append(table.Indexes, index)
+		return nil
+	case p.peek().Is("FOREIGN"), p.peek().Is("CHECK"):
+		return nil
+	}
+	column, primaryKey, unique, err := p.column()
+	if err != nil {
+		return err
+	}
+	if _, exists := table.Column(column.Name); exists {
+		return p.errorf("table %s has more than one column named %s", table.Name, column.Name)
+	}
+	table.Columns = append(table.Columns, column)
+	if primaryKey {
+		if len(table.PrimaryKey) > 0 {
+			return p.errorf("table %s has more than one primary key", table.Name)
+		}
+		table.PrimaryKey = []string{column.Name}
+	}
+	if unique {
+		table.Indexes = append(table.Indexes, Index{Name: column.Name, Columns: []string{column.Name}, Unique: true})
+	}
+	return nil
+}
+
+func (p *parser) skipIndexType() {
+	if p.accept("USING") {
+		p.pos++
+	}
+}
+
+// columnAttributes - keywords that end the type of a column
+var columnAttributes = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "KEY": true, "UNIQUE": true,
+	"AUTO_INCREMENT": true, "COMMENT": true, "COLLATE": true, "CHARSET": true, "REFERENCES": true,
+	"CHECK": true, "CONSTRAINT": true, "GENERATED": true, "AS": true, "ON": true,
+	"COLUMN_FORMAT": true, "STORAGE": true, "VISIBLE": true, "INVISIBLE": true,
+}
+
+// column reads a column name, type and attributes
+func (p *parser) column() (Column, bool, bool, error) {
+	column := Column{Nullable: true}
+	name, err := p.name()
+	if err != nil {
+		return column, false, false, err
+	}
+	column.Name = name
+
+	var typ bytes.Buffer
+	depth := 0
+	for !p.done() {
+		token := p.peek()
+		if depth == 0 && token.Kind == Word && (columnAttributes[strings.ToUpper(token.Value)] ||
+			token.Is("CHARACTER") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Is("SET")) {
+			break
+		}
+		switch {
+		case token.IsPunctuation("("):
+			depth++
+		case token.IsPunctuation(")"):
+			depth--
+		}
+		if typ.Len() > 0 && token.Kind == Word && !p.tokens[p.pos-1].IsPunctuation("(") {
+			typ.WriteString(" ")
+		}
+		typ.WriteString(token.Raw)
+		p.pos++
+	}
+	if typ.Len() == 0 {
+		return column, false, false, p.errorf("column %s has no type", name)
+	}
+	column.Type = typ.String()
+
+	var primaryKey, unique bool
+	for !p.done() {
+		switch {
+		case p.accept("NOT"):
+			if !p.accept("NULL") {
+				return column, false, false, p.errorf("expected NOT NULL for column %s", name)
+			}
+			column.Nullable = false
+		case p.accept("NULL"):
+			column.Nullable = true
+		case p.accept("DEFAULT"):
+			column.Default = p.value()
+		case p.accept("PRIMARY"), p.accept("KEY"):
+			p.accept("KEY")
+			primaryKey = true
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			unique = true
+		default:
+			p.pos++
+		}
+	}
+	if primaryKey {
+		column.Nullable = false
+	}
+	return column, primaryKey, unique, nil
+}
+
+// value reads a default value, which may be a bracketed expression or a signed number
+func (p *parser) value() string {
+	var b bytes.Buffer
+	depth := 0
+	for !p.done() {
+		token := p.peek()
+		b.WriteString(token.Raw)
+		p.pos++
+		switch {
+		case token.IsPunctuation("("):
+			depth++
+			continue
+		case token.IsPunctuation(")"):
+			depth--
+		case token.IsPunctuation("-") || token.IsPunctuation("+"):
+			continue
+		}
+		if depth == 0 && !p.peek().IsPunctuation("(") {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package ddl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	script := `-- FactSet people schema
+CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NOT NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type, people_name_value));
+
+CREATE TABLE IF NOT EXISTS ` + "`ppl_jobs`" + ` (
+  ` + "`job_id`" + ` INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY COMMENT 'the; id',
+  salary DECIMAL(10, 2) DEFAULT 0.00,
+  title VARCHAR(100) CHARACTER SET utf8 COLLATE utf8_bin NULL,
+  updated DATETIME DEFAULT CURRENT_TIMESTAMP,
+  code CHAR(2) DEFAULT NULL UNIQUE,
+  KEY ppl_jobs_title (title(10) ASC),
+  CONSTRAINT ppl_jobs_code UNIQUE INDEX (code, salary),
+  CONSTRAINT fk FOREIGN KEY (code) REFERENCES codes (code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE UNIQUE INDEX ppl_names_value ON ppl_names (people_name_value);
+ALTER TABLE ppl_jobs ADD COLUMN grade SMALLINT NOT NULL DEFAULT -1, ADD INDEX ppl_jobs_grade (grade);
+SET foreign_key_checks = 1;`
+
+	schema, err := Parse(script)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ppl_names", "ppl_jobs"}, schema.TableNames())
+
+	var kinds []StatementKind
+	for _, statement := range schema.Statements {
+		kinds = append(kinds, statement.Kind)
+	}
+	assert.Equal(t, []StatementKind{CreateTable, CreateTable, CreateIndex, AlterTable, Other}, kinds)
+
+	names, ok := schema.Table("PPL_NAMES")
+	assert.True(t, ok)
+	assert.False(t, names.IfNotExists)
+	assert.Equal(t, []Column{
+		{Name: "FACTSET_PERSON_ID", Type: "CHAR(8)"},
+		{Name: "people_name_type", Type: "VARCHAR(35)"},
+		{Name: "people_name_value", Type: "VARCHAR(100)"},
+	}, names.Columns)
+	assert.Equal(t, []string{"FACTSET_PERSON_ID", "people_name_type", "people_name_value"}, names.PrimaryKey)
+	assert.Equal(t, []Index{{Name: "ppl_names_value", Columns: []string{"people_name_value"}, Unique: true}}, names.Indexes)
+	assert.Equal(t, schema.Statements[0].Text, names.Statement)
+
+	jobs, ok := schema.Table("ppl_jobs")
+	assert.True(t, ok)
+	assert.True(t, jobs.IfNotExists)
+	assert.Equal(t, []Column{
+		{Name: "job_id", Type: "INT UNSIGNED"},
+		{Name: "salary", Type: "DECIMAL(10,2)", Nullable: true, Default: "0.00"},
+		{Name: "title", Type: "VARCHAR(100)", Nullable: true},
+		{Name: "updated", Type: "DATETIME", Nullable: true, Default: "CURRENT_TIMESTAMP"},
+		{Name: "code", Type: "CHAR(2)", Nullable: true, Default: "NULL"},
+		{Name: "grade", Type: "SMALLINT", Default: "-1"},
+	}, jobs.Columns)
+	assert.Equal(t, []string{"job_id"}, jobs.PrimaryKey)
+	assert.Equal(t, []Index{
+		{Name: "code", Columns: []string{"code"}, Unique: true},
+		{Name: "ppl_jobs_title", Columns: []string{"title"}},
+		{Columns: []string{"code", "salary"}, Unique: true},
+		{Name: "ppl_jobs_grade", Columns: []string{"grade"}},
+	}, jobs.Indexes)
+	assert.Equal(t, []string{"job_id", "salary", "title", "updated", "code", "grade"}, jobs.ColumnNames())
+}
+
+func TestParseNames(t *testing.T) {
+	testCases := []struct {
+		name      string
+		statement string
+		table     string
+	}{
+		{"Plain", "CREATE TABLE ppl_names (id INT)", "ppl_names"},
+		{"LowerCase", "create table ppl_names (id int)", "ppl_names"},
+		{"ExtraWhitespace", "CREATE \n\t TABLE\n  ppl_names(id INT)", "ppl_names"},
+		{"Comments", "CREATE /* people */ TABLE -- names\n ppl_names/**/(id INT)", "ppl_names"},
+		{"IfNotExists", "CREATE TABLE IF NOT EXISTS ppl_names (id INT)", "ppl_names"},
+		{"Temporary", "CREATE TEMPORARY TABLE ppl_names (id INT)", "ppl_names"},
+		{"Backticks", "CREATE TABLE `ppl_names` (id INT)", "ppl_names"},
+		{"DoubleQuotes", `CREATE TABLE "ppl_names" (id INT)`, "ppl_names"},
+		{"Qualified", "CREATE TABLE `fds`.`ppl_names` (id INT)", "fds.ppl_names"},
+		{"Unsafe", "CREATE TABLE `ppl_names; DROP TABLE x` (id INT)", "ppl_names; DROP TABLE x"},
+		{"Like", "CREATE TABLE ppl_names LIKE ppl_names_template", "ppl_names"},
+	}
+	for _, d := range testCases {
+		table, err := ParseCreateTable(d.statement)
+		assert.NoError(t, err, "Test %s failed, unexpected error", d.name)
+		assert.Equal(t, d.table, table.Name, "Test %s failed, wrong table name", d.name)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"NoName", "CREATE TABLE (id INT);", `line 1: expected a name but found "("`},
+		{"NameAtEnd", "CREATE TABLE IF NOT EXISTS", "line 1: expected a name at the end of the statement"},
+		{"NoColumns", "CREATE TABLE t;", "line 1: expected the column definitions of table t"},
+		{"EmptyColumns", "CREATE TABLE t ();", "line 1: empty definition"},
+		{"Unclosed", "CREATE TABLE t (\nid INT;", "line 2: brackets are not closed"},
+		{"NoType", "CREATE TABLE t (id NOT NULL);", "line 1: column id has no type"},
+		{"DuplicateColumn", "CREATE TABLE t (id INT, ID INT);", "line 1: table t has more than one column named ID"},
+		{"DuplicatePrimaryKey", "CREATE TABLE t (id INT PRIMARY KEY, PRIMARY KEY (id));", "line 1: table t has more than one primary key"},
+		{"DuplicateTable", "CREATE TABLE t (id INT);\nCREATE TABLE T (id INT);", "line 2: table T is created more than once"},
+		{"BadIfNotExists", "CREATE TABLE IF EXISTS t (id INT);", "line 1: expected IF NOT EXISTS"},
+		{"IndexWithoutTable", "CREATE INDEX i (id);", `line 1: expected ON after index i`},
+	}
+	for _, d := range testCases {
+		_, err := Parse(d.script)
+		if assert.Error(t, err, "Test %s failed, expected an error", d.name) {
+			assert.Equal(t, d.err, err.Error(), "Test %s failed, wrong error", d.name)
+		}
+	}
+
+	_, err := ParseCreateTable("CREATE INDEX i ON t (id)")
+	assert.Error(t, err)
+}
@@ -0,0 +1,283 @@
+package ddl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind - the kind of a token read from an SQL script
+type TokenKind int
+
+const (
+	// Word - a keyword or an unquoted name
+	Word TokenKind = iota
+	// QuotedIdentifier - a name quoted with backticks or double quotes
+	QuotedIdentifier
+	// String - a single quoted string literal
+	String
+	// Number - a numeric literal
+	Number
+	// Punctuation - any other single character, e.g. ( ) , . =
+	Punctuation
+	// Semicolon - the end of a statement
+	Semicolon
+)
+
+// Token - a token of an SQL script. Comments and whitespace are not returned as tokens.
+type Token struct {
+	Kind TokenKind
+	// Value is the text of the token, without the quotes of quoted identifiers and strings
+	Value string
+	// Raw is the text of the token as it appears in the script
+	Raw string
+	// Line is the line of the script the token starts on, from 1
+	Line int
+	// Pos and End are the byte offsets of the token in the script
+	Pos int
+	End int
+}
+
+// Is - whether the token is the given keyword, ignoring case
+func (t Token) Is(keyword string) bool {
+	return t.Kind == Word && strings.EqualFold(t.Value, keyword)
+}
+
+// IsPunctuation - whether the token is the given punctuation character
+func (t Token) IsPunctuation(p string) bool {
+	return t.Kind == Punctuation && t.Value == p
+}
+
+// IsName - whether the token can be a table, column or index name
+func (t Token) IsName() bool {
+	return t.Kind == Word || t.Kind == QuotedIdentifier
+}
+
+// Statement - a statement of an SQL script
+type Statement struct {
+	// Text is the statement without its terminating semicolon, with any comments inside it replaced by a space
+	Text   string
+	Tokens []Token
+}
+
+// Tokenize - splits an SQL script into tokens, skipping whitespace and --, # and /* */ comments.
+// Quoted strings and names may contain semicolons, comment markers and doubled or escaped quotes.
+func Tokenize(script string) ([]Token, error) {
+	t := tokenizer{src: script, line: 1}
+	var tokens []Token
+	for {
+		token, ok, err := t.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return tokens, nil
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// SplitStatements - splits an SQL script into its statements. Empty statements, including those
+// made up only of comments, are dropped.
+func SplitStatements(script string) ([]Statement, error) {
+	tokens, err := Tokenize(script)
+	if err != nil {
+		return nil, err
+	}
+	var statements []Statement
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].Kind != Semicolon {
+			continue
+		}
+		if i > start {
+			statements = append(statements, newStatement(script, tokens[start:i]))
+		}
+		start = i + 1
+	}
+	return statements, nil
+}
+
+// newStatement builds the text of a statement from the script, keeping its layout but dropping comments
+func newStatement(script string, tokens []Token) Statement {
+	var b bytes.Buffer
+	for i, token := range tokens {
+		if i > 0 {
+			gap := script[tokens[i-1].End:token.Pos]
+			if strings.TrimSpace(gap) == "" {
+				b.WriteString(gap)
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(token.Raw)
+	}
+	return Statement{Text: b.String(), Tokens: tokens}
+}
+
+type tokenizer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (t *tokenizer) peek(offset int) byte {
+	if t.pos+offset >= len(t.src) {
+		return 0
+	}
+	return t.src[t.pos+offset]
+}
+
+// advance moves past n bytes, counting the lines passed
+func (t *tokenizer) advance(n int) {
+	for i := 0; i < n && t.pos < len(t.src); i++ {
+		if t.src[t.pos] == '\n' {
+			t.line++
+		}
+		t.pos++
+	}
+}
+
+// skipSpaceAndComments moves to the start of the next token
+func (t *tokenizer) skipSpaceAndComments() error {
+	for t.pos < len(t.src) {
+		c := t.peek(0)
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == '\v':
+			t.advance(1)
+		case c == '-' && t.peek(1) == '-', c == '#':
+			for t.pos < len(t.src) && t.peek(0) != '\n' {
+				t.advance(1)
+			}
+		case c == '/' && t.peek(1) == '*':
+			line := t.line
+			end := strings.Index(t.src[t.pos+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("line %d: comment is not closed", line)
+			}
+			t.advance(end + 4)
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (t *tokenizer) next() (Token, bool, error) {
+	if err := t.skipSpaceAndComments(); err != nil {
+		return Token{}, false, err
+	}
+	if t.pos >= len(t.src) {
+		return Token{}, false, nil
+	}
+
+	start, line := t.pos, t.line
+	token := Token{Line: line, Pos: start}
+	c := t.peek(0)
+	switch {
+	case c == '\'':
+		value, err := t.quoted('\'', true)
+		if err != nil {
+			return Token{}, false, fmt.Errorf("line %d: string is not closed", line)
+		}
+		token.Kind, token.Value = String, value
+	case c == '`' || c == '"':
+		value, err := t.quoted(c, false)
+		if err != nil {
+			return Token{}, false, fmt.Errorf("line %d: quoted name is not closed", line)
+		}
+		token.Kind, token.Value = QuotedIdentifier, value
+	case c == ';':
+		t.advance(1)
+		token.Kind = Semicolon
+	case c >= '0' && c <= '9', c == '.' && t.peek(1) >= '0' && t.peek(1) <= '9':
+		t.number()
+		token.Kind = Number
+	case isWordByte(c):
+		for t.pos < len(t.src) && isWordByte(t.peek(0)) {
+			_, size := utf8.DecodeRuneInString(t.src[t.pos:])
+			t.advance(size)
+		}
+		token.Kind = Word
+	default:
+		_, size := utf8.DecodeRuneInString(t.src[t.pos:])
+		t.advance(size)
+		token.Kind = Punctuation
+	}
+	token.End = t.pos
+	token.Raw = t.src[start:t.pos]
+	if token.Kind != String && token.Kind != QuotedIdentifier {
+		token.Value = token.Raw
+	}
+	return token, true, nil
+}
+
+// quoted reads a quoted string or name, where the quote is escaped by doubling it or, in strings, with a backslash
+func (t *tokenizer) quoted(quote byte, backslashEscapes bool) (string, error) {
+	var b bytes.Buffer
+	t.advance(1)
+	for t.pos < len(t.src) {
+		c := t.peek(0)
+		switch {
+		case backslashEscapes && c == '\\' && t.pos+1 < len(t.src):
+			b.WriteByte(unescape(t.peek(1)))
+			t.advance(2)
+		case c == quote && t.peek(1) == quote:
+			b.WriteByte(quote)
+			t.advance(2)
+		case c == quote:
+			t.advance(1)
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			t.advance(1)
+		}
+	}
+	return "", fmt.Errorf("%c is not closed", quote)
+}
+
+func (t *tokenizer) number() {
+	for t.pos < len(t.src) && (isDigit(t.peek(0)) || t.peek(0) == '.') {
+		t.advance(1)
+	}
+	if c := t.peek(0); c == 'e' || c == 'E' {
+		n := 1
+		if s := t.peek(1); s == '+' || s == '-' {
+			n = 2
+		}
+		if isDigit(t.peek(n)) {
+			t.advance(n)
+			for t.pos < len(t.src) && isDigit(t.peek(0)) {
+				t.advance(1)
+			}
+		}
+	}
+}
+
+// unescape - the character a MySQL backslash escape in a string stands for
+func unescape(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 26
+	}
+	return c
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isWordByte - whether the byte can be part of a keyword or unquoted name; any non-ASCII character can be
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || isDigit(c)
+}
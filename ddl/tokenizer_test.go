@@ -0,0 +1,92 @@
+package ddl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("CREATE TABLE `ppl;names` -- the; names\n(name VARCHAR(35) DEFAULT 'O''Brien\\'s;' /* ; */, n DOUBLE DEFAULT -1.5e3);")
+	assert.NoError(t, err)
+
+	var values []string
+	var kinds []TokenKind
+	for _, token := range tokens {
+		values = append(values, token.Value)
+		kinds = append(kinds, token.Kind)
+	}
+	assert.Equal(t, []string{"CREATE", "TABLE", "ppl;names", "(", "name", "VARCHAR", "(", "35", ")", "DEFAULT", "O'Brien's;", ",",
+		"n", "DOUBLE", "DEFAULT", "-", "1.5e3", ")", ";"}, values)
+	assert.Equal(t, []TokenKind{Word, Word, QuotedIdentifier, Punctuation, Word, Word, Punctuation, Number, Punctuation, Word, String, Punctuation,
+		Word, Word, Word, Punctuation, Number, Punctuation, Semicolon}, kinds)
+	assert.Equal(t, "`ppl;names`", tokens[2].Raw)
+	assert.Equal(t, 1, tokens[2].Line)
+	assert.Equal(t, 2, tokens[3].Line)
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"UnclosedString", "CREATE TABLE t (a CHAR(1) DEFAULT 'a);", "line 1: string is not closed"},
+		{"UnclosedName", "CREATE TABLE\n`t (a INT);", "line 2: quoted name is not closed"},
+		{"UnclosedComment", "CREATE TABLE t (a INT);\n/* the end", "line 2: comment is not closed"},
+	}
+	for _, d := range testCases {
+		_, err := Tokenize(d.script)
+		if assert.Error(t, err, "Test %s failed, expected an error", d.name) {
+			assert.Equal(t, d.err, err.Error(), "Test %s failed, wrong error", d.name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		name       string
+		script     string
+		statements []string
+	}{
+		{
+			"Simple",
+			"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			[]string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			"NoFinalSemicolon",
+			"CREATE TABLE a (id INT)",
+			[]string{"CREATE TABLE a (id INT)"},
+		},
+		{
+			"EmptyStatementsAndComments",
+			"-- header\n;;\n# another\n/* block; comment */;\n  SET x = 1 ;",
+			[]string{"SET x = 1"},
+		},
+		{
+			"ShortStatements",
+			"USE a;DROP b;",
+			[]string{"USE a", "DROP b"},
+		},
+		{
+			"SemicolonsInLiterals",
+			"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`);",
+			[]string{"INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`)"},
+		},
+		{
+			"CommentsInsideStatements",
+			"CREATE TABLE a ( -- the id\n\tid INT /* key */ NOT NULL\n)",
+			[]string{"CREATE TABLE a ( id INT NOT NULL\n)"},
+		},
+	}
+	for _, d := range testCases {
+		statements, err := SplitStatements(d.script)
+		assert.NoError(t, err, "Test %s failed, unexpected error", d.name)
+		var texts []string
+		for _, statement := range statements {
+			texts = append(texts, statement.Text)
+		}
+		assert.Equal(t, d.statements, texts, "Test %s failed, wrong statements", d.name)
+	}
+}
@@ -0,0 +1,89 @@
+package factset
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RemoteBundle - a bundle of a product found on the Factset server, with the latest files published for a feed version
+type RemoteBundle struct {
+	FSPackage   string
+	Product     string
+	Bundle      string
+	FeedVersion int
+	// LatestFull and LatestDelta are the zero version when no file of the kind has been published
+	LatestFull  PackageVersion
+	LatestDelta PackageVersion
+	Files       int
+}
+
+// Data archives are named bundle_vN_full_sequence.zip or bundle_vN_sequence.zip
+var archiveNamePattern = regexp.MustCompile(`^(.+)_v([0-9]+)(_full)?_([0-9]+)\.zip$`)
+
+// Discover - lists the bundles of every product on the Factset server, from the names of their data archives
+func (s *Service) Discover(ctx context.Context) ([]RemoteBundle, error) {
+	packages, err := s.client.ReadDir(ctx, s.ftpServerBaseDir)
+	if err != nil {
+		log.WithError(err).Errorf("Error reading: %s", s.ftpServerBaseDir)
+		return nil, err
+	}
+
+	var bundles []RemoteBundle
+	for _, fsPackage := range packages {
+		if !fsPackage.IsDir() || "/"+fsPackage.Name() == schemaDir {
+			continue
+		}
+		packageDirectory := path.Join(s.ftpServerBaseDir, fsPackage.Name())
+		products, err := s.client.ReadDir(ctx, packageDirectory)
+		if err != nil {
+			log.WithError(err).Errorf("Error reading: %s", packageDirectory)
+			return nil, err
+		}
+		for _, product := range products {
+			if !product.IsDir() {
+				continue
+			}
+			productDirectory := path.Join(packageDirectory, product.Name())
+			files, err := s.client.ReadDir(ctx, productDirectory)
+			if err != nil {
+				log.WithError(err).Errorf("Error reading: %s", productDirectory)
+				return nil, err
+			}
+			found := make(map[string]*RemoteBundle)
+			var names []string
+			for _, file := range files {
+				match := archiveNamePattern.FindStringSubmatch(file.Name())
+				if file.IsDir() || match == nil {
+					continue
+				}
+				feedVersion, _ := strconv.Atoi(match[2])
+				sequence, _ := strconv.Atoi(match[4])
+				key := match[1] + "_v" + match[2]
+				bundle, ok := found[key]
+				if !ok {
+					bundle = &RemoteBundle{FSPackage: fsPackage.Name(), Product: product.Name(), Bundle: match[1], FeedVersion: feedVersion}
+					found[key] = bundle
+					names = append(names, key)
+				}
+				bundle.Files++
+				version := PackageVersion{FeedVersion: feedVersion, Sequence: sequence}
+				if match[3] != "" && sequence > bundle.LatestFull.Sequence {
+					bundle.LatestFull = version
+				}
+				if match[3] == "" && sequence > bundle.LatestDelta.Sequence {
+					bundle.LatestDelta = version
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				bundles = append(bundles, *found[name])
+			}
+		}
+	}
+	return bundles, nil
+}
@@ -0,0 +1,62 @@
+package factset
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Discover(t *testing.T) {
+	root, err := ioutil.TempDir("", "factset-discover")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+
+	files := []string{
+		"documents/docs_ppl/ppl_v1_schema_12.zip",
+		"people/ppl_premium/ppl_premium_v1_full_10.zip",
+		"people/ppl_premium/ppl_premium_v1_full_12.zip",
+		"people/ppl_premium/ppl_premium_v1_11.zip",
+		"people/ppl_premium/ppl_premium_v1_13.zip",
+		"people/ppl_premium/ppl_premium_summary_v2_full_3.zip",
+		"people/ppl_premium/readme.txt",
+		"fundamentals/ff_advanced_ap_v3/ff_advanced_der_ap_v3_full_1234.zip",
+		"fundamentals/notes.txt",
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	}
+
+	fs := &Service{&dirSftpClient{}, root}
+	bundles, err := fs.Discover(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []RemoteBundle{
+		{FSPackage: "fundamentals", Product: "ff_advanced_ap_v3", Bundle: "ff_advanced_der_ap", FeedVersion: 3, LatestFull: PackageVersion{3, 1234}, Files: 1},
+		{FSPackage: "people", Product: "ppl_premium", Bundle: "ppl_premium_summary", FeedVersion: 2, LatestFull: PackageVersion{2, 3}, Files: 1},
+		{FSPackage: "people", Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 1, LatestFull: PackageVersion{1, 12}, LatestDelta: PackageVersion{1, 13}, Files: 4},
+	}, bundles)
+
+	fs = &Service{&dirSftpClient{}, filepath.Join(root, "missing")}
+	_, err = fs.Discover(context.Background())
+	assert.Error(t, err)
+}
+
+// dirSftpClient - reads directories from the local file system, so a whole server layout can be tested
+type dirSftpClient struct{}
+
+func (c *dirSftpClient) ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(dir)
+}
+
+func (c *dirSftpClient) Download(ctx context.Context, path string, dest string, product string) error {
+	return nil
+}
+
+func (c *dirSftpClient) Close() error {
+	return nil
+}
@@ -0,0 +1,24 @@
+package factset
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// durationBuckets - histogram buckets, in seconds, suited to the duration of downloads
+var durationBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600}
+
+var (
+	downloadedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factset_uploader_downloaded_bytes_total",
+		Help: "Bytes downloaded from the Factset SFTP server.",
+	}, []string{"product"})
+	downloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factset_uploader_download_duration_seconds",
+		Help:    "Time taken to download an archive from the Factset SFTP server.",
+		Buckets: durationBuckets,
+	}, []string{"product"})
+)
+
+func init() {
+	prometheus.MustRegister(downloadedBytes, downloadDuration)
+}
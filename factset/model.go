@@ -4,8 +4,8 @@ import "time"
 
 // PackageVersion - Factset package versioning is two parts
 type PackageVersion struct {
-	FeedVersion int
-	Sequence    int
+	FeedVersion int `json:"feedVersion"`
+	Sequence    int `json:"sequence"`
 }
 
 // Example file naming and package breakdown
@@ -25,11 +25,11 @@ type PackageVersion struct {
 
 // Package - represents a package from Factset
 type Package struct {
-	Dataset     string
-	FSPackage   string
-	Product     string
-	Bundle      string
-	FeedVersion int
+	Dataset     string `json:"dataset"`
+	FSPackage   string `json:"fsPackage"`
+	Product     string `json:"product"`
+	Bundle      string `json:"bundle"`
+	FeedVersion int    `json:"feedVersion"`
 }
 
 // PackageMetadata - extended package including versioning information
@@ -47,4 +47,7 @@ type FSFile struct {
 	Path    string
 	Version PackageVersion
 	IsFull  bool
+	Size    int64
+	// ModTime is when the file was last modified on the Factset server, i.e. when it was published
+	ModTime time.Time
 }
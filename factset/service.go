@@ -1,10 +1,12 @@
 package factset
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"path"
 	"regexp"
@@ -14,15 +16,16 @@ import (
 
 // Servicer - service interface to be able to mock for testing
 type Servicer interface {
-	GetSchemaInfo(pkg Package) (*PackageVersion, error)
-	GetLatestFile(pkg Package, isFull bool) (FSFile, error)
-	Download(file FSFile, product string) (*os.File, error)
+	GetSchemaInfo(ctx context.Context, pkg Package) (*PackageVersion, error)
+	GetLatestFile(ctx context.Context, pkg Package, isFull bool) (FSFile, error)
+	Download(ctx context.Context, file FSFile, dest string, product string) (*os.File, error)
+	Discover(ctx context.Context) ([]RemoteBundle, error)
+	CheckConnectivity(ctx context.Context) error
 }
 
 // Service - Factset service
 type Service struct {
 	client           sftpClienter
-	workspace        string
 	ftpServerBaseDir string
 }
 
@@ -30,7 +33,7 @@ var baseDir = "/datafeeds"
 var schemaDir = "/documents"
 
 // NewService - create a new Service(r)
-func NewService(sftpUser, sftpKey, sftpAddress string, sftpPort int, workspace string) (Servicer, error) {
+func NewService(sftpUser, sftpKey, sftpAddress string, sftpPort int) (Servicer, error) {
 
 	sftpClient, err := newSFTPClient(sftpUser, sftpKey, sftpAddress, sftpPort)
 	if err != nil {
@@ -39,15 +42,14 @@ func NewService(sftpUser, sftpKey, sftpAddress string, sftpPort int, workspace s
 
 	return &Service{
 		client:           sftpClient,
-		workspace:        workspace,
 		ftpServerBaseDir: baseDir,
 	}, nil
 }
 
 // GetSchemaInfo - Get the latest schema info from Factset
-func (s *Service) GetSchemaInfo(pkg Package) (*PackageVersion, error) {
+func (s *Service) GetSchemaInfo(ctx context.Context, pkg Package) (*PackageVersion, error) {
 	schemaDirectory := s.ftpServerBaseDir + schemaDir + fmt.Sprintf("/docs_%s/", pkg.Dataset)
-	files, err := s.client.ReadDir(schemaDirectory)
+	files, err := s.client.ReadDir(ctx, schemaDirectory)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error reading schema directory: %s", schemaDirectory)
 		return nil, err
@@ -88,7 +90,7 @@ func (s *Service) GetSchemaInfo(pkg Package) (*PackageVersion, error) {
 }
 
 // GetLatestFile - Get latest file for a package
-func (s *Service) GetLatestFile(pkg Package, isFull bool) (FSFile, error) {
+func (s *Service) GetLatestFile(ctx context.Context, pkg Package, isFull bool) (FSFile, error) {
 	var mostRecentDataArchive FSFile
 	var mostRecentFileName string
 	var fileType string
@@ -99,7 +101,7 @@ func (s *Service) GetLatestFile(pkg Package, isFull bool) (FSFile, error) {
 	}
 
 	fileDirectory := path.Join(s.ftpServerBaseDir, pkg.FSPackage, pkg.Product)
-	files, err := s.client.ReadDir(fileDirectory)
+	files, err := s.client.ReadDir(ctx, fileDirectory)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error reading: %s", fileDirectory)
 		return mostRecentDataArchive, err
@@ -128,17 +130,28 @@ func (s *Service) GetLatestFile(pkg Package, isFull bool) (FSFile, error) {
 	return mostRecentDataArchive, nil
 }
 
-// Download - downloads the file from Factset and provides a local file object
-func (s *Service) Download(file FSFile, product string) (*os.File, error) {
-	err := s.client.Download(file.Path, s.workspace, product)
+// CheckConnectivity - checks that the Factset server can be reached by listing the data feed directory
+func (s *Service) CheckConnectivity(ctx context.Context) error {
+	_, err := s.client.ReadDir(ctx, s.ftpServerBaseDir)
+	return err
+}
+
+// Download - downloads the file from Factset into the dest directory and provides a local file object
+func (s *Service) Download(ctx context.Context, file FSFile, dest string, product string) (*os.File, error) {
+	started := time.Now()
+	err := s.client.Download(ctx, file.Path, dest, product)
 	if err != nil {
 		return nil, err
 	}
-	localFile, err := os.Open(s.workspace + "/" + file.Name)
+	downloadDuration.WithLabelValues(product).Observe(time.Since(started).Seconds())
+	localFile, err := os.Open(path.Join(dest, file.Name))
 	if err != nil {
-		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not open file: %s", s.workspace+"/"+file.Name)
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not open file: %s", path.Join(dest, file.Name))
 		return nil, err
 	}
+	if info, err := localFile.Stat(); err == nil {
+		downloadedBytes.WithLabelValues(product).Add(float64(info.Size()))
+	}
 	return localFile, nil
 }
 
@@ -168,6 +181,8 @@ func filterAndExtractFileInfo(pkg Package, files []os.FileInfo, isFull bool) []F
 		// Get the filename from the path and then take off the bundle name so we've got a clean start point
 		name := file.Name()[strings.LastIndex(file.Name(), "/")+1:]
 		outFile.Name = name // Grab the name now before we chop it up.
+		outFile.Size = file.Size()
+		outFile.ModTime = file.ModTime()
 		name = name[:strings.LastIndex(file.Name(), ".")]
 		name = name[len(removeBundleMetadata(pkg.Bundle))+1:]
 
@@ -1,6 +1,7 @@
 package factset
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -92,8 +94,8 @@ func Test_GetSchemaInfo(t *testing.T) {
 				assert.Contains(t, err.Error(), d.readDirErr.Error(), fmt.Sprintf("Test: %s failed, mismatched error codes", d.testName))
 			} else {
 				assert.NoError(t, err, fmt.Sprintf("Test: %s failed, should read file with no error", d.testName))
-				fs := &Service{&MockSftpClient{files, d.readDirErr}, "", "../fixtures/datafeeds"}
-				pv, err := fs.GetSchemaInfo(pkg)
+				fs := &Service{&MockSftpClient{files, d.readDirErr}, "../fixtures/datafeeds"}
+				pv, err := fs.GetSchemaInfo(context.Background(), pkg)
 				if d.dataset == "emptyDir" || d.dataset == "missingSchema" {
 					assert.Error(t, err, d.schemaErr, fmt.Sprintf("Test: %s failed, directory is empty should should not read schema", d.testName))
 					assert.Contains(t, err.Error(), d.schemaErr.Error(), fmt.Sprintf("Test: %s failed, mismatched error codes", d.testName))
@@ -112,8 +114,8 @@ func Test_GetSchemaInfo_EmptyDir(t *testing.T) {
 	os.Mkdir(directory, 0700)
 	files, err := ioutil.ReadDir(directory)
 	assert.NoError(t, err, fmt.Sprintf("Test: %s failed, should read file with no error", "Error when directory has no files"))
-	fs := &Service{&MockSftpClient{files, nil}, "", "../fixtures/datafeeds"}
-	_, err = fs.GetSchemaInfo(pkg)
+	fs := &Service{&MockSftpClient{files, nil}, "../fixtures/datafeeds"}
+	_, err = fs.GetSchemaInfo(context.Background(), pkg)
 	assert.Error(t, err, "Test failed, directory should be empty")
 	assert.Contains(t, err.Error(), "No schema found in: ", "Test failed, unexpected error was returned")
 	defer os.Remove(directory)
@@ -220,8 +222,8 @@ func Test_GetLatestFile(t *testing.T) {
 				assert.Contains(t, err.Error(), d.readDirErr.Error(), fmt.Sprintf("Test: %s failed, mismatched error codes", d.testName))
 			} else {
 				assert.NoError(t, err, fmt.Sprintf("Test: %s failed, should read file with no error", d.testName))
-				fs := &Service{&MockSftpClient{files, d.readDirErr}, "", "../fixtures/datafeeds"}
-				fsFile, err := fs.GetLatestFile(d.testPackage, d.isFullLoad)
+				fs := &Service{&MockSftpClient{files, d.readDirErr}, "../fixtures/datafeeds"}
+				fsFile, err := fs.GetLatestFile(context.Background(), d.testPackage, d.isFullLoad)
 				if d.fileSuffix == "emptyDir" || d.fileSuffix == "nestedDirectory" {
 					assert.Error(t, err, d.schemaErr, fmt.Sprintf("Test: %s failed, directory is empty/nested should should not read file", d.testName))
 					assert.Contains(t, err.Error(), d.schemaErr.Error(), fmt.Sprintf("Test: %s failed, mismatched error codes", d.testName))
@@ -231,6 +233,7 @@ func Test_GetLatestFile(t *testing.T) {
 					assert.Equal(t, d.expectedPath, fsFile.Path, fmt.Sprintf("Test: %s failed, path does not match", d.testName))
 					assert.Equal(t, d.expectedFeedVersion, fsFile.Version.FeedVersion, fmt.Sprintf("Test: %s failed, did not extract latest feed version", d.testName))
 					assert.Equal(t, d.expectedSequence, fsFile.Version.Sequence, fmt.Sprintf("Test: %s failed, did not extract latest sequence", d.testName))
+					assert.True(t, fsFile.Size > 0, fmt.Sprintf("Test: %s failed, did not record the size of the remote file", d.testName))
 				}
 			}
 		})
@@ -242,8 +245,8 @@ func Test_GetLatestFile_EmptyDirectory(t *testing.T) {
 	os.Mkdir(directory, 0700)
 	files, err := ioutil.ReadDir(directory)
 	assert.NoError(t, err, fmt.Sprintf("Test: %s failed, should read file with no error", "Error when directory has no files"))
-	fs := &Service{&MockSftpClient{files, nil}, "", "../fixtures/datafeeds"}
-	_, err = fs.GetLatestFile(pkg, true)
+	fs := &Service{&MockSftpClient{files, nil}, "../fixtures/datafeeds"}
+	_, err = fs.GetLatestFile(context.Background(), pkg, true)
 	assert.Error(t, err, "Test failed, directory should be empty")
 	assert.Contains(t, err.Error(), "No data archives found in: ../fixtures/datafeeds/people/ppl_test", "Test failed, returned unexpected error")
 	defer os.Remove(directory)
@@ -255,13 +258,13 @@ func Test_GetLatestFile_NestedDirectory(t *testing.T) {
 	os.Mkdir(directory+"/evenMoreNestedDirectory", 0700)
 	files, err := ioutil.ReadDir(directory)
 	assert.NoError(t, err, fmt.Sprintf("Test: %s failed, should read file with no error", "Error when directory has no files"))
-	fs := &Service{&MockSftpClient{files, nil}, "", "../fixtures/datafeeds"}
+	fs := &Service{&MockSftpClient{files, nil}, "../fixtures/datafeeds"}
 	//Full load error
-	_, err = fs.GetLatestFile(pkg, true)
+	_, err = fs.GetLatestFile(context.Background(), pkg, true)
 	assert.Error(t, err, "Test failed, directory should be empty")
 	assert.Contains(t, err.Error(), "No valid Full files found in: ../fixtures/datafeeds/people/ppl_test", "Test failed, mismatched error codes")
 	//Delta load error
-	_, err = fs.GetLatestFile(pkg, false)
+	_, err = fs.GetLatestFile(context.Background(), pkg, false)
 	assert.Error(t, err, "Test failed, directory should be empty")
 	assert.Contains(t, err.Error(), "No valid Delta files found in: ../fixtures/datafeeds/people/ppl_test", "Test failed, mismatched error codes")
 	defer os.RemoveAll(directory)
@@ -288,13 +291,18 @@ func Test_Download(t *testing.T) {
 	for _, d := range testCases {
 		t.Run(d.testName, func(t *testing.T) {
 			ftpFile := FSFile{Name: "ppl_test_v1_full_1234.zip", Path: "../fixtures/datafeeds/people/ppl_test/ppl_singleZip", Version: PackageVersion{FeedVersion: 1, Sequence: 1234}, IsFull: true}
-			fs := &Service{&MockSftpClient{err: d.expectedError}, ".", "../fixtures/datafeeds"}
-			fsFile, err := fs.Download(ftpFile, "ppl_test")
+			fs := &Service{&MockSftpClient{err: d.expectedError}, "../fixtures/datafeeds"}
+			bytesBefore := testutil.ToFloat64(downloadedBytes.WithLabelValues("ppl_test"))
+			fsFile, err := fs.Download(context.Background(), ftpFile, ".", "ppl_test")
 			if d.expectedError != nil {
 				assert.Error(t, err, fmt.Sprintf("Test: %s failed, error whilst downloading/copying file to current directory", d.testName))
+				assert.Equal(t, bytesBefore, testutil.ToFloat64(downloadedBytes.WithLabelValues("ppl_test")), fmt.Sprintf("Test: %s failed, failed download should not be counted", d.testName))
 			} else {
 				assert.NotNil(t, fsFile, fmt.Sprintf("Test: %s failed, file should exist in current directory", d.testName))
 				assert.Contains(t, fsFile.Name(), "ppl_test_v1_full_1234.zip", fmt.Sprintf("Test: %s failed, file name does not match expected", d.testName))
+				info, err := fsFile.Stat()
+				assert.NoError(t, err)
+				assert.Equal(t, bytesBefore+float64(info.Size()), testutil.ToFloat64(downloadedBytes.WithLabelValues("ppl_test")), fmt.Sprintf("Test: %s failed, downloaded bytes not counted", d.testName))
 			}
 			defer fs.client.Close()
 		})
@@ -306,11 +314,11 @@ type MockSftpClient struct {
 	err   error
 }
 
-func (m *MockSftpClient) ReadDir(dir string) ([]os.FileInfo, error) {
+func (m *MockSftpClient) ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
 	return m.files, m.err
 }
 
-func (m *MockSftpClient) Download(path string, dest string, product string) error {
+func (m *MockSftpClient) Download(ctx context.Context, path string, dest string, product string) error {
 	if m.err == nil {
 		m.err = copyFile(path)
 	}
@@ -1,6 +1,7 @@
 package factset
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -18,8 +19,8 @@ type sftpClient struct {
 }
 
 type sftpClienter interface {
-	ReadDir(dir string) ([]os.FileInfo, error)
-	Download(path string, dest string, product string) error
+	ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error)
+	Download(ctx context.Context, path string, dest string, product string) error
 	Close() error
 }
 
@@ -65,22 +66,28 @@ func newSFTPClient(user, key, address string, port int) (*sftpClient, error) {
 	}, nil
 }
 
-func (s *sftpClient) ReadDir(dir string) ([]os.FileInfo, error) {
+func (s *sftpClient) ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return s.sftp.ReadDir(dir)
 }
 
-func (s *sftpClient) Download(path string, dest string, product string) error {
+func (s *sftpClient) Download(ctx context.Context, path string, dest string, product string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	file, err := s.sftp.Open(path)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not open %s on sftp server", path)
 		return err
 	}
 	defer file.Close()
-	return s.save(file, dest, product)
+	return s.save(ctx, file, dest, product)
 }
 
 //TODO nice to have, a progress bar of download
-func (s *sftpClient) save(file *sftp.File, dest string, product string) error {
+func (s *sftpClient) save(ctx context.Context, file *sftp.File, dest string, product string) error {
 	_, fileName := path.Split(file.Name())
 	downFile, err := os.Create(path.Join(dest, fileName))
 	if err != nil {
@@ -97,7 +104,14 @@ func (s *sftpClient) save(file *sftp.File, dest string, product string) error {
 	size := fileStat.Size()
 
 	log.WithFields(log.Fields{"fs_product": product}).Infof("Downloading %s from sftp server", fileName)
-	n, err := io.Copy(downFile, io.LimitReader(file, size))
+	n, err := io.Copy(downFile, io.LimitReader(contextReader{ctx, file}, size))
+	if err != nil && ctx.Err() != nil {
+		// A partial archive must not be mistaken for a complete one by a later run
+		log.WithFields(log.Fields{"fs_product": product}).Warnf("Download of %s interrupted at [%d] of [%d] bytes", fileName, n, size)
+		downFile.Close()
+		os.Remove(path.Join(dest, fileName))
+		return err
+	}
 	if n != size || err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Download stopped at [%d] when copying sftp file to %s/%s", n, dest, fileName)
 		return err
@@ -106,6 +120,19 @@ func (s *sftpClient) save(file *sftp.File, dest string, product string) error {
 	return nil
 }
 
+// contextReader stops a copy at the next read once its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
 func (s *sftpClient) Close() error {
 	if s.sftp != nil {
 		if err := s.sftp.Close(); err != nil {
@@ -0,0 +1,254 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/loader"
+	"github.com/Financial-Times/factset-uploader/rds"
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/service-status-go/gtg"
+	status "github.com/Financial-Times/service-status-go/httphandlers"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultStatusInterval - how often the status of the SFTP server and of each package is refreshed if the config
+// does not say
+const DefaultStatusInterval = 5 * time.Minute
+
+// Config - how the service describes itself in its health checks, how long newly published data may wait to be
+// loaded before the package is reported as stale, and how often the status of the SFTP server and of each package
+// is refreshed
+type Config struct {
+	SystemCode     string
+	Name           string
+	Description    string
+	Packages       []factset.Package
+	MaxStaleness   time.Duration
+	StatusInterval time.Duration
+}
+
+type connectivityChecker interface {
+	CheckConnectivity(ctx context.Context) error
+}
+
+type writableChecker interface {
+	CheckWritable() error
+}
+
+type packageStatuser interface {
+	PackageStatus(ctx context.Context, pkg factset.Package) loader.PackageStatus
+}
+
+// Service - the health checks of the uploader
+type Service struct {
+	config    Config
+	factset   connectivityChecker
+	db        connectivityChecker
+	workspace writableChecker
+	loader    packageStatuser
+	now       func() time.Time
+
+	// status is refreshed in the background by Watch, so requests to the health check and good-to-go endpoints do
+	// not list the SFTP server or query the database for every package
+	status struct {
+		sync.RWMutex
+		checked  time.Time
+		sftpErr  error
+		packages map[factset.Package]loader.PackageStatus
+	}
+}
+
+// NewService - creates the health checks of the uploader's dependencies and of each configured package
+func NewService(config Config, factsetService factset.Servicer, db *rds.Client, workspace *loader.Workspace, loaderService *loader.Service) *Service {
+	return &Service{
+		config:    config,
+		factset:   factsetService,
+		db:        db,
+		workspace: workspace,
+		loader:    loaderService,
+		now:       time.Now,
+	}
+}
+
+// Handler - serves __health, __gtg, __build-info and __ping, the Prometheus metrics on /metrics and the pprof profiles
+// under /debug/pprof/
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__health", fthealth.Handler(s.HealthCheck()))
+	mux.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(gtg.StatusChecker(s.GTG)))
+	mux.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
+	mux.HandleFunc(status.PingPath, status.PingHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Watch - refreshes the status of the SFTP server and of each package straight away and then every StatusInterval,
+// until ctx is done
+func (s *Service) Watch(ctx context.Context) {
+	interval := s.config.StatusInterval
+	if interval <= 0 {
+		interval = DefaultStatusInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh - checks the SFTP server can be reached and compares the loaded version of each package with Factset,
+// keeping the results for the health checks
+func (s *Service) Refresh(ctx context.Context) {
+	sftpErr := s.factset.CheckConnectivity(ctx)
+	packages := make(map[factset.Package]loader.PackageStatus)
+	for _, pkg := range s.config.Packages {
+		packages[pkg] = s.loader.PackageStatus(ctx, pkg)
+	}
+
+	s.status.Lock()
+	defer s.status.Unlock()
+	s.status.checked = s.now()
+	s.status.sftpErr = sftpErr
+	s.status.packages = packages
+}
+
+// HealthCheck - the checks of the SFTP server, the database, the workspace and the freshness of each package
+func (s *Service) HealthCheck() fthealth.HealthCheck {
+	checks := []fthealth.Check{s.sftpCheck(), s.dbCheck(), s.workspaceCheck()}
+	for _, pkg := range s.config.Packages {
+		checks = append(checks, s.freshnessCheck(pkg))
+	}
+	return fthealth.HealthCheck{
+		SystemCode:  s.config.SystemCode,
+		Name:        s.config.Name,
+		Description: s.config.Description,
+		Checks:      checks,
+	}
+}
+
+// GTG - the uploader is good to go when it could reach the SFTP server when last refreshed, and can reach the
+// database and write to its workspace
+func (s *Service) GTG() gtg.Status {
+	for _, check := range []fthealth.Check{s.sftpCheck(), s.dbCheck(), s.workspaceCheck()} {
+		if _, err := check.Checker(); err != nil {
+			return gtg.Status{GoodToGo: false, Message: err.Error()}
+		}
+	}
+	return gtg.Status{GoodToGo: true}
+}
+
+func (s *Service) panicGuide() string {
+	return fmt.Sprintf("https://dewey.ft.com/%s.html", s.config.SystemCode)
+}
+
+func (s *Service) sftpCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "factset-sftp",
+		BusinessImpact:   "New FactSet data can not be downloaded, so the data in the database will become stale",
+		Name:             "Factset SFTP server is reachable",
+		PanicGuide:       s.panicGuide(),
+		Severity:         1,
+		TechnicalSummary: "The data feed directory on the Factset SFTP server can not be listed. Check the server address, credentials and that our IP address is still whitelisted",
+		Checker: func() (string, error) {
+			s.status.RLock()
+			defer s.status.RUnlock()
+			if s.status.checked.IsZero() {
+				return "Factset SFTP server has not been checked yet", nil
+			}
+			if s.status.sftpErr != nil {
+				return "", fmt.Errorf("the Factset SFTP server was unreachable at %s: %s", s.status.checked.Format(time.RFC3339), s.status.sftpErr)
+			}
+			return "Factset SFTP server is reachable", nil
+		},
+	}
+}
+
+func (s *Service) dbCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "rds",
+		BusinessImpact:   "FactSet data can not be loaded into the database",
+		Name:             "Database is reachable",
+		PanicGuide:       s.panicGuide(),
+		Severity:         1,
+		TechnicalSummary: "The database can not be queried. Check the RDS instance and the DSN",
+		Checker: func() (string, error) {
+			if err := s.db.CheckConnectivity(context.Background()); err != nil {
+				return "", fmt.Errorf("the database is unreachable: %s", err)
+			}
+			return "Database is reachable", nil
+		},
+	}
+}
+
+func (s *Service) workspaceCheck() fthealth.Check {
+	return fthealth.Check{
+		ID:               "workspace",
+		BusinessImpact:   "FactSet archives can not be downloaded and extracted, so no data can be loaded",
+		Name:             "Workspace is writable",
+		PanicGuide:       s.panicGuide(),
+		Severity:         2,
+		TechnicalSummary: "A file can not be written to the workspace. Check the volume is mounted, writable and has space",
+		Checker: func() (string, error) {
+			if err := s.workspace.CheckWritable(); err != nil {
+				return "", fmt.Errorf("the workspace is not writable: %s", err)
+			}
+			return "Workspace is writable", nil
+		},
+	}
+}
+
+func (s *Service) freshnessCheck(pkg factset.Package) fthealth.Check {
+	return fthealth.Check{
+		ID:               fmt.Sprintf("data-freshness-%s-%s", pkg.Product, pkg.Bundle),
+		BusinessImpact:   fmt.Sprintf("The %s data in the database is out of date", pkg.Product),
+		Name:             fmt.Sprintf("%s data is up to date", pkg.Product),
+		PanicGuide:       s.panicGuide(),
+		Severity:         2,
+		TechnicalSummary: fmt.Sprintf("The latest %s archive published by FactSet has not been loaded within %s of being published. Check the load history for errors", pkg.Product, s.config.MaxStaleness),
+		Checker: func() (string, error) {
+			return s.checkFreshness(pkg)
+		},
+	}
+}
+
+func (s *Service) checkFreshness(pkg factset.Package) (string, error) {
+	s.status.RLock()
+	st, ok := s.status.packages[pkg]
+	s.status.RUnlock()
+	if !ok {
+		return fmt.Sprintf("%s has not been checked yet", pkg.Product), nil
+	}
+	switch st.Status {
+	case loader.StatusError:
+		return "", fmt.Errorf("could not compare the loaded version of %s with Factset: %s", pkg.Product, st.Error)
+	case loader.StatusNotLoaded:
+		return "", fmt.Errorf("%s has not been loaded", pkg.Product)
+	case loader.StatusUpToDate:
+		return fmt.Sprintf("%s is up to date at v%d_%d", pkg.Product, st.LoadedData.FeedVersion, st.LoadedData.Sequence), nil
+	}
+
+	if st.SequencesBehind == 0 {
+		return fmt.Sprintf("%s data is up to date at v%d_%d but its schema is %d sequences behind", pkg.Product, st.LoadedData.FeedVersion, st.LoadedData.Sequence, st.SchemaSequencesBehind), nil
+	}
+	waiting := s.now().Sub(st.Published)
+	if !st.Published.IsZero() && waiting > s.config.MaxStaleness {
+		return "", fmt.Errorf("%s is %d sequences behind, %s was published %s ago", pkg.Product, st.SequencesBehind, st.LatestArchive, waiting-waiting%time.Minute)
+	}
+	return fmt.Sprintf("%s is %d sequences behind, within %s of the latest being published", pkg.Product, st.SequencesBehind, s.config.MaxStaleness), nil
+}
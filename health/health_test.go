@@ -0,0 +1,224 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPkg = factset.Package{Dataset: "ppl", FSPackage: "people", Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 1}
+
+var testNow = time.Date(2018, time.January, 10, 12, 0, 0, 0, time.UTC)
+
+type fakeChecker struct {
+	err   error
+	calls int
+}
+
+func (f *fakeChecker) CheckConnectivity(ctx context.Context) error {
+	f.calls++
+	return f.err
+}
+
+func (f *fakeChecker) CheckWritable() error {
+	f.calls++
+	return f.err
+}
+
+type fakeStatuser struct {
+	status loader.PackageStatus
+	calls  int
+}
+
+func (f *fakeStatuser) PackageStatus(ctx context.Context, pkg factset.Package) loader.PackageStatus {
+	f.calls++
+	return f.status
+}
+
+// newTestService - a service whose status has been refreshed once
+func newTestService(sftpErr, dbErr, workspaceErr error, status loader.PackageStatus) *Service {
+	s := newUncheckedTestService(sftpErr, dbErr, workspaceErr, status)
+	s.Refresh(context.Background())
+	return s
+}
+
+func newUncheckedTestService(sftpErr, dbErr, workspaceErr error, status loader.PackageStatus) *Service {
+	return &Service{
+		config: Config{
+			SystemCode:   "factset-uploader",
+			Name:         "factset-uploader",
+			Description:  "Loads FactSet data",
+			Packages:     []factset.Package{testPkg},
+			MaxStaleness: 36 * time.Hour,
+		},
+		factset:   &fakeChecker{err: sftpErr},
+		db:        &fakeChecker{err: dbErr},
+		workspace: &fakeChecker{err: workspaceErr},
+		loader:    &fakeStatuser{status: status},
+		now:       func() time.Time { return testNow },
+	}
+}
+
+func TestCheckFreshness(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   loader.PackageStatus
+		expected string
+		healthy  bool
+	}{
+		{
+			name:     "UpToDate",
+			status:   loader.PackageStatus{Status: loader.StatusUpToDate, LoadedData: factset.PackageVersion{FeedVersion: 1, Sequence: 12}},
+			expected: "ppl_premium is up to date at v1_12",
+			healthy:  true,
+		},
+		{
+			name:     "RecentlyPublished",
+			status:   loader.PackageStatus{Status: loader.StatusBehind, SequencesBehind: 1, Published: testNow.Add(-2 * time.Hour)},
+			expected: "ppl_premium is 1 sequences behind, within 36h0m0s of the latest being published",
+			healthy:  true,
+		},
+		{
+			name:     "SchemaBehind",
+			status:   loader.PackageStatus{Status: loader.StatusBehind, SchemaSequencesBehind: 1, LoadedData: factset.PackageVersion{FeedVersion: 1, Sequence: 12}},
+			expected: "ppl_premium data is up to date at v1_12 but its schema is 1 sequences behind",
+			healthy:  true,
+		},
+		{
+			name:     "Stale",
+			status:   loader.PackageStatus{Status: loader.StatusBehind, SequencesBehind: 3, LatestArchive: "ppl_premium_v1_full_15.zip", Published: testNow.Add(-50*time.Hour - 30*time.Second)},
+			expected: "ppl_premium is 3 sequences behind, ppl_premium_v1_full_15.zip was published 50h0m0s ago",
+		},
+		{
+			name:     "NotLoaded",
+			status:   loader.PackageStatus{Status: loader.StatusNotLoaded},
+			expected: "ppl_premium has not been loaded",
+		},
+		{
+			name:     "Error",
+			status:   loader.PackageStatus{Status: loader.StatusError, Error: "connection refused"},
+			expected: "could not compare the loaded version of ppl_premium with Factset: connection refused",
+		},
+	}
+	for _, d := range testCases {
+		service := newTestService(nil, nil, nil, d.status)
+		output, err := service.checkFreshness(testPkg)
+		if d.healthy {
+			assert.NoError(t, err, "Test %s failed, unexpected error", d.name)
+			assert.Equal(t, d.expected, output, "Test %s failed, wrong output", d.name)
+		} else if assert.Error(t, err, "Test %s failed, expected an error", d.name) {
+			assert.Equal(t, d.expected, err.Error(), "Test %s failed, wrong error", d.name)
+		}
+	}
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	service := newTestService(nil, errors.New("connection refused"), nil, loader.PackageStatus{Status: loader.StatusUpToDate})
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/__health")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var result struct {
+		SystemCode string `json:"systemCode"`
+		Ok         bool   `json:"ok"`
+		Checks     []struct {
+			ID string `json:"id"`
+			Ok bool   `json:"ok"`
+		} `json:"checks"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "factset-uploader", result.SystemCode)
+	assert.False(t, result.Ok)
+	ok := make(map[string]bool)
+	for _, c := range result.Checks {
+		ok[c.ID] = c.Ok
+	}
+	assert.Equal(t, map[string]bool{
+		"factset-sftp":                           true,
+		"rds":                                    false,
+		"workspace":                              true,
+		"data-freshness-ppl_premium-ppl_premium": true,
+	}, ok)
+}
+
+func TestGTGEndpoint(t *testing.T) {
+	testCases := []struct {
+		name         string
+		sftpErr      error
+		dbErr        error
+		workspaceErr error
+		expectedCode int
+	}{
+		{"GoodToGo", nil, nil, nil, http.StatusOK},
+		{"SFTPUnreachable", errors.New("timeout"), nil, nil, http.StatusServiceUnavailable},
+		{"DBUnreachable", nil, errors.New("connection refused"), nil, http.StatusServiceUnavailable},
+		{"WorkspaceReadOnly", nil, nil, errors.New("read-only file system"), http.StatusServiceUnavailable},
+	}
+	for _, d := range testCases {
+		// data freshness does not affect good to go
+		service := newTestService(d.sftpErr, d.dbErr, d.workspaceErr, loader.PackageStatus{Status: loader.StatusNotLoaded})
+		req := httptest.NewRequest("GET", "/__gtg", nil)
+		w := httptest.NewRecorder()
+		service.Handler().ServeHTTP(w, req)
+		assert.Equal(t, d.expectedCode, w.Code, "Test %s failed, wrong status code", d.name)
+	}
+}
+
+func TestChecksReportRefreshedStatus(t *testing.T) {
+	service := newUncheckedTestService(errors.New("timeout"), nil, nil, loader.PackageStatus{Status: loader.StatusNotLoaded})
+	sftp := service.factset.(*fakeChecker)
+	statuser := service.loader.(*fakeStatuser)
+
+	output, err := service.checkFreshness(testPkg)
+	assert.NoError(t, err, "A package should not be unhealthy before its status is checked")
+	assert.Equal(t, "ppl_premium has not been checked yet", output)
+	assert.True(t, service.GTG().GoodToGo, "The SFTP server should not fail good to go before it is checked")
+
+	service.Refresh(context.Background())
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/__health", "/__gtg"} {
+			w := httptest.NewRecorder()
+			service.Handler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		}
+	}
+	assert.Equal(t, 1, sftp.calls, "The SFTP server should only be checked by Refresh")
+	assert.Equal(t, 1, statuser.calls, "Package status should only be checked by Refresh")
+	assert.False(t, service.GTG().GoodToGo, "The SFTP server was unreachable when last checked")
+	_, err = service.checkFreshness(testPkg)
+	assert.Error(t, err, "The refreshed status should be reported")
+}
+
+func TestWatchRefreshesStatus(t *testing.T) {
+	service := newUncheckedTestService(nil, nil, nil, loader.PackageStatus{Status: loader.StatusUpToDate})
+	service.config.StatusInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Watch(ctx)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+	assert.True(t, service.loader.(*fakeStatuser).calls > 1, "Status should be refreshed on every interval")
+}
+
+func TestBuildInfoMetricsAndPprofEndpoints(t *testing.T) {
+	service := newTestService(nil, nil, nil, loader.PackageStatus{})
+	for _, path := range []string{"/__build-info", "/__ping", "/metrics", "/debug/pprof/"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		service.Handler().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, "Test %s failed, wrong status code", path)
+	}
+}
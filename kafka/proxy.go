@@ -0,0 +1,87 @@
+// Package kafka publishes load events to a Kafka topic through a Kafka REST proxy.
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/loader"
+)
+
+const contentType = "application/vnd.kafka.json.v2+json"
+
+// ProxyPublisher - publishes each loader.LoadedEvent as a JSON message to a topic through the v2 API of a Kafka
+// REST proxy. Messages are keyed by product and bundle so the events of a package stay in order.
+type ProxyPublisher struct {
+	topicURL string
+	client   *http.Client
+}
+
+// NewProxyPublisher - creates a publisher to the topic through the REST proxy at proxyURL
+func NewProxyPublisher(proxyURL, topic string) *ProxyPublisher {
+	return &ProxyPublisher{
+		topicURL: strings.TrimRight(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type produceRequest struct {
+	Records []record `json:"records"`
+}
+
+type record struct {
+	Key   string             `json:"key"`
+	Value loader.LoadedEvent `json:"value"`
+}
+
+type produceResponse struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode int    `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish - produces the event to the topic, failing if the proxy rejects the request or could not write the message
+func (p *ProxyPublisher) Publish(event loader.LoadedEvent) error {
+	key := event.Package.Product + "/" + event.Package.Bundle
+	contents, err := json.Marshal(produceRequest{Records: []record{{Key: key, Value: event}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", p.topicURL, bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka proxy responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var produced produceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("could not read kafka proxy response: %s", err)
+	}
+	for _, offset := range produced.Offsets {
+		if offset.Error != "" || offset.ErrorCode != 0 {
+			return fmt.Errorf("kafka proxy could not write message for %s: %s (error code %d)", key, offset.Error, offset.ErrorCode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = loader.LoadedEvent{
+	RunID:         "run-20180110T120000-1",
+	Package:       factset.Package{Dataset: "ppl", FSPackage: "people", Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 1},
+	SchemaVersion: factset.PackageVersion{FeedVersion: 1, Sequence: 2},
+	DataVersion:   factset.PackageVersion{FeedVersion: 1, Sequence: 1234},
+	Archive:       "ppl_premium_v1_full_1234.zip",
+	Tables:        []loader.LoadedTable{{Table: "ppl_names", Rows: 5}, {Table: "ppl_jobs", Rows: 7}},
+	Rows:          12,
+	Loaded:        time.Date(2018, time.January, 10, 12, 0, 0, 0, time.UTC),
+}
+
+func TestPublish(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        int
+		response      string
+		expectedError string
+	}{
+		{
+			name:     "Produced",
+			status:   http.StatusOK,
+			response: `{"key_schema_id":null,"value_schema_id":null,"offsets":[{"partition":2,"offset":100,"error_code":null,"error":null}]}`,
+		},
+		{
+			name:          "MessageNotWritten",
+			status:        http.StatusOK,
+			response:      `{"offsets":[{"partition":null,"offset":null,"error_code":50003,"error":"Leader not available"}]}`,
+			expectedError: "kafka proxy could not write message for ppl_premium/ppl_premium: Leader not available (error code 50003)",
+		},
+		{
+			name:          "TopicNotFound",
+			status:        http.StatusNotFound,
+			response:      `{"error_code":40401,"message":"Topic not found."}`,
+			expectedError: `kafka proxy responded 404 Not Found: {"error_code":40401,"message":"Topic not found."}`,
+		},
+	}
+	for _, d := range testCases {
+		var method, path, contentType string
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			method = req.Method
+			path = req.URL.Path
+			contentType = req.Header.Get("Content-Type")
+			body, _ = ioutil.ReadAll(req.Body)
+			w.WriteHeader(d.status)
+			w.Write([]byte(d.response))
+		}))
+
+		err := NewProxyPublisher(server.URL+"/", "FactsetLoadedEvents").Publish(testEvent)
+		server.Close()
+		if d.expectedError == "" {
+			assert.NoError(t, err, "Test %s failed, unexpected error", d.name)
+		} else if assert.Error(t, err, "Test %s failed, expected an error", d.name) {
+			assert.Equal(t, d.expectedError, err.Error(), "Test %s failed, wrong error", d.name)
+		}
+
+		assert.Equal(t, "POST", method, "Test %s failed, wrong method", d.name)
+		assert.Equal(t, "/topics/FactsetLoadedEvents", path, "Test %s failed, wrong path", d.name)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", contentType, "Test %s failed, wrong content type", d.name)
+		var produced struct {
+			Records []struct {
+				Key   string             `json:"key"`
+				Value loader.LoadedEvent `json:"value"`
+			} `json:"records"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &produced), "Test %s failed, invalid request body", d.name)
+		if assert.Len(t, produced.Records, 1, "Test %s failed, wrong number of records", d.name) {
+			assert.Equal(t, "ppl_premium/ppl_premium", produced.Records[0].Key, "Test %s failed, wrong key", d.name)
+			assert.Equal(t, testEvent, produced.Records[0].Value, "Test %s failed, wrong event", d.name)
+		}
+	}
+}
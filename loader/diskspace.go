@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// workspaceReserveBytes - space that is always kept free in the workspace on top of what a file needs
+var workspaceReserveBytes uint64 = 100 << 20
+
+// freeSpace is swapped out in tests
+var freeSpace = availableBytes
+
+// InsufficientSpaceError - returned when the workspace does not have room for a download or an archive's contents
+type InsufficientSpaceError struct {
+	Workspace string
+	Needed    uint64
+	Available uint64
+	Reason    string
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("insufficient space in workspace %s to %s: need %d bytes (including %d reserved) but only %d available",
+		e.Workspace, e.Reason, e.Needed, workspaceReserveBytes, e.Available)
+}
+
+func isInsufficientSpace(err error) bool {
+	_, ok := err.(*InsufficientSpaceError)
+	return ok
+}
+
+// ensureSpace checks that the workspace can hold the given number of bytes plus the reserve.
+// If the free space can not be determined the check is skipped rather than blocking the load.
+func (s *Service) ensureSpace(bytes uint64, reason string, product string) error {
+	dir := s.run.Dir()
+	available, err := freeSpace(dir)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Warnf("Could not determine free space in workspace %s; skipping space check", dir)
+		return nil
+	}
+	needed := bytes + workspaceReserveBytes
+	if needed > available {
+		return &InsufficientSpaceError{
+			Workspace: dir,
+			Needed:    needed,
+			Available: available,
+			Reason:    reason,
+		}
+	}
+	log.WithFields(log.Fields{"fs_product": product}).Debugf("Workspace has %d bytes free, %d needed to %s", available, needed, reason)
+	return nil
+}
+
+// download checks the remote file will fit in the workspace before downloading it
+func (s *Service) download(ctx context.Context, file factset.FSFile, product string) (*os.File, error) {
+	if file.Size > 0 {
+		if err := s.ensureSpace(uint64(file.Size), "download "+file.Name, product); err != nil {
+			log.WithFields(log.Fields{"fs_product": product}).Error(err)
+			return nil, err
+		}
+	}
+	s.run.Track(filepath.Join(s.run.Dir(), file.Name))
+	return s.factset.Download(ctx, file, s.run.Dir(), product)
+}
+
+func declaredUncompressedSize(files []*zip.File) uint64 {
+	var total uint64
+	for _, f := range files {
+		total += f.UncompressedSize64
+	}
+	return total
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package loader
+
+import "syscall"
+
+// availableBytes - space available to unprivileged users on the filesystem holding path
+func availableBytes(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
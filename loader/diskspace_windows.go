@@ -0,0 +1,8 @@
+package loader
+
+import "errors"
+
+// availableBytes - free space reporting is not supported on windows so the space checks are skipped
+func availableBytes(path string) (uint64, error) {
+	return 0, errors.New("free space reporting is not supported on windows")
+}
@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileFormatSetting - how the data files of a package, or a single data file, should be read
+type FileFormatSetting struct {
+	// Detect works the format out from the head of each file before the options are applied
+	Detect  bool
+	options []func(*rds.FileFormat)
+}
+
+var fileFormatOptions = map[string]map[string]func(*rds.FileFormat){
+	"delimiter": {
+		"pipe":  func(f *rds.FileFormat) { f.Delimiter = '|' },
+		"tab":   func(f *rds.FileFormat) { f.Delimiter = '\t' },
+		"comma": func(f *rds.FileFormat) { f.Delimiter = ',' },
+	},
+	"lines": {
+		"crlf": func(f *rds.FileFormat) { f.LineEnding = "\r\n" },
+		"lf":   func(f *rds.FileFormat) { f.LineEnding = "\n" },
+		"cr":   func(f *rds.FileFormat) { f.LineEnding = "\r" },
+	},
+	"encoding": {
+		rds.EncodingUTF8:    func(f *rds.FileFormat) { f.Encoding = rds.EncodingUTF8 },
+		rds.EncodingUTF16LE: func(f *rds.FileFormat) { f.Encoding = rds.EncodingUTF16LE },
+		rds.EncodingUTF16BE: func(f *rds.FileFormat) { f.Encoding = rds.EncodingUTF16BE },
+		rds.EncodingLatin1:  func(f *rds.FileFormat) { f.Encoding = rds.EncodingLatin1 },
+	},
+	"header": {
+		"true":  func(f *rds.FileFormat) { f.Header = true },
+		"false": func(f *rds.FileFormat) { f.Header = false },
+	},
+}
+
+// ParseFileFormats - parses file format settings of the form name=option,...;name=option,... where name is a
+// product, to apply to all its data files, or a table, to apply to its data file alone. An option is either
+// auto, to detect the format, or one of delimiter:pipe|tab|comma, lines:crlf|lf|cr,
+// encoding:utf-8|utf-16le|utf-16be|latin-1 and header:true|false.
+func ParseFileFormats(spec string) (map[string]FileFormatSetting, error) {
+	settings := make(map[string]FileFormatSetting)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("file format %q should be of the form name=option,...", entry)
+		}
+
+		var setting FileFormatSetting
+		for _, option := range strings.Split(parts[1], ",") {
+			option = strings.ToLower(strings.TrimSpace(option))
+			if option == "auto" {
+				setting.Detect = true
+				continue
+			}
+			kv := strings.SplitN(option, ":", 2)
+			if len(kv) != 2 || fileFormatOptions[kv[0]] == nil {
+				return nil, fmt.Errorf("unknown option %q in file format for %s", option, name)
+			}
+			apply, ok := fileFormatOptions[kv[0]][kv[1]]
+			if !ok {
+				return nil, fmt.Errorf("unsupported %s %q in file format for %s", kv[0], kv[1], name)
+			}
+			setting.options = append(setting.options, apply)
+		}
+		settings[name] = setting
+	}
+	return settings, nil
+}
+
+// fileFormat works out the format of a data file from the setting for its table, or failing that its product
+func (s *Service) fileFormat(pkg factset.Package, file, tableName string) (rds.FileFormat, error) {
+	setting, ok := s.config.fileFormats[tableName]
+	if !ok {
+		setting = s.config.fileFormats[pkg.Product]
+	}
+
+	format := rds.DefaultFileFormat
+	if setting.Detect {
+		detected, err := rds.DetectFileFormat(file)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Could not detect format of data file %s", file)
+			return format, err
+		}
+		format = detected
+	}
+	for _, apply := range setting.options {
+		apply(&format)
+	}
+	log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Reading data file %s with %s", file, format)
+	return format, nil
+}
@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFileFormats(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		spec          string
+		expectedNames []string
+		expectedError string
+	}{
+		{"Empty", "", nil, ""},
+		{"Product and table settings", "ref_v2=auto;ref_countries=lines:lf,encoding:latin-1,header:false;", []string{"ref_countries", "ref_v2"}, ""},
+		{"Missing options", "ref_v2", nil, "should be of the form"},
+		{"Unknown option", "ref_v2=quote:single", nil, "unknown option"},
+		{"Unsupported value", "ref_v2=encoding:utf-32", nil, "unsupported encoding"},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			settings, err := ParseFileFormats(d.spec)
+			if d.expectedError != "" {
+				assert.Error(t, err, "Test %s failed, should have resulted in an error", d.testName)
+				assert.Contains(t, err.Error(), d.expectedError, "Test %s failed, returned unexpected error", d.testName)
+				return
+			}
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			var names []string
+			for name := range settings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, d.expectedNames, names, "Test %s failed, unexpected settings", d.testName)
+		})
+	}
+}
+
+func Test_FileFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factset-fileformat")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ref_countries.txt")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("ISO_CODE\tNAME\nGB\tUnited Kingdom\n"), 0644))
+
+	pkg := factset.Package{Product: "ref_v2"}
+	testCases := []struct {
+		testName string
+		spec     string
+		expected rds.FileFormat
+	}{
+		{"Defaults to the FactSet format", "", rds.DefaultFileFormat},
+		{"Product setting applies to its files", "ref_v2=delimiter:tab,lines:lf", rds.FileFormat{Delimiter: '\t', LineEnding: "\n", Encoding: rds.EncodingUTF8, Header: true}},
+		{"Table setting wins over product setting", "ref_v2=delimiter:tab;ref_countries=header:false", rds.FileFormat{Delimiter: '|', LineEnding: "\r\n", Encoding: rds.EncodingUTF8, Header: false}},
+		{"Detects the format", "ref_v2=auto", rds.FileFormat{Delimiter: '\t', LineEnding: "\n", Encoding: rds.EncodingUTF8, Header: true}},
+		{"Options override what is detected", "ref_v2=auto,encoding:latin-1,header:false", rds.FileFormat{Delimiter: '\t', LineEnding: "\n", Encoding: rds.EncodingLatin1, Header: false}},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			settings, err := ParseFileFormats(d.spec)
+			assert.NoError(t, err)
+			config := Config{packages: []factset.Package{pkg}}
+			config.SetFileFormats(settings)
+			loader := NewService(config, newMemoryStore(), nil, nil)
+
+			format, err := loader.fileFormat(pkg, file, "ref_countries")
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			assert.Equal(t, d.expected, format, "Test %s failed, unexpected format %s", d.testName, format)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"context"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// packageLoad - what happened whilst loading a package, for its load history
+type packageLoad struct {
+	archive  string
+	version  factset.PackageVersion
+	rows     int64
+	upToDate bool
+	// stage is the stage the load has reached, which is the stage it failed at if it fails
+	stage string
+	// interrupted is set when the load stopped because the uploader is shutting down
+	interrupted  bool
+	schemaChange string
+	before       factset.PackageMetadata
+	after        factset.PackageMetadata
+	tables       []TableResult
+}
+
+// recordTable adds the outcome of loading a table to the package's result
+func (l *packageLoad) recordTable(tableName string, rows int64, started time.Time, err error) {
+	table := TableResult{Table: tableName, Rows: rows, DurationSeconds: time.Since(started).Seconds()}
+	if err != nil {
+		table.Error = err.Error()
+	}
+	l.tables = append(l.tables, table)
+}
+
+// recordTableHistory records the outcome of loading a single table from the current data archive
+func (s *Service) recordTableHistory(pkg factset.Package, tableName string, rows int64, started time.Time, err error) {
+	entry := rds.LoadHistory{
+		Package:  pkg,
+		Archive:  s.load.archive,
+		Table:    tableName,
+		Version:  s.load.version,
+		RowCount: rows,
+		Duration: time.Since(started),
+		Outcome:  rds.OutcomeSucceeded,
+		Started:  started,
+	}
+	if err != nil {
+		entry.Outcome = failedOutcome(s.load.interrupted)
+		entry.Error = err.Error()
+	}
+	s.recordHistory(entry)
+}
+
+// recordPackageHistory records the outcome of loading a package as a whole
+func (s *Service) recordPackageHistory(pkg factset.Package, started time.Time, err error) {
+	entry := rds.LoadHistory{
+		Package:  pkg,
+		Archive:  s.load.archive,
+		Version:  s.load.version,
+		RowCount: s.load.rows,
+		Duration: time.Since(started),
+		Outcome:  rds.OutcomeSucceeded,
+		Started:  started,
+	}
+	if err != nil {
+		entry.Outcome = failedOutcome(s.load.interrupted)
+		entry.Error = err.Error()
+	} else if s.load.upToDate {
+		entry.Outcome = rds.OutcomeUpToDate
+	}
+	s.recordHistory(entry)
+}
+
+// failedOutcome is the outcome recorded for a load that returned an error
+func failedOutcome(interrupted bool) string {
+	if interrupted {
+		return rds.OutcomeInterrupted
+	}
+	return rds.OutcomeFailed
+}
+
+// recordHistory adds the entry to the load history. The history is an audit trail, so failing to write it is
+// logged rather than failing the load, and it is written even when the uploader is shutting down.
+func (s *Service) recordHistory(entry rds.LoadHistory) {
+	entry.RunID = s.run.ID()
+	if err := s.db.RecordLoadHistory(context.Background(), entry); err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": entry.Package.Product}).Warnf("Could not record load history for product %s", entry.Package.Product)
+	}
+}
@@ -0,0 +1,120 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	log "github.com/sirupsen/logrus"
+)
+
+// The scopes of the lock taken before loading
+const (
+	LockPackage = "package"
+	LockGlobal  = "global"
+	LockNone    = "none"
+)
+
+// globalLockName is the lock taken for a whole run when locking is global
+const globalLockName = "load"
+
+// stageLock is the stage packages fail at when their lock can not be taken for a reason other than contention
+const stageLock = "lock"
+
+// ParseLockScope - checks the scope is one of LockPackage, LockGlobal or LockNone
+func ParseLockScope(scope string) (string, error) {
+	switch scope {
+	case LockPackage, LockGlobal, LockNone:
+		return scope, nil
+	}
+	return "", fmt.Errorf("lock scope %q is not one of %s, %s or %s", scope, LockPackage, LockGlobal, LockNone)
+}
+
+func packageLockName(pkg factset.Package) string {
+	return fmt.Sprintf("load:%s:%s:v%d", pkg.Product, pkg.Bundle, pkg.FeedVersion)
+}
+
+// lock takes the named lock if the scope of the configured locking is scope, returning the context to load under and
+// a function that releases the lock. The context is done if the lock is lost, so the load stops at its next safe
+// point, and its error is then the rds.LockLostError. Locks held by another uploader are reported as an
+// rds.LockHeldError once the configured wait is over, or with the error of ctx if it is done first.
+func (s *Service) lock(ctx context.Context, scope, name string) (context.Context, func(), error) {
+	configured := s.config.locking.Scope
+	if configured == "" {
+		configured = LockPackage
+	}
+	if configured != scope {
+		return ctx, func() {}, nil
+	}
+
+	lease, err := s.db.AcquireLease(ctx, name, s.config.locking.Wait)
+	if err != nil {
+		return ctx, nil, err
+	}
+	log.Debugf("Took lock %s", name)
+	leaseCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-lease.Lost():
+			log.WithError(lease.Err()).Errorf("Lost lock %s; stopping the load at the next safe point", name)
+			cancel()
+		case <-leaseCtx.Done():
+		}
+	}()
+	return &leaseContext{leaseCtx, lease}, func() {
+		cancel()
+		if err := lease.Release(); err != nil {
+			log.WithError(err).Errorf("Lock %s may have been taken by another uploader before it was released", name)
+		}
+	}, nil
+}
+
+// leaseContext - the context of a load holding a lock, whose error is the loss of the lock if that ended it
+type leaseContext struct {
+	context.Context
+	lease rds.Lease
+}
+
+func (c *leaseContext) Err() error {
+	err := c.Context.Err()
+	if err == nil {
+		return nil
+	}
+	if lost := c.lease.Err(); lost != nil {
+		return lost
+	}
+	return err
+}
+
+// stoppedResults marks the packages that were not started as interrupted if the uploader is shutting down, or as
+// failed if the lock of the run was lost
+func stoppedResults(packages []factset.Package, err error) []PackageResult {
+	if rds.IsLockLost(err) {
+		return failedResults(packages, stageLock, err)
+	}
+	return interruptedResults(packages, err)
+}
+
+// stopReason describes why the load stopped early for the log
+func stopReason(err error) string {
+	if rds.IsLockLost(err) {
+		return "its lock was lost"
+	}
+	return "the uploader is shutting down"
+}
+
+// skippedResults marks every package as skipped because of the lock held by another uploader
+func skippedResults(packages []factset.Package, err error) []PackageResult {
+	var results []PackageResult
+	for _, pkg := range packages {
+		results = append(results, skippedResult(pkg, err))
+	}
+	return results
+}
+
+func skippedResult(pkg factset.Package, err error) PackageResult {
+	loadSkipped.WithLabelValues(pkg.Product).Inc()
+	return PackageResult{Package: pkg, Status: PackageSkipped, Started: time.Now(), Error: err.Error()}
+}
@@ -0,0 +1,203 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadPackagesLocking(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		scope            string
+		held             []string
+		storeErrs        map[string]error
+		expectedOutcome  string
+		expectedStatuses []string
+		expectedLocks    []string
+	}{
+		{
+			testName:         "Locks each package by default",
+			expectedOutcome:  RunSucceeded,
+			expectedStatuses: []string{PackageSucceeded, PackageSucceeded},
+			expectedLocks:    []string{"load:ppl_test:ppl_test:v1", "load:ppl_other:ppl_test:v1"},
+		},
+		{
+			testName:         "Skips a package locked by another uploader",
+			scope:            LockPackage,
+			held:             []string{"load:ppl_test:ppl_test:v1"},
+			expectedOutcome:  RunSucceeded,
+			expectedStatuses: []string{PackageSkipped, PackageSucceeded},
+			expectedLocks:    []string{"load:ppl_other:ppl_test:v1"},
+		},
+		{
+			testName:         "Locks the whole run",
+			scope:            LockGlobal,
+			expectedOutcome:  RunSucceeded,
+			expectedStatuses: []string{PackageSucceeded, PackageSucceeded},
+			expectedLocks:    []string{"load"},
+		},
+		{
+			testName:         "Skips every package when the run is locked by another uploader",
+			scope:            LockGlobal,
+			held:             []string{"load"},
+			expectedOutcome:  RunSucceeded,
+			expectedStatuses: []string{PackageSkipped, PackageSkipped},
+		},
+		{
+			testName:         "Does not lock when locking is turned off",
+			scope:            LockNone,
+			held:             []string{"load", "load:ppl_test:ppl_test:v1"},
+			expectedOutcome:  RunSucceeded,
+			expectedStatuses: []string{PackageSucceeded, PackageSucceeded},
+		},
+		{
+			testName:         "Fails packages whose lock can not be taken",
+			scope:            LockPackage,
+			storeErrs:        map[string]error{"AcquireLease": errors.New("connection refused")},
+			expectedOutcome:  RunFailed,
+			expectedStatuses: []string{PackageFailed, PackageFailed},
+		},
+	}
+	for _, d := range testCases {
+		config := Config{packages: []factset.Package{standardPkg, otherPkg}}
+		config.SetLocking(Locking{Scope: d.scope})
+		loader, store, cleanup := newTestLoader(t, config, d.storeErrs)
+		for _, name := range d.held {
+			store.locks[name] = true
+		}
+		summary := loader.LoadPackages(context.Background())
+
+		assert.Equal(t, d.expectedOutcome, summary.Outcome, "Test %s failed, wrong outcome", d.testName)
+		var statuses []string
+		for _, p := range summary.Packages {
+			statuses = append(statuses, p.Status)
+			if p.Status == PackageFailed {
+				assert.Equal(t, stageLock, p.ErrorStage, "Test %s failed, wrong error stage", d.testName)
+			}
+		}
+		assert.Equal(t, d.expectedStatuses, statuses, "Test %s failed, wrong statuses", d.testName)
+		assert.Equal(t, d.expectedLocks, store.acquired, "Test %s failed, wrong locks taken", d.testName)
+		for _, name := range d.expectedLocks {
+			assert.False(t, store.locks[name], "Test %s failed, lock %s was not released", d.testName, name)
+		}
+		cleanup()
+	}
+}
+
+func Test_LoadPackagesLostLock(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		scope            string
+		lose             string
+		expectedOutcome  string
+		expectedStatuses []string
+		expectedStages   []string
+	}{
+		{
+			testName:         "Stops loading a package whose lock is lost",
+			scope:            LockPackage,
+			lose:             "load:ppl_test:ppl_test:v1",
+			expectedOutcome:  RunPartialFailure,
+			expectedStatuses: []string{PackageFailed, PackageSucceeded},
+			expectedStages:   []string{stageLoad, ""},
+		},
+		{
+			testName:         "Stops the run when its lock is lost",
+			scope:            LockGlobal,
+			lose:             globalLockName,
+			expectedOutcome:  RunFailed,
+			expectedStatuses: []string{PackageFailed, PackageFailed},
+			expectedStages:   []string{stageLoad, stageLock},
+		},
+	}
+	for _, d := range testCases {
+		config := Config{packages: []factset.Package{standardPkg, otherPkg}}
+		config.SetLocking(Locking{Scope: d.scope})
+		loader, store, cleanup := newTestLoader(t, config, nil)
+		store.beforeLoad = func(ctx context.Context, tableName string) {
+			if lease, ok := store.leases[d.lose]; ok && lease.err == nil {
+				lease.lose()
+				// the load is stopped once the loss has been noticed
+				<-ctx.Done()
+			}
+		}
+		summary := loader.LoadPackages(context.Background())
+
+		assert.Equal(t, d.expectedOutcome, summary.Outcome, "Test %s failed, wrong outcome", d.testName)
+		var statuses, stages []string
+		for _, p := range summary.Packages {
+			statuses = append(statuses, p.Status)
+			stages = append(stages, p.ErrorStage)
+			if p.Status == PackageFailed {
+				assert.Contains(t, p.Error, "lost lock "+d.lose, "Test %s failed, wrong error", d.testName)
+			}
+		}
+		assert.Equal(t, d.expectedStatuses, statuses, "Test %s failed, wrong statuses", d.testName)
+		assert.Equal(t, d.expectedStages, stages, "Test %s failed, wrong stages", d.testName)
+		for _, h := range store.history {
+			assert.NotEqual(t, rds.OutcomeInterrupted, h.Outcome, "Test %s failed, a lost lock should fail the load rather than interrupt it", d.testName)
+		}
+		_, loaded := store.packages[packageKey(standardPkg)]
+		assert.False(t, loaded, "Test %s failed, no package version should be recorded for the package whose lock was lost", d.testName)
+		assert.Empty(t, store.locks, "Test %s failed, locks were not released", d.testName)
+		cleanup()
+	}
+}
+
+func Test_ResetPackagesLocking(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		scope         string
+		held          []string
+		expectedReset bool
+		expectedLocks []string
+	}{
+		{"Locks the package by default", "", nil, true, []string{"load:ppl_test:ppl_test:v1"}},
+		{"Does not reset a package that is loading", LockPackage, []string{"load:ppl_test:ppl_test:v1"}, false, nil},
+		{"Locks the whole reset", LockGlobal, nil, true, []string{"load"}},
+		{"Does not reset while another uploader holds the run lock", LockGlobal, []string{"load"}, false, nil},
+		{"Does not lock when locking is turned off", LockNone, []string{"load", "load:ppl_test:ppl_test:v1"}, true, nil},
+	}
+	for _, d := range testCases {
+		config := Config{packages: []factset.Package{standardPkg}}
+		config.SetLocking(Locking{Scope: d.scope})
+		loader, store, cleanup := newTestLoader(t, config, nil)
+		summary := loader.LoadPackages(context.Background())
+		assert.Equal(t, RunSucceeded, summary.Outcome, "Test %s failed, package was not loaded", d.testName)
+		store.acquired = nil
+		for _, name := range d.held {
+			store.locks[name] = true
+		}
+
+		err := loader.ResetPackages(context.Background(), config.packages, true)
+		_, loaded := store.packages[packageKey(standardPkg)]
+		if d.expectedReset {
+			assert.NoError(t, err, "Test %s failed, unexpected error", d.testName)
+			assert.False(t, loaded, "Test %s failed, package metadata was not reset", d.testName)
+			assert.Empty(t, store.tables, "Test %s failed, tables were not dropped", d.testName)
+		} else {
+			assert.True(t, rds.IsLockHeld(err), "Test %s failed, expected a lock held error, got %v", d.testName, err)
+			assert.True(t, loaded, "Test %s failed, package metadata was reset", d.testName)
+			assert.NotEmpty(t, store.tables, "Test %s failed, tables were dropped", d.testName)
+		}
+		assert.Equal(t, d.expectedLocks, store.acquired, "Test %s failed, wrong locks taken", d.testName)
+		cleanup()
+	}
+}
+
+func TestParseLockScope(t *testing.T) {
+	for _, scope := range []string{LockPackage, LockGlobal, LockNone} {
+		parsed, err := ParseLockScope(scope)
+		assert.NoError(t, err, "Test %s failed, unexpected error", scope)
+		assert.Equal(t, scope, parsed, "Test %s failed, wrong scope", scope)
+	}
+	_, err := ParseLockScope("table")
+	if assert.Error(t, err) {
+		assert.Equal(t, `lock scope "table" is not one of package, global or none`, err.Error())
+	}
+}
@@ -0,0 +1,293 @@
+package loader
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/ddl"
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+)
+
+// MemoryStore - in-memory rds.Storer so the loader can be tested without a database
+type MemoryStore struct {
+	packages map[string]factset.PackageMetadata
+	tables   map[string]*memoryTable
+	history  []rds.LoadHistory
+	// schema changes applied in place, in order
+	migrations []ddl.SchemaDiff
+	// results to return from LoadTable in place of the rows in the file, by table name
+	loadResults map[string]rds.LoadResult
+	// errors to return from the named method, e.g. "LoadTable"
+	errs map[string]error
+	// locks held, by name, and the names of those acquired in order
+	locks    map[string]bool
+	acquired []string
+	// called before loading each table, e.g. to stop the load part way
+	beforeLoad func(ctx context.Context, tableName string)
+	// leases held, by lock name, so a test can lose them part way through a load
+	leases map[string]*memoryLease
+}
+
+type memoryTable struct {
+	product string
+	bundle  string
+	version factset.PackageVersion
+	rows    int
+	counts  rds.TableRowCounts
+	format  rds.FileFormat
+	// loadedColumns is the header of the file last loaded into the table
+	loadedColumns []string
+	// definition is the table as created or last migrated
+	definition ddl.Table
+}
+
+func newMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		packages:    make(map[string]factset.PackageMetadata),
+		tables:      make(map[string]*memoryTable),
+		loadResults: make(map[string]rds.LoadResult),
+		errs:        make(map[string]error),
+		locks:       make(map[string]bool),
+		leases:      make(map[string]*memoryLease),
+	}
+}
+
+func packageKey(pkg factset.Package) string {
+	return fmt.Sprintf("%s/%s/v%d", pkg.Product, pkg.Bundle, pkg.FeedVersion)
+}
+
+func (m *MemoryStore) GetPackageMetadata(ctx context.Context, pkg factset.Package) (factset.PackageMetadata, error) {
+	if err := m.errs["GetPackageMetadata"]; err != nil {
+		return factset.PackageMetadata{}, err
+	}
+	pm, ok := m.packages[packageKey(pkg)]
+	if !ok {
+		return factset.PackageMetadata{}, sql.ErrNoRows
+	}
+	return pm, nil
+}
+
+func (m *MemoryStore) UpdateLoadedPackageVersion(ctx context.Context, packageMetadata *factset.PackageMetadata) error {
+	if err := m.errs["UpdateLoadedPackageVersion"]; err != nil {
+		return err
+	}
+	m.packages[packageKey(packageMetadata.Package)] = *packageMetadata
+	return nil
+}
+
+func (m *MemoryStore) UpdateLoadedTableVersion(ctx context.Context, tableName string, version factset.PackageVersion, pkg factset.Package) error {
+	if err := m.errs["UpdateLoadedTableVersion"]; err != nil {
+		return err
+	}
+	table, ok := m.tables[tableName]
+	if !ok {
+		table = &memoryTable{}
+		m.tables[tableName] = table
+	}
+	table.product = pkg.Product
+	table.bundle = pkg.Bundle
+	table.version = version
+	return nil
+}
+
+func (m *MemoryStore) CreateTablesFromSchema(ctx context.Context, contents []byte, pkg factset.Package) error {
+	if err := m.errs["CreateTablesFromSchema"]; err != nil {
+		return err
+	}
+	schema, err := ddl.Parse(string(contents))
+	if err != nil {
+		return err
+	}
+	for _, table := range schema.Tables {
+		m.tables[table.Name] = &memoryTable{product: pkg.Product, bundle: pkg.Bundle, definition: table}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetTableSchemas(ctx context.Context, product string, bundle string) ([]ddl.Table, error) {
+	if err := m.errs["GetTableSchemas"]; err != nil {
+		return nil, err
+	}
+	var tables []ddl.Table
+	for _, table := range m.tables {
+		if table.product == product && table.bundle == bundle && table.definition.Name != "" {
+			tables = append(tables, table.definition)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
+
+func (m *MemoryStore) ApplySchemaChanges(ctx context.Context, changes ddl.SchemaDiff, schema ddl.Schema, pkg factset.Package) error {
+	if err := m.errs["ApplySchemaChanges"]; err != nil {
+		return err
+	}
+	if changes.IsDestructive() {
+		return fmt.Errorf("schema changes can not be made in place: %s", changes)
+	}
+	for _, table := range changes.AddedTables {
+		m.tables[table.Name] = &memoryTable{product: pkg.Product, bundle: pkg.Bundle, definition: table}
+	}
+	for _, table := range changes.ChangedTables {
+		m.tables[table.Table.Name].definition = table.Table
+	}
+	m.migrations = append(m.migrations, changes)
+	return nil
+}
+
+func (m *MemoryStore) DropDataFromTable(ctx context.Context, tableName string, product string) error {
+	if err := m.errs["DropDataFromTable"]; err != nil {
+		return err
+	}
+	if table, ok := m.tables[tableName]; ok {
+		table.rows = 0
+	}
+	return nil
+}
+
+// LoadTable - counts the data rows in the file, which must be for a table that has been created
+func (m *MemoryStore) LoadTable(ctx context.Context, filename, tableName string, format rds.FileFormat) (rds.LoadResult, error) {
+	if m.beforeLoad != nil {
+		m.beforeLoad(ctx, tableName)
+	}
+	if err := m.errs["LoadTable"]; err != nil {
+		return rds.LoadResult{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return rds.LoadResult{}, err
+	}
+	table, ok := m.tables[tableName]
+	if !ok {
+		return rds.LoadResult{}, fmt.Errorf("table %s does not exist", tableName)
+	}
+	table.format = format
+	if format.Header {
+		columns, err := readHeader(filename, format)
+		if err != nil {
+			return rds.LoadResult{}, err
+		}
+		table.loadedColumns = columns
+	}
+	if result, ok := m.loadResults[tableName]; ok {
+		table.rows = int(result.Rows)
+		return result, nil
+	}
+	rows, err := rds.CountDataRows(filename, format)
+	if err != nil {
+		return rds.LoadResult{}, err
+	}
+	table.rows = int(rows)
+	return rds.LoadResult{Rows: rows}, nil
+}
+
+// readHeader returns the column names in the first line of the file
+func readHeader(filename string, format rds.FileFormat) ([]string, error) {
+	f, err := rds.OpenDataFile(filename, format)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString(format.LineEnding[len(format.LineEnding)-1])
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var columns []string
+	for _, field := range strings.Split(strings.TrimRight(line, "\r\n"), string(format.Delimiter)) {
+		columns = append(columns, strings.Trim(field, `"`))
+	}
+	return columns, nil
+}
+
+func (m *MemoryStore) UpdateLoadedTableRowCounts(ctx context.Context, tableName string, counts rds.TableRowCounts) error {
+	if err := m.errs["UpdateLoadedTableRowCounts"]; err != nil {
+		return err
+	}
+	table, ok := m.tables[tableName]
+	if !ok {
+		return fmt.Errorf("table %s does not exist", tableName)
+	}
+	table.counts = counts
+	return nil
+}
+
+func (m *MemoryStore) DropTablesWithProductAndBundle(ctx context.Context, product string, bundle string) error {
+	if err := m.errs["DropTablesWithProductAndBundle"]; err != nil {
+		return err
+	}
+	for name, table := range m.tables {
+		if table.product == product && table.bundle == bundle {
+			delete(m.tables, name)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ResetPackage(ctx context.Context, pkg factset.Package, dropTables bool) error {
+	if err := m.errs["ResetPackage"]; err != nil {
+		return err
+	}
+	if dropTables {
+		if err := m.DropTablesWithProductAndBundle(ctx, pkg.Product, pkg.Bundle); err != nil {
+			return err
+		}
+	}
+	delete(m.packages, packageKey(pkg))
+	return nil
+}
+
+func (m *MemoryStore) RecordLoadHistory(ctx context.Context, entry rds.LoadHistory) error {
+	if err := m.errs["RecordLoadHistory"]; err != nil {
+		return err
+	}
+	m.history = append(m.history, entry)
+	return nil
+}
+
+func (m *MemoryStore) AcquireLease(ctx context.Context, name string, wait time.Duration) (rds.Lease, error) {
+	if err := m.errs["AcquireLease"]; err != nil {
+		return nil, err
+	}
+	if m.locks[name] {
+		return nil, &rds.LockHeldError{Name: name, Holder: "another uploader", Waited: wait}
+	}
+	m.locks[name] = true
+	m.acquired = append(m.acquired, name)
+	lease := &memoryLease{store: m, name: name, lost: make(chan struct{})}
+	m.leases[name] = lease
+	return lease, nil
+}
+
+type memoryLease struct {
+	store *MemoryStore
+	name  string
+	lost  chan struct{}
+	err   error
+}
+
+// lose - reports the lock as lost, as a lease does when it can not be renewed
+func (l *memoryLease) lose() {
+	l.err = &rds.LockLostError{Name: l.name, Err: fmt.Errorf("lock %s is no longer held", l.name)}
+	close(l.lost)
+}
+
+func (l *memoryLease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *memoryLease) Err() error {
+	return l.err
+}
+
+func (l *memoryLease) Release() error {
+	delete(l.store.locks, l.name)
+	delete(l.store.leases, l.name)
+	return l.err
+}
@@ -0,0 +1,99 @@
+package loader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The stages of loading a package, used to label failures
+const (
+	stageMetadata = "metadata"
+	stageSchema   = "schema"
+	stageDownload = "download"
+	stageUnzip    = "unzip"
+	stageLoad     = "load"
+	stageUpdate   = "update"
+)
+
+// durationBuckets - histogram buckets, in seconds, suited to the duration of extracts and loads
+var durationBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600}
+
+var (
+	unzipDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factset_uploader_unzip_duration_seconds",
+		Help:    "Time taken to extract a downloaded archive.",
+		Buckets: durationBuckets,
+	}, []string{"product"})
+	rowsLoaded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factset_uploader_rows_loaded_total",
+		Help: "Rows loaded into each table.",
+	}, []string{"product", "table"})
+	loadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factset_uploader_load_duration_seconds",
+		Help:    "Time taken to load a package, whether it succeeded or failed.",
+		Buckets: durationBuckets,
+	}, []string{"product"})
+	loadFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factset_uploader_load_failures_total",
+		Help: "Package loads that failed, by the stage they failed at.",
+	}, []string{"product", "stage"})
+	loadSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factset_uploader_load_skipped_total",
+		Help: "Package loads skipped because another uploader held the lock.",
+	}, []string{"product"})
+	loadInterrupted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factset_uploader_load_interrupted_total",
+		Help: "Package loads stopped, or not started, because the uploader was shutting down.",
+	}, []string{"product"})
+	loadedSequence = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "factset_uploader_package_loaded_sequence",
+		Help: "Sequence of the data loaded for each package.",
+	}, []string{"product", "bundle"})
+	loadedAge = prometheus.NewDesc(
+		"factset_uploader_package_loaded_age_seconds",
+		"Seconds since each package was last loaded successfully.",
+		[]string{"product", "bundle"}, nil)
+)
+
+// loadedPackages holds when each package was last loaded, so its age can be worked out whenever the metrics are
+// collected
+var loadedPackages = struct {
+	sync.Mutex
+	at map[factset.Package]time.Time
+}{at: make(map[factset.Package]time.Time)}
+
+func init() {
+	prometheus.MustRegister(unzipDuration, rowsLoaded, loadDuration, loadFailures, loadSkipped, loadInterrupted,
+		loadedSequence, loadedAgeCollector{})
+}
+
+// recordLoadedPackage updates the loaded sequence and age of the package from its metadata, unless it has not been
+// loaded
+func recordLoadedPackage(pkg factset.Package, metadata factset.PackageMetadata) {
+	if metadata.PackageVersion.FeedVersion == 0 || metadata.PackageLoadedDate.IsZero() {
+		return
+	}
+	loadedSequence.WithLabelValues(pkg.Product, pkg.Bundle).Set(float64(metadata.PackageVersion.Sequence))
+	loadedPackages.Lock()
+	loadedPackages.at[pkg] = metadata.PackageLoadedDate
+	loadedPackages.Unlock()
+}
+
+// loadedAgeCollector - works out the age of each loaded package when the metrics are collected
+type loadedAgeCollector struct{}
+
+func (loadedAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- loadedAge
+}
+
+func (loadedAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	loadedPackages.Lock()
+	defer loadedPackages.Unlock()
+	now := time.Now()
+	for pkg, at := range loadedPackages.at {
+		ch <- prometheus.MustNewConstMetric(loadedAge, prometheus.GaugeValue, now.Sub(at).Seconds(), pkg.Product, pkg.Bundle)
+	}
+}
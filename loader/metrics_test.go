@@ -0,0 +1,88 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadMetrics(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		storeErrs        map[string]error
+		expectedRows     float64
+		expectedSequence float64
+		expectedStage    string
+	}{
+		{
+			testName:         "Records rows and the loaded sequence",
+			expectedRows:     5,
+			expectedSequence: 1234,
+		},
+		{
+			testName:      "Records a failure to read the loaded version",
+			storeErrs:     map[string]error{"GetPackageMetadata": errors.New("connection refused")},
+			expectedStage: stageMetadata,
+		},
+		{
+			testName:      "Records a failure to create the tables",
+			storeErrs:     map[string]error{"CreateTablesFromSchema": errors.New("syntax error")},
+			expectedStage: stageSchema,
+		},
+		{
+			testName:      "Records a failure to load a table",
+			storeErrs:     map[string]error{"LoadTable": errors.New("could not load table")},
+			expectedStage: stageLoad,
+		},
+		{
+			testName:      "Records a failure to update the loaded version",
+			storeErrs:     map[string]error{"UpdateLoadedPackageVersion": errors.New("deadlock")},
+			expectedRows:  5,
+			expectedStage: stageUpdate,
+		},
+	}
+	for _, d := range testCases {
+		loader, _, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg}}, d.storeErrs)
+		rowsBefore := testutil.ToFloat64(rowsLoaded.WithLabelValues(standardPkg.Product, "ppl_names"))
+		failuresBefore := map[string]float64{}
+		for _, stage := range []string{stageMetadata, stageSchema, stageDownload, stageUnzip, stageLoad, stageUpdate} {
+			failuresBefore[stage] = testutil.ToFloat64(loadFailures.WithLabelValues(standardPkg.Product, stage))
+		}
+		loadsBefore := observations(loadDuration, standardPkg.Product)
+		loadedSequence.WithLabelValues(standardPkg.Product, standardPkg.Bundle).Set(0)
+		loadedPackages.Lock()
+		delete(loadedPackages.at, standardPkg)
+		loadedPackages.Unlock()
+
+		loader.LoadPackages(context.Background())
+
+		assert.Equal(t, loadsBefore+1, observations(loadDuration, standardPkg.Product), "Test %s failed, load duration not recorded", d.testName)
+		assert.Equal(t, d.expectedRows, testutil.ToFloat64(rowsLoaded.WithLabelValues(standardPkg.Product, "ppl_names"))-rowsBefore, "Test %s failed, wrong rows recorded", d.testName)
+		assert.Equal(t, d.expectedSequence, testutil.ToFloat64(loadedSequence.WithLabelValues(standardPkg.Product, standardPkg.Bundle)), "Test %s failed, wrong loaded sequence", d.testName)
+		for stage, before := range failuresBefore {
+			expected := float64(0)
+			if stage == d.expectedStage {
+				expected = 1
+			}
+			assert.Equal(t, expected, testutil.ToFloat64(loadFailures.WithLabelValues(standardPkg.Product, stage))-before, "Test %s failed, wrong failures at stage %s", d.testName, stage)
+		}
+		loadedPackages.Lock()
+		_, aged := loadedPackages.at[standardPkg]
+		loadedPackages.Unlock()
+		assert.Equal(t, d.expectedSequence > 0, aged, "Test %s failed, loaded age not recorded", d.testName)
+		cleanup()
+	}
+}
+
+// observations returns the number of durations observed by the histogram for the label values
+func observations(h *prometheus.HistogramVec, labels ...string) uint64 {
+	var m dto.Metric
+	h.WithLabelValues(labels...).(prometheus.Metric).Write(&m)
+	return m.GetHistogram().GetSampleCount()
+}
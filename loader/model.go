@@ -1,13 +1,91 @@
 package loader
 
-import "github.com/Financial-Times/factset-uploader/factset"
+import (
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/cron"
+	"github.com/Financial-Times/factset-uploader/factset"
+)
 
 // Config - Which packages to load
 type Config struct {
-	packages []factset.Package
+	packages       []factset.Package
+	reconciliation *Reconciliation
+	fileFormats    map[string]FileFormatSetting
+	// schedule is used for the packages of products without a schedule of their own
+	schedule         *cron.Schedule
+	productSchedules map[string]*cron.Schedule
+	// staleAfter is how long a package may go without loading before notifications report it as stale
+	staleAfter time.Duration
+	locking    Locking
 }
 
 // AddPackage - append new package
 func (c *Config) AddPackage(p factset.Package) {
 	c.packages = append(c.packages, p)
 }
+
+// Packages - the packages to load, in the order they are loaded
+func (c *Config) Packages() []factset.Package {
+	return c.packages
+}
+
+// SetReconciliation - how far the rows loaded into a table may differ from its data file; without it
+// DefaultReconciliation is used
+func (c *Config) SetReconciliation(r Reconciliation) {
+	c.reconciliation = &r
+}
+
+// reconciliationThresholds returns the reconciliation set, or DefaultReconciliation if none has been
+func (c *Config) reconciliationThresholds() Reconciliation {
+	if c.reconciliation == nil {
+		return DefaultReconciliation
+	}
+	return *c.reconciliation
+}
+
+// SetFileFormats - how data files are read, by product or table name; files without a setting are read
+// as rds.DefaultFileFormat
+func (c *Config) SetFileFormats(settings map[string]FileFormatSetting) {
+	c.fileFormats = settings
+}
+
+// SetSchedules - when RunScheduled loads the packages, by default and by product
+func (c *Config) SetSchedules(schedule *cron.Schedule, byProduct map[string]*cron.Schedule) {
+	c.schedule = schedule
+	c.productSchedules = byProduct
+}
+
+// SetStaleAfter - how long a package may go without being loaded successfully before notifications report it as
+// stale; 0 to not report stale packages
+func (c *Config) SetStaleAfter(staleAfter time.Duration) {
+	c.staleAfter = staleAfter
+}
+
+// SetLocking - which lock is taken before loading, and how long to wait for it
+func (c *Config) SetLocking(l Locking) {
+	c.locking = l
+}
+
+// Locking - the lock taken in the database so uploaders sharing it do not load the same packages at once. The zero
+// value locks each package and skips those locked by another uploader without waiting.
+type Locking struct {
+	// Scope is LockPackage, LockGlobal or LockNone
+	Scope string
+	// Wait is how long to wait for a lock held by another uploader before skipping its packages
+	Wait time.Duration
+}
+
+// Reconciliation - thresholds applied after each table is loaded. Row differences are a fraction of the rows in
+// the data file; a negative threshold is never exceeded. The zero value fails a table on any difference or warning,
+// so a Config without one set uses DefaultReconciliation instead.
+type Reconciliation struct {
+	WarnRowDifference float64
+	FailRowDifference float64
+	WarnWarnings      int64
+	FailWarnings      int64
+}
+
+// DefaultReconciliation - the thresholds used when none are set, the same as the command line defaults: any row
+// difference or warning is logged, and a table fails when more than 1% of its rows differ
+var DefaultReconciliation = Reconciliation{WarnRowDifference: 0, FailRowDifference: 0.01, WarnWarnings: 0, FailWarnings: -1}
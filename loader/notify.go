@@ -0,0 +1,84 @@
+package loader
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// The kinds of event a run is notified about
+const (
+	EventSchemaChange = "schema-change"
+	EventFailure      = "failure"
+	EventStale        = "stale"
+)
+
+// Notifier - told about the events of each run that has any, e.g. to post them to a webhook
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Notification - the events of a run and its summary
+type Notification struct {
+	Events  []Event    `json:"events"`
+	Summary RunSummary `json:"summary"`
+}
+
+// Event - something about a package in a run that someone should know about
+type Event struct {
+	Kind    string          `json:"kind"`
+	Package factset.Package `json:"package"`
+	Message string          `json:"message"`
+}
+
+// SetNotifier - the notifier told about the schema changes, failures and stale packages of each run
+func (s *Service) SetNotifier(notifier Notifier) {
+	s.notifier = notifier
+}
+
+// notify tells the notifier about the events of the run. Failing to notify is logged rather than failing the run.
+func (s *Service) notify(summary RunSummary) {
+	if s.notifier == nil {
+		return
+	}
+	events := runEvents(summary, s.config.staleAfter, summary.Finished)
+	if len(events) == 0 {
+		return
+	}
+	if err := s.notifier.Notify(Notification{Events: events, Summary: summary}); err != nil {
+		log.WithError(err).Warnf("Could not send notification of %d events in run %s", len(events), summary.RunID)
+	}
+}
+
+// runEvents lists the schema changes and failures of the run, and the packages that have not been loaded within
+// staleAfter of now
+func runEvents(summary RunSummary, staleAfter time.Duration, now time.Time) []Event {
+	var events []Event
+	for _, p := range summary.Packages {
+		if p.SchemaChange != "" {
+			message := fmt.Sprintf("%s schema was %s from v%d_%d to v%d_%d", p.Package.Product, p.SchemaChange, p.SchemaBefore.FeedVersion, p.SchemaBefore.Sequence, p.SchemaAfter.FeedVersion, p.SchemaAfter.Sequence)
+			if p.SchemaBefore.FeedVersion == 0 {
+				message = fmt.Sprintf("%s schema was created at v%d_%d", p.Package.Product, p.SchemaAfter.FeedVersion, p.SchemaAfter.Sequence)
+			}
+			events = append(events, Event{Kind: EventSchemaChange, Package: p.Package, Message: message})
+		}
+		if p.Status == PackageFailed {
+			events = append(events, Event{
+				Kind:    EventFailure,
+				Package: p.Package,
+				Message: fmt.Sprintf("%s failed to load at the %s stage: %s", p.Package.Product, p.ErrorStage, p.Error),
+			})
+		}
+		if staleAfter > 0 && !p.LastLoaded.IsZero() && now.Sub(p.LastLoaded) > staleAfter {
+			age := now.Sub(p.LastLoaded)
+			events = append(events, Event{
+				Kind:    EventStale,
+				Package: p.Package,
+				Message: fmt.Sprintf("%s was last loaded %s ago, at v%d_%d", p.Package.Product, age-age%time.Minute, p.DataAfter.FeedVersion, p.DataAfter.Sequence),
+			})
+		}
+	}
+	return events
+}
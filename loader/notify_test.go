@@ -0,0 +1,119 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingNotifier struct {
+	notifications []Notification
+	err           error
+}
+
+func (n *recordingNotifier) Notify(notification Notification) error {
+	n.notifications = append(n.notifications, notification)
+	return n.err
+}
+
+func TestRunEvents(t *testing.T) {
+	now := time.Date(2018, time.January, 10, 12, 0, 0, 0, time.UTC)
+	version := factset.PackageVersion{FeedVersion: 1, Sequence: 12}
+	testCases := []struct {
+		name     string
+		result   PackageResult
+		expected []Event
+	}{
+		{
+			name:   "Loaded",
+			result: PackageResult{Package: standardPkg, Status: PackageSucceeded, DataAfter: version, LastLoaded: now},
+		},
+		{
+			name: "SchemaMigrated",
+			result: PackageResult{Package: standardPkg, Status: PackageSucceeded, SchemaChange: SchemaMigrated, LastLoaded: now,
+				SchemaBefore: factset.PackageVersion{FeedVersion: 1, Sequence: 1}, SchemaAfter: factset.PackageVersion{FeedVersion: 1, Sequence: 2}},
+			expected: []Event{{Kind: EventSchemaChange, Package: standardPkg, Message: "ppl_test schema was migrated from v1_1 to v1_2"}},
+		},
+		{
+			name:     "SchemaCreated",
+			result:   PackageResult{Package: standardPkg, Status: PackageSucceeded, SchemaChange: SchemaRebuilt, SchemaAfter: factset.PackageVersion{FeedVersion: 1, Sequence: 2}, LastLoaded: now},
+			expected: []Event{{Kind: EventSchemaChange, Package: standardPkg, Message: "ppl_test schema was created at v1_2"}},
+		},
+		{
+			name:     "FailedRecently",
+			result:   PackageResult{Package: standardPkg, Status: PackageFailed, ErrorStage: stageDownload, Error: "timeout", DataAfter: version, LastLoaded: now.Add(-time.Hour)},
+			expected: []Event{{Kind: EventFailure, Package: standardPkg, Message: "ppl_test failed to load at the download stage: timeout"}},
+		},
+		{
+			name:   "FailedAndStale",
+			result: PackageResult{Package: standardPkg, Status: PackageFailed, ErrorStage: stageMetadata, Error: "connection refused", DataAfter: version, LastLoaded: now.Add(-50*time.Hour - 30*time.Second)},
+			expected: []Event{
+				{Kind: EventFailure, Package: standardPkg, Message: "ppl_test failed to load at the metadata stage: connection refused"},
+				{Kind: EventStale, Package: standardPkg, Message: "ppl_test was last loaded 50h0m0s ago, at v1_12"},
+			},
+		},
+		{
+			name:     "NeverLoaded",
+			result:   PackageResult{Package: standardPkg, Status: PackageFailed, ErrorStage: stageSchema, Error: "syntax error"},
+			expected: []Event{{Kind: EventFailure, Package: standardPkg, Message: "ppl_test failed to load at the schema stage: syntax error"}},
+		},
+	}
+	for _, d := range testCases {
+		events := runEvents(RunSummary{Packages: []PackageResult{d.result}}, 36*time.Hour, now)
+		assert.Equal(t, d.expected, events, "Test %s failed, wrong events", d.name)
+	}
+}
+
+func Test_LoadPackagesNotifies(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		storeErrs     map[string]error
+		notifyErr     error
+		expectedKinds []string
+	}{
+		{
+			testName:      "Notifies of the schema being created",
+			expectedKinds: []string{EventSchemaChange},
+		},
+		{
+			testName:      "Notifies of a failure",
+			storeErrs:     map[string]error{"GetPackageMetadata": errors.New("connection refused")},
+			expectedKinds: []string{EventFailure},
+		},
+		{
+			testName:      "Still finishes the run when the notification can not be sent",
+			storeErrs:     map[string]error{"GetPackageMetadata": errors.New("connection refused")},
+			notifyErr:     errors.New("webhook unreachable"),
+			expectedKinds: []string{EventFailure},
+		},
+	}
+	for _, d := range testCases {
+		loader, _, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg}}, d.storeErrs)
+		notifier := &recordingNotifier{err: d.notifyErr}
+		loader.SetNotifier(notifier)
+		summary := loader.LoadPackages(context.Background())
+
+		if assert.Len(t, notifier.notifications, 1, "Test %s failed, wrong number of notifications", d.testName) {
+			var kinds []string
+			for _, e := range notifier.notifications[0].Events {
+				kinds = append(kinds, e.Kind)
+			}
+			assert.Equal(t, d.expectedKinds, kinds, "Test %s failed, wrong events", d.testName)
+			assert.Equal(t, summary, notifier.notifications[0].Summary, "Test %s failed, notified of the wrong summary", d.testName)
+		}
+		cleanup()
+	}
+
+	// a run without events is not notified
+	loader, store, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg}}, nil)
+	defer cleanup()
+	store.UpdateLoadedPackageVersion(context.Background(), &freshPackageMetadata)
+	notifier := &recordingNotifier{}
+	loader.SetNotifier(notifier)
+	loader.LoadPackages(context.Background())
+	assert.Empty(t, notifier.notifications, "a run without events should not be notified")
+}
@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// What a load would do to the schema or data of a package
+const (
+	PlanUpToDate = "up-to-date"
+	PlanMigrate  = "migrate"
+	PlanRebuild  = "rebuild"
+	PlanLoad     = "load"
+)
+
+// PackagePlan - what loading a package would do, without changing the database
+type PackagePlan struct {
+	Package      factset.Package
+	Loaded       factset.PackageMetadata
+	LatestSchema factset.PackageVersion
+	LatestData   factset.FSFile
+	// Schema is PlanUpToDate, PlanMigrate or PlanRebuild
+	Schema        string
+	SchemaChanges []string
+	// Data is PlanUpToDate or PlanLoad
+	Data string
+	Err  error
+}
+
+// Plan - works out what loading each configured package would do. Schema archives that have changed are
+// downloaded to compare them with the loaded tables, but nothing is written to the database.
+func (s *Service) Plan(ctx context.Context) ([]PackagePlan, error) {
+	run, err := s.workspace.NewRun()
+	if err != nil {
+		log.WithError(err).Errorf("Could not create run directory in workspace %s prior to planning", s.workspace.Root())
+		return nil, err
+	}
+	s.run = run
+	defer func() {
+		if err := s.run.Finish(); err != nil {
+			log.WithError(err).Errorf("Could not clean up run directory %s after planning", s.run.Dir())
+		}
+		s.run = nil
+	}()
+
+	var plans []PackagePlan
+	for _, pkg := range s.config.packages {
+		if err := ctx.Err(); err != nil {
+			return plans, err
+		}
+		plan := s.planPackage(ctx, pkg)
+		s.run.Cleanup(false)
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func (s *Service) planPackage(ctx context.Context, pkg factset.Package) PackagePlan {
+	plan := PackagePlan{Package: pkg, Schema: PlanUpToDate, Data: PlanUpToDate}
+
+	loaded, err := s.db.GetPackageMetadata(ctx, pkg)
+	if err != nil && err != sql.ErrNoRows {
+		plan.Err = err
+		return plan
+	}
+	plan.Loaded = loaded
+
+	schemaVersion, err := s.factset.GetSchemaInfo(ctx, pkg)
+	if err != nil {
+		plan.Err = err
+		return plan
+	}
+	plan.LatestSchema = *schemaVersion
+
+	latestDataArchive, err := s.factset.GetLatestFile(ctx, pkg, true)
+	if err != nil {
+		plan.Err = err
+		return plan
+	}
+	plan.LatestData = latestDataArchive
+	if needsDataLoad(loaded.PackageVersion, latestDataArchive.Version) {
+		plan.Data = PlanLoad
+	}
+
+	if !isSchemaOutOfDate(schemaVersion, loaded) {
+		return plan
+	}
+	contents, err := s.downloadSchema(ctx, pkg, schemaVersion)
+	if err != nil {
+		plan.Err = err
+		return plan
+	}
+	_, changes, migratable, err := s.schemaChanges(ctx, pkg, contents)
+	if err != nil {
+		plan.Err = err
+		return plan
+	}
+	plan.SchemaChanges = changes.Changes()
+	if migratable {
+		plan.Schema = PlanMigrate
+		if addsData(changes) {
+			// the added tables and columns are empty until the data is reloaded
+			plan.Data = PlanLoad
+		}
+	} else {
+		// the rebuilt tables are empty so the data must be reloaded
+		plan.Schema = PlanRebuild
+		plan.Data = PlanLoad
+	}
+	return plan
+}
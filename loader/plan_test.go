@@ -0,0 +1,108 @@
+package loader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Plan(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		loaded         *factset.PackageMetadata
+		newSchema      string
+		expectedSchema string
+		expectedData   string
+		expectedChange []string
+	}{
+		{
+			testName:       "Nothing loaded",
+			newSchema:      pplNamesSchema,
+			expectedSchema: PlanRebuild,
+			expectedData:   PlanLoad,
+		},
+		{
+			testName:       "Up to date",
+			loaded:         &factset.PackageMetadata{Package: standardPkg, SchemaVersion: updatedSequenceSchema, PackageVersion: filesInDirectory[0].Version},
+			expectedSchema: PlanUpToDate,
+			expectedData:   PlanUpToDate,
+		},
+		{
+			testName:       "New data",
+			loaded:         &factset.PackageMetadata{Package: standardPkg, SchemaVersion: updatedSequenceSchema, PackageVersion: factset.PackageVersion{FeedVersion: 1, Sequence: 1}},
+			expectedSchema: PlanUpToDate,
+			expectedData:   PlanLoad,
+		},
+		{
+			testName:       "Additive schema change",
+			loaded:         &factset.PackageMetadata{Package: standardPkg, SchemaVersion: standardSchema, PackageVersion: filesInDirectory[0].Version},
+			newSchema:      pplNamesSchema + "\nALTER TABLE ppl_names ADD COLUMN people_name_source VARCHAR(20);",
+			expectedSchema: PlanMigrate,
+			expectedData:   PlanLoad,
+			expectedChange: []string{"column ppl_names.people_name_source added"},
+		},
+		{
+			testName:       "Index only schema change",
+			loaded:         &factset.PackageMetadata{Package: standardPkg, SchemaVersion: standardSchema, PackageVersion: filesInDirectory[0].Version},
+			newSchema:      pplNamesSchema + "\nCREATE INDEX ppl_names_value ON ppl_names (people_name_value);",
+			expectedSchema: PlanMigrate,
+			expectedData:   PlanUpToDate,
+			expectedChange: []string{"index ppl_names_value on ppl_names added"},
+		},
+		{
+			testName:       "Destructive schema change",
+			loaded:         &factset.PackageMetadata{Package: standardPkg, SchemaVersion: standardSchema, PackageVersion: filesInDirectory[0].Version},
+			newSchema:      "CREATE TABLE ppl_names (FACTSET_PERSON_ID CHAR(8) NOT NULL);",
+			expectedSchema: PlanRebuild,
+			expectedData:   PlanLoad,
+			expectedChange: []string{"column ppl_names.people_name_type removed", "column ppl_names.people_name_value removed", "primary key of ppl_names changed"},
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(root)
+			workspace, err := OpenWorkspace(root, false)
+			assert.NoError(t, err)
+			archives, err := ioutil.TempDir("", "factset-schema")
+			assert.NoError(t, err)
+			defer os.RemoveAll(archives)
+
+			store := newMemoryStore()
+			if d.loaded != nil {
+				assert.NoError(t, store.CreateTablesFromSchema(context.Background(), []byte(pplNamesSchema), standardPkg))
+				store.UpdateLoadedPackageVersion(context.Background(), d.loaded)
+			}
+			service := &MockFactsetService{
+				fileList:   filesInDirectory,
+				schemaInfo: updatedSequenceSchema,
+				files: map[string]string{
+					"/datafeeds/documents/docs_ppl/ppl_v1_schema_2.zip": createSchemaArchive(t, filepath.Join(archives, "ppl_v1_schema_2.zip"), d.newSchema),
+				},
+			}
+			loader := NewService(Config{packages: []factset.Package{standardPkg}}, store, service, workspace)
+			plans, err := loader.Plan(context.Background())
+			assert.NoError(t, err)
+			if assert.Len(t, plans, 1, "Test %s failed, expected a plan for each package", d.testName) {
+				plan := plans[0]
+				assert.NoError(t, plan.Err, "Test %s failed, unexpected error", d.testName)
+				assert.Equal(t, d.expectedSchema, plan.Schema, "Test %s failed, wrong schema plan", d.testName)
+				assert.Equal(t, d.expectedData, plan.Data, "Test %s failed, wrong data plan", d.testName)
+				assert.Equal(t, d.expectedChange, plan.SchemaChanges, "Test %s failed, wrong schema changes", d.testName)
+				assert.Equal(t, updatedSequenceSchema, plan.LatestSchema)
+				assert.Equal(t, filesInDirectory[0].Version, plan.LatestData.Version)
+			}
+			assert.Empty(t, store.migrations, "Test %s failed, planning should not change the schema", d.testName)
+			pm, _ := store.GetPackageMetadata(context.Background(), standardPkg)
+			if d.loaded != nil {
+				assert.Equal(t, d.loaded.SchemaVersion, pm.SchemaVersion, "Test %s failed, planning should not change metadata", d.testName)
+			}
+		})
+	}
+}
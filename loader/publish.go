@@ -0,0 +1,65 @@
+package loader
+
+import (
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// Publisher - told about each package whose tables changed, once its new version has been recorded
+type Publisher interface {
+	Publish(event LoadedEvent) error
+}
+
+// LoadedEvent - the tables of a package that were changed by loading it and the versions they are now at
+type LoadedEvent struct {
+	RunID         string                 `json:"runId"`
+	Package       factset.Package        `json:"package"`
+	SchemaVersion factset.PackageVersion `json:"schemaVersion"`
+	DataVersion   factset.PackageVersion `json:"dataVersion"`
+	// SchemaChange is SchemaMigrated or SchemaRebuilt if the tables were changed to a new schema, otherwise empty
+	SchemaChange string        `json:"schemaChange,omitempty"`
+	Archive      string        `json:"archive,omitempty"`
+	Tables       []LoadedTable `json:"tables"`
+	Rows         int64         `json:"rows"`
+	Loaded       time.Time     `json:"loaded"`
+}
+
+// LoadedTable - a table loaded from the data archive
+type LoadedTable struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// SetPublisher - the publisher told about each package whose tables changed
+func (s *Service) SetPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// publish tells the publisher about the package if its tables changed. The new version has already been recorded,
+// so failing to publish is logged rather than failing the load.
+func (s *Service) publish(metadata factset.PackageMetadata) {
+	if s.publisher == nil || (s.load.upToDate && s.load.schemaChange == "") {
+		return
+	}
+	event := LoadedEvent{
+		RunID:         s.run.ID(),
+		Package:       metadata.Package,
+		SchemaVersion: metadata.SchemaVersion,
+		DataVersion:   metadata.PackageVersion,
+		SchemaChange:  s.load.schemaChange,
+		Tables:        []LoadedTable{},
+		Rows:          s.load.rows,
+		Loaded:        metadata.PackageLoadedDate,
+	}
+	if !s.load.upToDate {
+		event.Archive = s.load.archive
+	}
+	for _, table := range s.load.tables {
+		event.Tables = append(event.Tables, LoadedTable{Table: table.Table, Rows: table.Rows})
+	}
+	if err := s.publisher.Publish(event); err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": metadata.Package.Product}).Warnf("Could not publish that product %s was loaded", metadata.Package.Product)
+	}
+}
@@ -0,0 +1,84 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingPublisher struct {
+	events []LoadedEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(event LoadedEvent) error {
+	p.events = append(p.events, event)
+	return p.err
+}
+
+func Test_LoadPackagesPublishes(t *testing.T) {
+	testCases := []struct {
+		testName        string
+		existing        *factset.PackageMetadata
+		storeErrs       map[string]error
+		publishErr      error
+		expectPublished bool
+		expectedStatus  string
+	}{
+		{
+			testName:        "Publishes a package once its version is recorded",
+			expectPublished: true,
+			expectedStatus:  PackageSucceeded,
+		},
+		{
+			testName:       "Does not publish a package that is up to date",
+			existing:       &freshPackageMetadata,
+			expectedStatus: PackageUpToDate,
+		},
+		{
+			testName:       "Does not publish a package whose version can not be recorded",
+			storeErrs:      map[string]error{"UpdateLoadedPackageVersion": errors.New("deadlock")},
+			expectedStatus: PackageFailed,
+		},
+		{
+			testName:        "Still loads a package when it can not be published",
+			publishErr:      errors.New("kafka proxy unreachable"),
+			expectPublished: true,
+			expectedStatus:  PackageSucceeded,
+		},
+	}
+	for _, d := range testCases {
+		loader, store, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg}}, d.storeErrs)
+		if d.existing != nil {
+			store.UpdateLoadedPackageVersion(context.Background(), d.existing)
+		}
+		publisher := &recordingPublisher{err: d.publishErr}
+		loader.SetPublisher(publisher)
+		summary := loader.LoadPackages(context.Background())
+
+		if assert.Len(t, summary.Packages, 1, "Test %s failed, wrong number of packages", d.testName) {
+			assert.Equal(t, d.expectedStatus, summary.Packages[0].Status, "Test %s failed, wrong status", d.testName)
+		}
+		if !d.expectPublished {
+			assert.Empty(t, publisher.events, "Test %s failed, should not have published", d.testName)
+			cleanup()
+			continue
+		}
+		if assert.Len(t, publisher.events, 1, "Test %s failed, wrong number of events", d.testName) {
+			event := publisher.events[0]
+			assert.Equal(t, summary.RunID, event.RunID, "Test %s failed, wrong run id", d.testName)
+			assert.Equal(t, standardPkg, event.Package, "Test %s failed, wrong package", d.testName)
+			assert.Equal(t, standardSchema, event.SchemaVersion, "Test %s failed, wrong schema version", d.testName)
+			assert.Equal(t, filesInDirectory[0].Version, event.DataVersion, "Test %s failed, wrong data version", d.testName)
+			assert.Equal(t, SchemaRebuilt, event.SchemaChange, "Test %s failed, wrong schema change", d.testName)
+			assert.Equal(t, filesInDirectory[0].Name, event.Archive, "Test %s failed, wrong archive", d.testName)
+			assert.Equal(t, []LoadedTable{{Table: "ppl_names", Rows: 5}}, event.Tables, "Test %s failed, wrong tables", d.testName)
+			assert.Equal(t, int64(5), event.Rows, "Test %s failed, wrong rows", d.testName)
+			assert.False(t, event.Loaded.IsZero(), "Test %s failed, load time missing", d.testName)
+		}
+		cleanup()
+	}
+}
@@ -0,0 +1,35 @@
+package loader
+
+import (
+	"fmt"
+
+	"github.com/Financial-Times/factset-uploader/rds"
+)
+
+// check compares the counts against the thresholds, returning a description of any difference worth warning
+// about and an error if the table should fail.
+func (r Reconciliation) check(counts rds.TableRowCounts) (string, error) {
+	difference := counts.Loaded - counts.Expected
+	if difference < 0 {
+		difference = -difference
+	}
+	expected := counts.Expected
+	if expected == 0 {
+		expected = 1
+	}
+	fraction := float64(difference) / float64(expected)
+
+	if r.FailRowDifference >= 0 && fraction > r.FailRowDifference {
+		return "", fmt.Errorf("loaded %d rows but the data file has %d, more than the %.2f%% difference allowed", counts.Loaded, counts.Expected, r.FailRowDifference*100)
+	}
+	if r.FailWarnings >= 0 && counts.Warnings > r.FailWarnings {
+		return "", fmt.Errorf("load raised %d warnings, more than the %d allowed", counts.Warnings, r.FailWarnings)
+	}
+	if r.WarnRowDifference >= 0 && fraction > r.WarnRowDifference {
+		return fmt.Sprintf("loaded %d rows but the data file has %d", counts.Loaded, counts.Expected), nil
+	}
+	if r.WarnWarnings >= 0 && counts.Warnings > r.WarnWarnings {
+		return fmt.Sprintf("load raised %d warnings", counts.Warnings), nil
+	}
+	return "", nil
+}
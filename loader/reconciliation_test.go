@@ -0,0 +1,127 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Reconciliation_Check(t *testing.T) {
+	lenient := Reconciliation{WarnRowDifference: 0, FailRowDifference: 0.1, WarnWarnings: 0, FailWarnings: -1}
+	testCases := []struct {
+		testName        string
+		reconciliation  Reconciliation
+		counts          rds.TableRowCounts
+		expectedWarning string
+		expectedError   string
+	}{
+		{"Matching counts pass", DefaultReconciliation, rds.TableRowCounts{Expected: 100, Loaded: 100}, "", ""},
+		{"A difference of 1% warns by default", DefaultReconciliation, rds.TableRowCounts{Expected: 100, Loaded: 99}, "loaded 99 rows but the data file has 100", ""},
+		{"A difference of more than 1% fails by default", DefaultReconciliation, rds.TableRowCounts{Expected: 100, Loaded: 98}, "", "loaded 98 rows but the data file has 100"},
+		{"Warnings warn by default", DefaultReconciliation, rds.TableRowCounts{Expected: 100, Loaded: 100, Warnings: 1}, "raised 1 warnings", ""},
+		{"Any difference fails with zero thresholds", Reconciliation{}, rds.TableRowCounts{Expected: 100, Loaded: 99}, "", "loaded 99 rows but the data file has 100"},
+		{"Any warning fails with zero thresholds", Reconciliation{}, rds.TableRowCounts{Expected: 100, Loaded: 100, Warnings: 1}, "", "raised 1 warnings"},
+		{"Difference within the failure threshold warns", lenient, rds.TableRowCounts{Expected: 100, Loaded: 95}, "loaded 95 rows", ""},
+		{"Extra rows count as a difference", lenient, rds.TableRowCounts{Expected: 100, Loaded: 111}, "", "loaded 111 rows"},
+		{"Warnings warn when failure is disabled", lenient, rds.TableRowCounts{Expected: 100, Loaded: 100, Warnings: 3}, "raised 3 warnings", ""},
+		{"Negative thresholds disable checks", Reconciliation{-1, -1, -1, -1}, rds.TableRowCounts{Expected: 100, Loaded: 0, Warnings: 3}, "", ""},
+		{"Empty data files are compared", DefaultReconciliation, rds.TableRowCounts{Expected: 0, Loaded: 1}, "", "loaded 1 rows"},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			warning, err := d.reconciliation.check(d.counts)
+			if d.expectedError != "" {
+				assert.Error(t, err, "Test %s failed, should have resulted in an error", d.testName)
+				assert.Contains(t, err.Error(), d.expectedError, "Test %s failed, returned unexpected error", d.testName)
+				return
+			}
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			if d.expectedWarning == "" {
+				assert.Empty(t, warning, "Test %s failed, should not have warned", d.testName)
+			} else {
+				assert.Contains(t, warning, d.expectedWarning, "Test %s failed, unexpected warning", d.testName)
+			}
+		})
+	}
+}
+
+func Test_LoadPackages_Reconciliation(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		reconciliation *Reconciliation
+		loadResult     *rds.LoadResult
+		expectLoaded   bool
+		expectedCounts rds.TableRowCounts
+	}{
+		{
+			testName:       "Records matching row counts",
+			expectLoaded:   true,
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 5},
+		},
+		{
+			testName:       "Loads a table with missing rows within the threshold",
+			reconciliation: &Reconciliation{FailRowDifference: 0.5, FailWarnings: -1},
+			loadResult:     &rds.LoadResult{Rows: 4},
+			expectLoaded:   true,
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 4},
+		},
+		{
+			testName:       "Fails a table with missing rows beyond the threshold",
+			reconciliation: &Reconciliation{FailRowDifference: 0.1, FailWarnings: -1},
+			loadResult:     &rds.LoadResult{Rows: 4},
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 4},
+		},
+		{
+			testName:       "Fails a table with missing rows beyond the default threshold",
+			loadResult:     &rds.LoadResult{Rows: 4},
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 4},
+		},
+		{
+			testName:       "Loads a table with warnings by default",
+			loadResult:     &rds.LoadResult{Rows: 5, Warnings: 3},
+			expectLoaded:   true,
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 5, Warnings: 3},
+		},
+		{
+			testName:       "Fails a table with too many warnings",
+			reconciliation: &Reconciliation{FailWarnings: 2},
+			loadResult:     &rds.LoadResult{Rows: 5, Warnings: 3, WarningMessages: []string{"Warning 1265: Data truncated for column 'NAME' at row 1"}},
+			expectedCounts: rds.TableRowCounts{Expected: 5, Loaded: 5, Warnings: 3},
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(root)
+			workspace, err := OpenWorkspace(root, false)
+			assert.NoError(t, err)
+
+			store := newMemoryStore()
+			if d.loadResult != nil {
+				store.loadResults["ppl_names"] = *d.loadResult
+			}
+			config := Config{packages: []factset.Package{standardPkg}}
+			if d.reconciliation != nil {
+				config.SetReconciliation(*d.reconciliation)
+			}
+			loader := NewService(config, store, getFactsetService(filesInDirectory, standardSchema, nil), workspace)
+			loader.LoadPackages(context.Background())
+
+			assert.Equal(t, d.expectedCounts, store.tables["ppl_names"].counts, "Test %s failed, unexpected row counts recorded", d.testName)
+			_, err = store.GetPackageMetadata(context.Background(), standardPkg)
+			if !d.expectLoaded {
+				assert.Equal(t, sql.ErrNoRows, err, "Test %s failed, package version should not have been recorded", d.testName)
+				assert.Equal(t, factset.PackageVersion{}, store.tables["ppl_names"].version, "Test %s failed, table version should not have been recorded", d.testName)
+				return
+			}
+			assert.NoError(t, err, "Test %s failed, package version should have been recorded", d.testName)
+		})
+	}
+}
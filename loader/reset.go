@@ -0,0 +1,36 @@
+package loader
+
+import (
+	"context"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResetPackages - clears the loaded metadata of the packages, and drops their tables when dropTables is set, so they
+// are reloaded by the next load. It takes the same locks as a load, so a package is never reset while it is loading.
+func (s *Service) ResetPackages(ctx context.Context, packages []factset.Package, dropTables bool) error {
+	ctx, release, err := s.lock(ctx, LockGlobal, globalLockName)
+	if err != nil {
+		log.WithError(err).Errorf("Could not take lock %s before resetting packages", globalLockName)
+		return err
+	}
+	defer release()
+
+	for _, pkg := range packages {
+		if err := s.resetPackage(ctx, pkg, dropTables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) resetPackage(ctx context.Context, pkg factset.Package, dropTables bool) error {
+	ctx, release, err := s.lock(ctx, LockPackage, packageLockName(pkg))
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Could not take lock %s before resetting package", packageLockName(pkg))
+		return err
+	}
+	defer release()
+	return s.db.ResetPackage(ctx, pkg, dropTables)
+}
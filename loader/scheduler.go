@@ -0,0 +1,165 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/cron"
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// Replaced by the tests to run the scheduler against a fake clock
+var (
+	schedulerNow   = time.Now
+	schedulerAfter = time.After
+)
+
+// ParseSchedules - parses the global cron schedule and product schedules of the form product=expression;...
+// Either may be empty, but every package must be covered by one of them to be scheduled.
+func ParseSchedules(global string, byProduct string) (*cron.Schedule, map[string]*cron.Schedule, error) {
+	var globalSchedule *cron.Schedule
+	if strings.TrimSpace(global) != "" {
+		schedule, err := cron.Parse(global)
+		if err != nil {
+			return nil, nil, err
+		}
+		globalSchedule = schedule
+	}
+
+	schedules := make(map[string]*cron.Schedule)
+	for _, entry := range strings.Split(byProduct, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		product := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || product == "" {
+			return nil, nil, fmt.Errorf("schedule %q should be of the form product=expression", entry)
+		}
+		schedule, err := cron.Parse(parts[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("schedule for %s: %s", product, err)
+		}
+		schedules[product] = schedule
+	}
+	return globalSchedule, schedules, nil
+}
+
+// scheduleGroup - packages loaded together whenever their schedule fires
+type scheduleGroup struct {
+	schedule *cron.Schedule
+	packages []factset.Package
+	next     time.Time
+}
+
+// scheduleGroups groups the configured packages by schedule, failing if a package has no schedule or a product
+// schedule matches no package
+func (s *Service) scheduleGroups() ([]*scheduleGroup, error) {
+	var groups []*scheduleGroup
+	bySchedule := make(map[*cron.Schedule]*scheduleGroup)
+	used := make(map[string]bool)
+	for _, pkg := range s.config.packages {
+		schedule, ok := s.config.productSchedules[pkg.Product]
+		if ok {
+			used[pkg.Product] = true
+		} else {
+			schedule = s.config.schedule
+		}
+		if schedule == nil {
+			return nil, fmt.Errorf("product %s has no schedule", pkg.Product)
+		}
+		group, ok := bySchedule[schedule]
+		if !ok {
+			group = &scheduleGroup{schedule: schedule}
+			bySchedule[schedule] = group
+			groups = append(groups, group)
+		}
+		group.packages = append(group.packages, pkg)
+	}
+	for product := range s.config.productSchedules {
+		if !used[product] {
+			return nil, fmt.Errorf("a schedule is given for product %s, which is not configured", product)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, errors.New("no packages are configured")
+	}
+	return groups, nil
+}
+
+// RunScheduled - loads packages whenever their schedules fire until ctx is done. Loads run one at a time, so a
+// schedule that fires during a load waits for it to finish and fire times missed while a package's own load was running
+// are skipped. A load in progress when ctx is done stops at its next safe point before returning.
+func (s *Service) RunScheduled(ctx context.Context) error {
+	groups, err := s.scheduleGroups()
+	if err != nil {
+		return err
+	}
+	now := schedulerNow()
+	for _, group := range groups {
+		group.next = group.schedule.Next(now)
+		log.Infof("Scheduled %s with %q, next load at %s", describePackages(group.packages), group.schedule, group.next.Format(time.RFC3339))
+	}
+
+	for {
+		var first time.Time
+		for _, group := range groups {
+			if !group.next.IsZero() && (first.IsZero() || group.next.Before(first)) {
+				first = group.next
+			}
+		}
+		if first.IsZero() {
+			return errors.New("no schedule will fire again")
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("Scheduler stopped")
+			return nil
+		case <-schedulerAfter(first.Sub(schedulerNow())):
+		}
+
+		started := schedulerNow()
+		var due []*scheduleGroup
+		var packages []factset.Package
+		for _, group := range groups {
+			if !group.next.After(started) {
+				due = append(due, group)
+				packages = append(packages, group.packages...)
+			}
+		}
+		if len(packages) == 0 {
+			continue
+		}
+		log.Infof("Starting scheduled load of %s", describePackages(packages))
+		s.loadPackages(ctx, packages)
+		finished := schedulerNow()
+		log.Infof("Finished scheduled load of %s in %s", describePackages(packages), finished.Sub(started))
+
+		for _, group := range due {
+			group.next = group.schedule.Next(started)
+			if group.next.Before(finished) {
+				log.Warnf("Load of %s ran past its next scheduled time %s, skipping to the following one", describePackages(group.packages), group.next.Format(time.RFC3339))
+				group.next = group.schedule.Next(finished)
+			}
+		}
+
+		if ctx.Err() != nil {
+			log.Info("Scheduler stopped")
+			return nil
+		}
+	}
+}
+
+func describePackages(packages []factset.Package) string {
+	var products []string
+	for _, pkg := range packages {
+		products = append(products, pkg.Product)
+	}
+	return strings.Join(products, ", ")
+}
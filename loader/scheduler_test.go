@@ -0,0 +1,168 @@
+package loader
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+var otherPkg = factset.Package{
+	Dataset:     "ppl",
+	FSPackage:   "people",
+	Product:     "ppl_other",
+	Bundle:      "ppl_test",
+	FeedVersion: 1,
+}
+
+// slowFactsetService advances the fake clock as each package is loaded, to simulate long loads
+type slowFactsetService struct {
+	*MockFactsetService
+	clock *time.Time
+	delay time.Duration
+}
+
+func (s slowFactsetService) GetSchemaInfo(ctx context.Context, pkg factset.Package) (*factset.PackageVersion, error) {
+	*s.clock = s.clock.Add(s.delay)
+	return s.MockFactsetService.GetSchemaInfo(ctx, pkg)
+}
+
+func Test_RunScheduled(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		packages         []factset.Package
+		schedule         string
+		productSchedules string
+		loadTime         time.Duration
+		expectedLoads    []string
+	}{
+		{
+			testName:         "Loads packages when their schedules fire",
+			packages:         []factset.Package{standardPkg, otherPkg},
+			schedule:         "0 * * * *",
+			productSchedules: "ppl_other=30 */2 * * *",
+			expectedLoads:    []string{"ppl_other", "ppl_test", "ppl_test", "ppl_other", "ppl_test"},
+		},
+		{
+			testName:         "Loads packages sharing a schedule in one run",
+			packages:         []factset.Package{standardPkg, otherPkg},
+			schedule:         "@hourly",
+			productSchedules: "",
+			expectedLoads:    []string{"ppl_test", "ppl_other", "ppl_test", "ppl_other", "ppl_test", "ppl_other"},
+		},
+		{
+			// loads start at 10:30, 11:15, 12:00 and 12:45
+			testName:      "Skips fire times missed during a load",
+			packages:      []factset.Package{standardPkg},
+			schedule:      "*/15 * * * *",
+			loadTime:      40 * time.Minute,
+			expectedLoads: []string{"ppl_test", "ppl_test", "ppl_test", "ppl_test"},
+		},
+	}
+	defer func() {
+		schedulerNow = time.Now
+		schedulerAfter = time.After
+	}()
+	for _, d := range testCases {
+		root, err := ioutil.TempDir("", "factset-workspace")
+		assert.NoError(t, err)
+		workspace, err := OpenWorkspace(root, false)
+		assert.NoError(t, err)
+
+		clock := time.Date(2018, time.January, 10, 10, 15, 0, 0, time.UTC)
+		end := time.Date(2018, time.January, 10, 13, 10, 0, 0, time.UTC)
+		ctx, stop := context.WithCancel(context.Background())
+		schedulerNow = func() time.Time { return clock }
+		schedulerAfter = func(wait time.Duration) <-chan time.Time {
+			if wait > 0 {
+				clock = clock.Add(wait)
+			}
+			if clock.After(end) {
+				stop()
+				return make(chan time.Time)
+			}
+			fired := make(chan time.Time, 1)
+			fired <- clock
+			return fired
+		}
+
+		global, byProduct, err := ParseSchedules(d.schedule, d.productSchedules)
+		assert.NoError(t, err, "Test %s failed, unexpected error", d.testName)
+		config := Config{packages: d.packages}
+		config.SetSchedules(global, byProduct)
+		store := newMemoryStore()
+		service := slowFactsetService{
+			MockFactsetService: &MockFactsetService{fileList: filesInDirectory, schemaInfo: standardSchema},
+			clock:              &clock,
+			delay:              d.loadTime,
+		}
+		loader := NewService(config, store, service, workspace)
+		assert.NoError(t, loader.RunScheduled(ctx), "Test %s failed, unexpected error", d.testName)
+
+		var loads []string
+		for _, h := range store.history {
+			if h.Table == "" {
+				loads = append(loads, h.Package.Product)
+			}
+		}
+		assert.Equal(t, d.expectedLoads, loads, "Test %s failed, wrong packages loaded", d.testName)
+		os.RemoveAll(root)
+	}
+}
+
+func Test_RunScheduledRejectsIncompleteSchedules(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		schedule         string
+		productSchedules string
+		expectedError    string
+	}{
+		{"No schedule", "", "", "product ppl_test has no schedule"},
+		{"Package without a schedule", "", "ppl_other=@daily", "product ppl_test has no schedule"},
+		{"Unknown product", "@daily", "ppl_unknown=@daily", "a schedule is given for product ppl_unknown, which is not configured"},
+	}
+	for _, d := range testCases {
+		global, byProduct, err := ParseSchedules(d.schedule, d.productSchedules)
+		assert.NoError(t, err, "Test %s failed, unexpected error", d.testName)
+		config := Config{packages: []factset.Package{standardPkg, otherPkg}}
+		config.SetSchedules(global, byProduct)
+		loader := NewService(config, newMemoryStore(), &MockFactsetService{}, nil)
+		err = loader.RunScheduled(context.Background())
+		if assert.Error(t, err, "Test %s failed, expected an error", d.testName) {
+			assert.Equal(t, d.expectedError, err.Error(), "Test %s failed, wrong error", d.testName)
+		}
+	}
+}
+
+func TestParseSchedules(t *testing.T) {
+	global, byProduct, err := ParseSchedules("0 6 * * *", " ppl_premium = 0 */4 * * mon-fri ; ent_entity_advanced=@weekly;")
+	assert.NoError(t, err)
+	assert.Equal(t, "0 6 * * *", global.String())
+	assert.Len(t, byProduct, 2)
+	assert.Equal(t, "0 */4 * * mon-fri", byProduct["ppl_premium"].String())
+	assert.Equal(t, "@weekly", byProduct["ent_entity_advanced"].String())
+
+	global, byProduct, err = ParseSchedules("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, global)
+	assert.Empty(t, byProduct)
+
+	testCases := []struct {
+		name     string
+		global   string
+		products string
+	}{
+		{"InvalidGlobal", "0 6 * *", ""},
+		{"MissingExpression", "", "ppl_premium"},
+		{"MissingProduct", "", "=@daily"},
+		{"InvalidProductSchedule", "", "ppl_premium=61 * * * *"},
+	}
+	for _, d := range testCases {
+		_, _, err := ParseSchedules(d.global, d.products)
+		assert.Error(t, err, "Test %s failed, expected an error", d.name)
+	}
+}
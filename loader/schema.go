@@ -0,0 +1,70 @@
+package loader
+
+import (
+	"context"
+
+	"github.com/Financial-Times/factset-uploader/ddl"
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// migrateSchema compares the new schema with the definitions recorded when the package's tables were created,
+// and applies the changes in place if none of them are destructive. It reports whether the tables were migrated;
+// if not they must be rebuilt from the schema. The changes applied are returned so the caller can tell whether
+// the migrated tables need their data reloading.
+func (s *Service) migrateSchema(ctx context.Context, pkg factset.Package, contents []byte) (bool, ddl.SchemaDiff, error) {
+	schema, changes, migratable, err := s.schemaChanges(ctx, pkg, contents)
+	if err != nil {
+		return false, ddl.SchemaDiff{}, err
+	}
+	if !migratable {
+		if changes.IsDestructive() {
+			log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Schema changes for %s need its tables to be rebuilt: %s", pkg.Product, changes)
+		}
+		return false, ddl.SchemaDiff{}, nil
+	}
+	if changes.IsEmpty() {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Schema for %s has not changed", pkg.Product)
+		return true, changes, nil
+	}
+	if err := s.db.ApplySchemaChanges(ctx, changes, schema, pkg); err != nil {
+		return false, ddl.SchemaDiff{}, err
+	}
+	return true, changes, nil
+}
+
+// addsData reports whether the changes add tables or columns. Added tables are empty and added columns are
+// null until the data is loaded again, so the package must be reloaded even if its data is up to date.
+func addsData(changes ddl.SchemaDiff) bool {
+	if len(changes.AddedTables) > 0 {
+		return true
+	}
+	for _, table := range changes.ChangedTables {
+		if len(table.AddedColumns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaChanges parses the new schema and compares it with the recorded table definitions. The tables can be
+// migrated in place only if definitions are recorded and none of the changes are destructive.
+func (s *Service) schemaChanges(ctx context.Context, pkg factset.Package, contents []byte) (ddl.Schema, ddl.SchemaDiff, bool, error) {
+	schema, err := ddl.Parse(string(contents))
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error parsing schema for %s", pkg.Product)
+		return ddl.Schema{}, ddl.SchemaDiff{}, false, err
+	}
+
+	current, err := s.db.GetTableSchemas(ctx, pkg.Product, pkg.Bundle)
+	if err != nil {
+		return ddl.Schema{}, ddl.SchemaDiff{}, false, err
+	}
+	if len(current) == 0 {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("No table definitions are recorded for %s, so its tables will be rebuilt", pkg.Product)
+		return schema, ddl.SchemaDiff{}, false, nil
+	}
+
+	changes := ddl.Diff(current, schema.Tables)
+	return schema, changes, !changes.IsDestructive(), nil
+}
@@ -0,0 +1,163 @@
+package loader
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+const pplNamesSchema = `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NOT NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type, people_name_value));`
+
+func Test_LoadPackages_SchemaChanges(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		newSchema        string
+		recordDefinition bool
+		expectMigrated   bool
+		// data files in the latest data archive, by name; the fixture archive is used if not set
+		data              map[string]string
+		expectedRows      int
+		expectedColumns   []string
+		expectedLoaded    []string
+		expectedAliasRows int
+	}{
+		{
+			testName:         "Leaves tables alone when the schema has not changed",
+			newSchema:        pplNamesSchema,
+			recordDefinition: true,
+			expectMigrated:   false,
+			expectedRows:     99,
+			expectedColumns:  []string{"FACTSET_PERSON_ID", "people_name_type", "people_name_value"},
+		},
+		{
+			testName: "Migrates index changes in place without reloading",
+			newSchema: pplNamesSchema + `
+CREATE INDEX ppl_names_value ON ppl_names (people_name_value);`,
+			recordDefinition: true,
+			expectMigrated:   true,
+			expectedRows:     99,
+			expectedColumns:  []string{"FACTSET_PERSON_ID", "people_name_type", "people_name_value"},
+		},
+		{
+			testName: "Migrates additive changes in place",
+			newSchema: `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_type VARCHAR(35) NOT NULL,
+ people_name_value VARCHAR(100) NOT NULL,
+ people_name_source VARCHAR(20),
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_type, people_name_value));
+CREATE INDEX ppl_names_source ON ppl_names (people_name_source);
+CREATE TABLE ppl_aliases (FACTSET_PERSON_ID CHAR(8) NOT NULL);`,
+			recordDefinition: true,
+			expectMigrated:   true,
+			data: map[string]string{
+				"ppl_names.txt":   "FACTSET_PERSON_ID|PEOPLE_NAME_TYPE|PEOPLE_NAME_VALUE|PEOPLE_NAME_SOURCE\nABCDEF-P|FIRST|Jane|REG\nABCDEF-P|LAST|Doe|REG\n",
+				"ppl_aliases.txt": "FACTSET_PERSON_ID\nABCDEF-P\n",
+			},
+			expectedRows:      2,
+			expectedColumns:   []string{"FACTSET_PERSON_ID", "people_name_type", "people_name_value", "people_name_source"},
+			expectedLoaded:    []string{"FACTSET_PERSON_ID", "PEOPLE_NAME_TYPE", "PEOPLE_NAME_VALUE", "PEOPLE_NAME_SOURCE"},
+			expectedAliasRows: 1,
+		},
+		{
+			testName: "Rebuilds and reloads tables when a column is removed",
+			newSchema: `CREATE TABLE ppl_names (
+ FACTSET_PERSON_ID CHAR(8) NOT NULL,
+ people_name_value VARCHAR(100) NOT NULL,
+ PRIMARY KEY (FACTSET_PERSON_ID, people_name_value));`,
+			recordDefinition: true,
+			expectedRows:     5,
+			expectedColumns:  []string{"FACTSET_PERSON_ID", "people_name_value"},
+			expectedLoaded:   []string{"FACTSET_PERSON_ID", "PEOPLE_NAME_TYPE", "PEOPLE_NAME_VALUE"},
+		},
+		{
+			testName:        "Rebuilds and reloads tables when no definitions are recorded",
+			newSchema:       pplNamesSchema,
+			expectedRows:    5,
+			expectedColumns: []string{"FACTSET_PERSON_ID", "people_name_type", "people_name_value"},
+			expectedLoaded:  []string{"FACTSET_PERSON_ID", "PEOPLE_NAME_TYPE", "PEOPLE_NAME_VALUE"},
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(root)
+			workspace, err := OpenWorkspace(root, false)
+			assert.NoError(t, err)
+			archives, err := ioutil.TempDir("", "factset-schema")
+			assert.NoError(t, err)
+			defer os.RemoveAll(archives)
+
+			// the data loaded is up to date, so it is only reloaded if the tables are rebuilt or added to
+			store := newMemoryStore()
+			assert.NoError(t, store.CreateTablesFromSchema(context.Background(), []byte(pplNamesSchema), standardPkg))
+			store.tables["ppl_names"].rows = 99
+			if !d.recordDefinition {
+				store.tables["ppl_names"].definition.Name = ""
+			}
+			store.UpdateLoadedPackageVersion(context.Background(), &factset.PackageMetadata{
+				Package:        standardPkg,
+				SchemaVersion:  standardSchema,
+				PackageVersion: filesInDirectory[0].Version,
+			})
+
+			service := &MockFactsetService{
+				fileList:   filesInDirectory,
+				schemaInfo: updatedSequenceSchema,
+				files: map[string]string{
+					"/datafeeds/documents/docs_ppl/ppl_v1_schema_2.zip": createSchemaArchive(t, filepath.Join(archives, "ppl_v1_schema_2.zip"), d.newSchema),
+				},
+			}
+			if d.data != nil {
+				service.files[filesInDirectory[0].Path] = createArchive(t, filepath.Join(archives, filesInDirectory[0].Name), d.data)
+			}
+			loader := NewService(Config{packages: []factset.Package{standardPkg}}, store, service, workspace)
+			loader.LoadPackages(context.Background())
+
+			pm, err := store.GetPackageMetadata(context.Background(), standardPkg)
+			assert.NoError(t, err)
+			assert.Equal(t, updatedSequenceSchema, pm.SchemaVersion, "Test %s failed, schema version was not updated", d.testName)
+			assert.Equal(t, d.expectMigrated, len(store.migrations) == 1, "Test %s failed, unexpected in place migrations %v", d.testName, store.migrations)
+			if assert.NotNil(t, store.tables["ppl_names"], "Test %s failed, ppl_names should exist", d.testName) {
+				assert.Equal(t, d.expectedRows, store.tables["ppl_names"].rows, "Test %s failed, unexpected rows in ppl_names", d.testName)
+				assert.Equal(t, d.expectedColumns, store.tables["ppl_names"].definition.ColumnNames(), "Test %s failed, unexpected columns", d.testName)
+				assert.Equal(t, d.expectedLoaded, store.tables["ppl_names"].loadedColumns, "Test %s failed, unexpected columns loaded into ppl_names", d.testName)
+			}
+			if aliases, ok := store.tables["ppl_aliases"]; ok {
+				assert.Equal(t, d.expectedAliasRows, aliases.rows, "Test %s failed, unexpected rows in ppl_aliases", d.testName)
+			} else {
+				assert.Zero(t, d.expectedAliasRows, "Test %s failed, ppl_aliases should exist", d.testName)
+			}
+		})
+	}
+}
+
+func createSchemaArchive(t *testing.T, path string, schema string) string {
+	return createArchive(t, path, map[string]string{"ppl_v1_schema.sql": schema})
+}
+
+// createArchive writes a zip archive holding the files, by name, and returns its path
+func createArchive(t *testing.T, path string, files map[string]string) string {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	w := zip.NewWriter(f)
+	for name, contents := range files {
+		entry, err := w.Create(name)
+		assert.NoError(t, err)
+		entry.Write([]byte(contents))
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+	return path
+}
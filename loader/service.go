@@ -2,6 +2,8 @@ package loader
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
 	"database/sql"
 	"io"
 	"os"
@@ -19,16 +21,26 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Limits applied when extracting archives so a malformed or malicious archive can not fill the workspace.
+var (
+	maxArchiveEntries                 = 1000
+	maxArchiveUncompressedBytes int64 = 20 << 30
+)
+
 // Service - Logic for loading the data files.
 type Service struct {
 	config    Config
-	workspace string
-	db        *rds.Client
+	workspace *Workspace
+	run       *Run
+	load      *packageLoad
+	db        rds.Storer
 	factset   factset.Servicer
+	notifier  Notifier
+	publisher Publisher
 }
 
 // NewService - Creates a new loader.Service
-func NewService(config Config, db *rds.Client, factset factset.Servicer, workspace string) *Service {
+func NewService(config Config, db rds.Storer, factset factset.Servicer, workspace *Workspace) *Service {
 	return &Service{
 		config:    config,
 		db:        db,
@@ -37,68 +49,146 @@ func NewService(config Config, db *rds.Client, factset factset.Servicer, workspa
 	}
 }
 
-// LoadPackages - Load all packages listed in the config
-func (s *Service) LoadPackages() {
-	//Make sure working directory is clean prior to run
-	err := refreshWorkingDirectory(s.workspace)
+// LoadPackages - Load all packages listed in the config, returning the outcome of each. Once ctx is done the load
+// stops at the next safe point and the packages it did not finish are reported as interrupted.
+func (s *Service) LoadPackages(ctx context.Context) RunSummary {
+	return s.loadPackages(ctx, s.config.packages)
+}
+
+// loadPackages loads the packages in a new run, deferring those that do not fit in the workspace until the others
+// have been loaded
+func (s *Service) loadPackages(ctx context.Context, packages []factset.Package) (summary RunSummary) {
+	summary.Started = time.Now()
+	defer func() {
+		summary.Finished = time.Now()
+		summary.Outcome = summary.outcome()
+		s.notify(summary)
+	}()
+
+	ctx, release, err := s.lock(ctx, LockGlobal, globalLockName)
+	if rds.IsLockHeld(err) {
+		log.WithError(err).Warnf("Skipping %d packages as another uploader is loading", len(packages))
+		summary.Packages = skippedResults(packages, err)
+		return summary
+	}
+	if isInterrupted(ctx, err) {
+		log.Warnf("Stopped waiting for lock %s as the uploader is shutting down", globalLockName)
+		summary.Packages = interruptedResults(packages, err)
+		return summary
+	}
 	if err != nil {
-		log.WithError(err).Errorf("Could not clean up working directory %s prior package load", s.workspace)
-		return
+		log.WithError(err).Errorf("Could not take lock %s before loading packages", globalLockName)
+		summary.Packages = failedResults(packages, stageLock, err)
+		return summary
 	}
+	defer release()
 
-	for _, v := range s.config.packages {
-		err = s.loadPackage(v)
+	if err := s.workspace.RemoveStaleRuns(); err != nil {
+		log.WithError(err).Warnf("Could not remove stale run directories from workspace %s", s.workspace.Root())
+	}
+	run, err := s.workspace.NewRun()
+	if err != nil {
+		log.WithError(err).Errorf("Could not create run directory in workspace %s prior package load", s.workspace.Root())
+		summary.Packages = failedResults(packages, stageWorkspace, err)
+		return summary
+	}
+	s.run = run
+	summary.RunID = run.ID()
+	defer func() {
+		if err := s.run.Finish(); err != nil {
+			log.WithError(err).Errorf("Could not clean up run directory %s after loading packages", s.run.Dir())
+		}
+		s.run = nil
+	}()
+
+	var deferred []factset.Package
+	for i, v := range packages {
+		if err := ctx.Err(); err != nil {
+			log.Warnf("Stopping before loading %s as %s", describePackages(packages[i:]), stopReason(err))
+			summary.Packages = append(summary.Packages, stoppedResults(packages[i:], err)...)
+			summary.Packages = append(summary.Packages, stoppedResults(deferred, err)...)
+			return summary
+		}
+		result, err := s.loadPackage(ctx, v)
+		s.run.Cleanup(result.Status == PackageFailed && !isInsufficientSpace(err))
+		if isInsufficientSpace(err) {
+			log.WithFields(log.Fields{"fs_product": v.Product}).Warnf("Not enough space in workspace to load product %s; deferring until other packages have been loaded", v.Product)
+			deferred = append(deferred, v)
+			continue
+		}
+		summary.Packages = append(summary.Packages, result)
 		if err != nil {
 			log.WithFields(log.Fields{"fs_product": v.Product}).Errorf("An error occurred whilst loading product %s; moving on to next package", v.Product)
 		}
 	}
 
-	//Re clean directory after final package has been loaded
-	err = refreshWorkingDirectory(s.workspace)
-	if err != nil {
-		log.WithError(err).Errorf("Could not clean up working directory %s after loading packages", s.workspace)
-		return
+	for i, v := range deferred {
+		if err := ctx.Err(); err != nil {
+			log.Warnf("Stopping before loading %s as %s", describePackages(deferred[i:]), stopReason(err))
+			summary.Packages = append(summary.Packages, stoppedResults(deferred[i:], err)...)
+			return summary
+		}
+		result, err := s.loadPackage(ctx, v)
+		s.run.Cleanup(result.Status == PackageFailed && !isInsufficientSpace(err))
+		summary.Packages = append(summary.Packages, result)
+		if isInsufficientSpace(err) {
+			log.WithError(err).WithFields(log.Fields{"fs_product": v.Product}).Errorf("Skipping product %s as it does not fit in the workspace", v.Product)
+			continue
+		}
+		if err != nil {
+			log.WithFields(log.Fields{"fs_product": v.Product}).Errorf("An error occurred whilst loading deferred product %s", v.Product)
+		}
 	}
+	return summary
 }
 
-func refreshWorkingDirectory(workspace string) error {
-	log.WithFields(log.Fields{"workspace": workspace}).Info("Refreshing the workspace")
-	d, err := os.Open(workspace)
-	if err != nil {
-		log.WithError(err).Fatalf("Could not open directory %s, can not run application", workspace)
-		return err
+func (s *Service) loadPackage(ctx context.Context, pkg factset.Package) (PackageResult, error) {
+	ctx, release, err := s.lock(ctx, LockPackage, packageLockName(pkg))
+	if rds.IsLockHeld(err) {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Warnf("Skipping product %s as another uploader is loading it", pkg.Product)
+		return skippedResult(pkg, err), nil
+	}
+	if isInterrupted(ctx, err) {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Warnf("Stopped waiting to load product %s as the uploader is shutting down", pkg.Product)
+		return interruptedResults([]factset.Package{pkg}, err)[0], err
 	}
-	defer d.Close()
-	names, err := d.Readdirnames(-1)
 	if err != nil {
-		log.WithError(err).Fatalf("Could not read directory %s, can not run application", workspace)
-		return err
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Could not take lock to load product %s", pkg.Product)
+		return failedResults([]factset.Package{pkg}, stageLock, err)[0], err
 	}
-	for _, name := range names {
-		log.WithFields(log.Fields{"workspace": workspace, "file": name}).Debug("Removing file")
-		if err = os.RemoveAll(filepath.Join(workspace, name)); err != nil {
-			log.WithError(err).Fatalf("Could not clear down directory %s, can not run application", workspace)
-			return err
-		}
+	defer release()
+
+	started := time.Now()
+	s.load = &packageLoad{stage: stageMetadata}
+	err = s.loadLatestVersion(ctx, pkg)
+	loadDuration.WithLabelValues(pkg.Product).Observe(time.Since(started).Seconds())
+	if isInterrupted(ctx, err) {
+		s.load.interrupted = true
+		loadInterrupted.WithLabelValues(pkg.Product).Inc()
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Warnf("Load of product %s was interrupted at the %s stage; it will be loaded again by the next run", pkg.Product, s.load.stage)
+	} else if err != nil {
+		loadFailures.WithLabelValues(pkg.Product, s.load.stage).Inc()
 	}
-	return nil
+	s.recordPackageHistory(pkg, started, err)
+	result := s.packageResult(pkg, started, err)
+	s.load = nil
+	return result, err
 }
 
-func (s *Service) loadPackage(pkg factset.Package) error {
+func (s *Service) loadLatestVersion(ctx context.Context, pkg factset.Package) error {
 	log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Processing %s package", pkg.Product)
 	// Get package metadata
-	if err := s.db.LoadMetadataTables(); err != nil {
-		return err
-	}
 	//TODO make custom error instead of sql error
 	log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Querying db for current metadata for package: %s", pkg.Product)
-	currentlyLoadedPkgMetadata, currentPackageMetadataErr := s.db.GetPackageMetadata(pkg)
+	currentlyLoadedPkgMetadata, currentPackageMetadataErr := s.db.GetPackageMetadata(ctx, pkg)
 	if currentPackageMetadataErr != nil && currentPackageMetadataErr != sql.ErrNoRows {
 		return currentPackageMetadataErr
 	}
+	s.load.before = currentlyLoadedPkgMetadata
+	recordLoadedPackage(pkg, currentlyLoadedPkgMetadata)
 
 	log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Searching factset for most recent package: %s", pkg.Product)
-	schemaVersion, err := s.factset.GetSchemaInfo(pkg)
+	schemaVersion, err := s.factset.GetSchemaInfo(ctx, pkg)
 	if err != nil {
 		return err
 	}
@@ -111,12 +201,23 @@ func (s *Service) loadPackage(pkg factset.Package) error {
 	// This will need to be reworked when delta are handled
 	if isSchemaOutOfDate(schemaVersion, currentlyLoadedPkgMetadata) {
 		log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Schema is out of date")
-		if err = s.reloadSchema(pkg, schemaVersion); err != nil {
+		s.load.stage = stageSchema
+		rebuilt, reloadData, err := s.reloadSchema(ctx, pkg, schemaVersion)
+		if err != nil {
 			return err
 		}
 
 		schemaLastUpdated = time.Now()
-		if loadedVersion, err = s.doFullLoad(pkg, currentlyLoadedPkgMetadata); err != nil {
+		s.load.schemaChange = SchemaMigrated
+		if rebuilt {
+			s.load.schemaChange = SchemaRebuilt
+		}
+		loadFrom := currentlyLoadedPkgMetadata
+		if reloadData {
+			// the recreated or added tables and columns are empty so the data must be loaded even if it is up-to-date
+			loadFrom.PackageVersion = factset.PackageVersion{}
+		}
+		if loadedVersion, err = s.doFullLoad(ctx, pkg, loadFrom); err != nil {
 			return err
 		}
 
@@ -124,7 +225,7 @@ func (s *Service) loadPackage(pkg factset.Package) error {
 	} else {
 		log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Schema is up to date")
 		// Else do an incremental load. which actually does a full load
-		if loadedVersion, err = s.doIncrementalLoad(pkg, currentlyLoadedPkgMetadata); err != nil {
+		if loadedVersion, err = s.doIncrementalLoad(ctx, pkg, currentlyLoadedPkgMetadata); err != nil {
 			return err
 		}
 		schemaLastUpdated = currentlyLoadedPkgMetadata.SchemaLoadedDate
@@ -146,9 +247,14 @@ func (s *Service) loadPackage(pkg factset.Package) error {
 		PackageLoadedDate: packageLastUpdate,
 	}
 
-	if err := s.db.UpdateLoadedPackageVersion(updatedPackageMetadata); err != nil {
+	// Every table has been loaded, so the version is recorded even if the uploader is shutting down
+	s.load.stage = stageUpdate
+	if err := s.db.UpdateLoadedPackageVersion(context.Background(), updatedPackageMetadata); err != nil {
 		return err
 	}
+	s.load.after = *updatedPackageMetadata
+	recordLoadedPackage(pkg, *updatedPackageMetadata)
+	s.publish(*updatedPackageMetadata)
 	log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Updated product %s to data version v%d_%d", pkg.Product, updatedPackageMetadata.PackageVersion.FeedVersion, updatedPackageMetadata.PackageVersion.Sequence)
 	return nil
 }
@@ -158,6 +264,12 @@ func isSchemaOutOfDate(latestSchema *factset.PackageVersion, loadedSchema factse
 		(latestSchema.FeedVersion == loadedSchema.SchemaVersion.FeedVersion && latestSchema.Sequence > loadedSchema.SchemaVersion.Sequence)
 }
 
+// needsDataLoad reports whether the latest data archive should be loaded over the loaded version
+func needsDataLoad(loaded, latest factset.PackageVersion) bool {
+	return loaded.FeedVersion == 0 ||
+		(loaded.FeedVersion == latest.FeedVersion && loaded.Sequence < latest.Sequence)
+}
+
 // Incremental load:
 // Get all incremental files after loaded version.
 // In order
@@ -166,9 +278,9 @@ func isSchemaOutOfDate(latestSchema *factset.PackageVersion, loadedSchema factse
 //      Process delete files
 // Update table metadata
 // Clean up and update package metadata.
-func (s *Service) doIncrementalLoad(pkg factset.Package, currentPackageMetadata factset.PackageMetadata) (factset.PackageVersion, error) {
+func (s *Service) doIncrementalLoad(ctx context.Context, pkg factset.Package, currentPackageMetadata factset.PackageMetadata) (factset.PackageVersion, error) {
 	// TODO: Actually do an incremental load as described above.
-	return s.doFullLoad(pkg, currentPackageMetadata)
+	return s.doFullLoad(ctx, pkg, currentPackageMetadata)
 }
 
 // Full load:
@@ -177,51 +289,56 @@ func (s *Service) doIncrementalLoad(pkg factset.Package, currentPackageMetadata
 // For each file, load into table.
 // Update metadata with new version.
 // Clean up and update package metadata.
-func (s *Service) doFullLoad(pkg factset.Package, currentLoadedFileMetadata factset.PackageMetadata) (factset.PackageVersion, error) {
+func (s *Service) doFullLoad(ctx context.Context, pkg factset.Package, currentLoadedFileMetadata factset.PackageMetadata) (factset.PackageVersion, error) {
 	var loadedVersions factset.PackageVersion
 
-	latestDataArchive, err := s.factset.GetLatestFile(pkg, true)
+	s.load.stage = stageMetadata
+	latestDataArchive, err := s.factset.GetLatestFile(ctx, pkg, true)
 	if err != nil {
 		return loadedVersions, err
 	}
 
-	if currentLoadedFileMetadata.PackageVersion.FeedVersion == 0 ||
-		(currentLoadedFileMetadata.PackageVersion.FeedVersion == latestDataArchive.Version.FeedVersion && currentLoadedFileMetadata.PackageVersion.Sequence < latestDataArchive.Version.Sequence) {
+	s.load.archive = latestDataArchive.Name
+	if needsDataLoad(currentLoadedFileMetadata.PackageVersion, latestDataArchive.Version) {
 
 		//if err = s.db.DropTablesWithProductAndBundle(pkg.Dataset, pkg.Product); err != nil {
 		//	return loadedVersions, err
 		//}
 
+		s.load.version = latestDataArchive.Version
+		s.load.stage = stageDownload
 		var localDataArchive *os.File
-		localDataArchive, err = s.factset.Download(latestDataArchive, pkg.Product)
+		localDataArchive, err = s.download(ctx, latestDataArchive, pkg.Product)
 		if err != nil {
 			return loadedVersions, err
 		}
 
+		s.load.stage = stageUnzip
 		var localDataFiles []string
-		localDataFiles, err = s.unzipFile(localDataArchive, pkg.Product)
+		localDataFiles, err = s.unzipFile(ctx, localDataArchive, pkg.Product)
 		if err != nil {
 			return loadedVersions, err
 		}
 
+		s.load.stage = stageLoad
 		for _, file := range localDataFiles {
-			//TODO version the file name to be table_sequence
-			tableName := getTableFromFilename(file)
-			if err = s.db.DropDataFromTable(tableName, pkg.Product); err != nil {
-				return loadedVersions, err
-			}
-
-			log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Loading table %s with data from file %s", tableName, file)
-			err = s.db.LoadTable(file, tableName)
-			if err != nil {
-				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error whilst loading table %s with data from file %s", tableName, file)
+			// Tables are loaded one at a time, so the load can stop cleanly between them
+			if err = ctx.Err(); err != nil {
 				return loadedVersions, err
 			}
-
-			err = s.db.UpdateLoadedTableVersion(tableName, latestDataArchive.Version, pkg)
+			//TODO version the file name to be table_sequence
+			tableName := getTableFromFilename(file)
+			tableStarted := time.Now()
+			var rows int64
+			rows, err = s.loadDataFile(ctx, pkg, file, tableName, latestDataArchive.Version)
+			s.load.interrupted = isInterrupted(ctx, err)
+			s.recordTableHistory(pkg, tableName, rows, tableStarted, err)
+			s.load.recordTable(tableName, rows, tableStarted, err)
 			if err != nil {
 				return loadedVersions, err
 			}
+			s.load.rows += rows
+			rowsLoaded.WithLabelValues(pkg.Product, tableName).Add(float64(rows))
 
 			loadedVersions.FeedVersion = latestDataArchive.Version.FeedVersion
 			loadedVersions.Sequence = latestDataArchive.Version.Sequence
@@ -231,18 +348,72 @@ func (s *Service) doFullLoad(pkg factset.Package, currentLoadedFileMetadata fact
 	} else {
 		log.Infof("%s data is up-to-date as version v%d_%d has already been loaded into db", pkg.Product, currentLoadedFileMetadata.PackageVersion.FeedVersion, currentLoadedFileMetadata.PackageVersion.Sequence)
 		loadedVersions = currentLoadedFileMetadata.PackageVersion
+		s.load.version = loadedVersions
+		s.load.upToDate = true
 	}
 
 	return loadedVersions, err
 }
 
+// loadDataFile replaces the contents of the table with the data file and reconciles the rows loaded with the
+// rows in the file, returning the number of rows loaded. Once the data has been loaded its counts and version are
+// recorded even if ctx is done, so the metadata matches what is in the table.
+func (s *Service) loadDataFile(ctx context.Context, pkg factset.Package, file, tableName string, version factset.PackageVersion) (int64, error) {
+	format, err := s.fileFormat(pkg, file, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	expected, err := rds.CountDataRows(file, format)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Could not count rows in data file %s", file)
+		return 0, err
+	}
+
+	if err := s.db.DropDataFromTable(ctx, tableName, pkg.Product); err != nil {
+		return 0, err
+	}
+
+	log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Loading table %s with data from file %s", tableName, file)
+	result, err := s.db.LoadTable(ctx, file, tableName, format)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error whilst loading table %s with data from file %s", tableName, file)
+		return 0, err
+	}
+	for _, message := range result.WarningMessages {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Warnf("Warning whilst loading table %s: %s", tableName, message)
+	}
+
+	counts := rds.TableRowCounts{Expected: expected, Loaded: result.Rows, Warnings: result.Warnings}
+	warning, err := s.config.reconciliationThresholds().check(counts)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Table %s failed reconciliation with data file %s", tableName, file)
+		if err := s.db.UpdateLoadedTableRowCounts(context.Background(), tableName, counts); err != nil {
+			return result.Rows, err
+		}
+		return result.Rows, fmt.Errorf("table %s failed reconciliation: %s", tableName, err)
+	}
+	if warning != "" {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Warnf("Table %s %s", tableName, warning)
+	}
+
+	if err := s.db.UpdateLoadedTableVersion(context.Background(), tableName, version, pkg); err != nil {
+		return result.Rows, err
+	}
+	if err := s.db.UpdateLoadedTableRowCounts(context.Background(), tableName, counts); err != nil {
+		return result.Rows, err
+	}
+	return result.Rows, nil
+}
+
 func getTableFromFilename(filename string) string {
-	return filename[strings.LastIndex(filename, "/")+1 : strings.LastIndex(filename, ".")]
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
 }
 
-func (s *Service) unzipFile(file *os.File, product string) ([]string, error) {
+func (s *Service) unzipFile(ctx context.Context, file *os.File, product string) ([]string, error) {
 	var filenames []string
 
+	started := time.Now()
 	zipReader, err := zip.OpenReader(file.Name())
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not open archive: %s", file.Name())
@@ -250,91 +421,177 @@ func (s *Service) unzipFile(file *os.File, product string) ([]string, error) {
 	}
 	defer zipReader.Close()
 
+	if len(zipReader.File) > maxArchiveEntries {
+		err := fmt.Errorf("archive %s has %d entries which exceeds the limit of %d", file.Name(), len(zipReader.File), maxArchiveEntries)
+		log.WithFields(log.Fields{"fs_product": product}).Error(err)
+		return []string{}, err
+	}
+
+	if err := s.ensureSpace(declaredUncompressedSize(zipReader.File), "extract "+filepath.Base(file.Name()), product); err != nil {
+		log.WithFields(log.Fields{"fs_product": product}).Error(err)
+		return []string{}, err
+	}
+
+	workspace, err := filepath.Abs(s.run.Dir())
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not resolve run directory %s", s.run.Dir())
+		return []string{}, err
+	}
+
+	remaining := maxArchiveUncompressedBytes
 	for _, f := range zipReader.File {
-		fpath, _ := filepath.Abs(filepath.Join(s.workspace, f.Name))
+		if err := ctx.Err(); err != nil {
+			return []string{}, err
+		}
+		fpath, err := archiveEntryPath(workspace, f.Name)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Refusing to extract entry from archive %s", file.Name())
+			return []string{}, err
+		}
 
-		if err := copyFile(f, fpath); err != nil {
-			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not copy %s to %s", file.Name(), s.workspace)
+		mode := f.FileInfo().Mode()
+		if mode.IsDir() {
+			if err := s.run.MkdirAll(fpath); err != nil {
+				log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not create directory %s", fpath)
+				return []string{}, err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			err := fmt.Errorf("archive entry %s is not a regular file", f.Name)
+			log.WithFields(log.Fields{"fs_product": product}).Errorf("Refusing to extract entry from archive %s: %s", file.Name(), err)
+			return []string{}, err
+		}
+		if filepath.Ext(fpath) == "" {
+			// Tables are named after their data files less the extension, so anything else is not ours to load
+			log.WithFields(log.Fields{"fs_product": product}).Warnf("Skipping archive entry %s without a file extension in %s", f.Name, file.Name())
+			continue
+		}
+		if f.UncompressedSize64 > uint64(remaining) {
+			err := fmt.Errorf("archive %s exceeds the uncompressed size limit of %d bytes", file.Name(), maxArchiveUncompressedBytes)
+			log.WithFields(log.Fields{"fs_product": product}).Error(err)
 			return []string{}, err
 		}
+
+		if err := s.run.MkdirAll(filepath.Dir(fpath)); err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not create directory for %s", fpath)
+			return []string{}, err
+		}
+		s.run.Track(fpath)
+		written, err := copyFile(f, fpath, remaining)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Could not copy %s to %s", file.Name(), s.run.Dir())
+			return []string{}, err
+		}
+		remaining -= written
 		filenames = append(filenames, fpath)
 	}
 
-	log.WithFields(log.Fields{"fs_product": product}).Debugf("Unzipped archive %s into %s", file.Name(), s.workspace)
+	unzipDuration.WithLabelValues(product).Observe(time.Since(started).Seconds())
+	log.WithFields(log.Fields{"fs_product": product}).Debugf("Unzipped archive %s into %s", file.Name(), s.run.Dir())
 	return filenames, nil
 }
 
-func copyFile(srcFile *zip.File, dest string) error {
+// archiveEntryPath resolves an archive entry name against the workspace, rejecting any name that would
+// end up outside of it.
+func archiveEntryPath(workspace string, name string) (string, error) {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return "", fmt.Errorf("archive entry %q has an absolute or empty path", name)
+	}
+	fpath := filepath.Join(workspace, name)
+	rel, err := filepath.Rel(workspace, fpath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %q resolves outside of the workspace", name)
+	}
+	return fpath, nil
+}
+
+// copyFile extracts srcFile to dest, writing at most limit bytes so that an entry which lies about its
+// uncompressed size can not fill the volume.
+func copyFile(srcFile *zip.File, dest string, limit int64) (int64, error) {
 	rc, err := srcFile.Open()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer rc.Close()
 
-	f, err := os.OpenFile(
-		dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer f.Close()
 
-	if srcFile.FileInfo().IsDir() {
-		err = os.MkdirAll(dest, os.ModePerm)
-		if err != nil {
-			return err
-		}
-	} else {
-		var fdir string
-		if lastIndex := strings.LastIndex(dest, string(os.PathSeparator)); lastIndex > -1 {
-			fdir = dest[:lastIndex]
-		}
-
-		err = os.MkdirAll(fdir, os.ModePerm)
-		if err != nil {
-			log.Fatal(err)
-			return err
-		}
+	written, err := io.Copy(f, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return written, err
 	}
-
-	_, err = io.Copy(f, rc)
-	return err
+	if written > limit {
+		return written, fmt.Errorf("archive entry %s exceeds the uncompressed size limit", srcFile.Name)
+	}
+	return written, f.Close()
 }
 
-// Reloading schema:
-// Delete all tables with applicable prefix
-// Download new schema and unzip
-// Run new table creation script - ent_v1_table_generation_statements.sql
-func (s *Service) reloadSchema(pkg factset.Package, schemaVersion *factset.PackageVersion) error {
+// reloadSchema brings the tables of the package up to the schema version, migrating them in place when the
+// changes are additive and otherwise dropping and recreating them. It reports whether the tables were recreated,
+// and whether the package data must be reloaded because the tables were recreated or tables or columns were added.
+func (s *Service) reloadSchema(ctx context.Context, pkg factset.Package, schemaVersion *factset.PackageVersion) (bool, bool, error) {
 	log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Reloading schema for package: %s", pkg.Product)
-	if err := s.db.DropTablesWithProductAndBundle(pkg.Product, pkg.Bundle); err != nil {
-		return err
+	schemaContents, err := s.downloadSchema(ctx, pkg, schemaVersion)
+	if err != nil {
+		return false, false, err
 	}
+
+	// The tables are changed as a whole even if the uploader starts shutting down part way, as dropped or half
+	// migrated tables would leave the package unusable until the next run
+	if err := ctx.Err(); err != nil {
+		return false, false, err
+	}
+	ctx = context.Background()
+	migrated, changes, err := s.migrateSchema(ctx, pkg, schemaContents)
+	if err != nil {
+		return false, false, err
+	}
+	if migrated {
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Migrated schema for product %s to version v%d_%d", pkg.Product, schemaVersion.FeedVersion, schemaVersion.Sequence)
+		return false, addsData(changes), nil
+	}
+
+	if err := s.db.DropTablesWithProductAndBundle(ctx, pkg.Product, pkg.Bundle); err != nil {
+		return false, false, err
+	}
+	if err := s.db.CreateTablesFromSchema(ctx, schemaContents, pkg); err != nil {
+		return false, false, err
+	}
+	log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Updated schema for product %s to version v%d_%d", pkg.Product, schemaVersion.FeedVersion, schemaVersion.Sequence)
+	return true, true, nil
+}
+
+// downloadSchema downloads and unzips the schema archive of the version, returning its table creation scripts
+func (s *Service) downloadSchema(ctx context.Context, pkg factset.Package, schemaVersion *factset.PackageVersion) ([]byte, error) {
 	schemaFileDetails := s.getSchemaDetails(pkg, schemaVersion)
-	schemaFileArchive, err := s.factset.Download(*schemaFileDetails, pkg.Product)
+	schemaFileArchive, err := s.download(ctx, *schemaFileDetails, pkg.Product)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	schemaFiles, err := s.unzipFile(schemaFileArchive, pkg.Product)
+	schemaFiles, err := s.unzipFile(ctx, schemaFileArchive, pkg.Product)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var contents [][]byte
 	for _, file := range schemaFiles {
 		if strings.HasSuffix(file, ".sql") {
 			fileContents, err := ioutil.ReadFile(file)
 			if err != nil {
 				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Could not read file: %s", file)
-				return err
+				return nil, err
 			}
-			err = s.db.CreateTablesFromSchema(fileContents, pkg)
-			if err != nil {
-				return err
-			}
-			log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Updated schema for product %s to version v%d_%d", pkg.Product, schemaVersion.FeedVersion, schemaVersion.Sequence)
+			contents = append(contents, fileContents)
 		}
 	}
-	return nil
+	// files are separated so a statement missing its semicolon does not run into the next file
+	return bytes.Join(contents, []byte("\n;\n")), nil
 }
 
 func (s *Service) getSchemaDetails(pkg factset.Package, schemaVersion *factset.PackageVersion) *factset.FSFile {
@@ -1,8 +1,13 @@
 package loader
 
 import (
+	"archive/zip"
+	"context"
+	"database/sql"
 	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -159,15 +164,14 @@ func Test_LoadPackage(t *testing.T) {
 	}
 	for _, d := range testCases {
 		t.Run(d.testName, func(t *testing.T) {
-			os.Mkdir("../fixtures/tmp", 0700)
 			defer os.RemoveAll("../fixtures/tmp")
 			defer dropTable(dbClient, "ppl_names")
 			defer removeMetadataTables(dbClient)
 
+			err := dbClient.Migrate()
+			assert.NoError(t, err, "Test %s failed, could not migrate metadata tables with error: ", d.testName, err)
 			if !d.freshLoad {
-				err := dbClient.LoadMetadataTables()
-				assert.NoError(t, err, "Test %s failed, could not load metadata tables with error: ", d.testName, err)
-				err = dbClient.UpdateLoadedPackageVersion(&d.existingPackageMetadata)
+				err = dbClient.UpdateLoadedPackageVersion(context.Background(), &d.existingPackageMetadata)
 				assert.NoError(t, err, "Test %s failed, could not pre load package metadata table with error: ", d.testName, err)
 			}
 
@@ -176,9 +180,13 @@ func Test_LoadPackage(t *testing.T) {
 				assert.NoError(t, err, "Test %s failed, could not load ppl_names table with error: ", d.testName, err)
 			}
 
-			loader := NewService(Config{[]factset.Package{d.pkg}}, dbClient, d.factsetService, "../fixtures/tmp")
+			workspace, err := OpenWorkspace("../fixtures/tmp", false)
+			assert.NoError(t, err, "Test %s failed, could not open workspace", d.testName)
+			loader := NewService(Config{packages: []factset.Package{d.pkg}}, dbClient, d.factsetService, workspace)
+			loader.run, err = workspace.NewRun()
+			assert.NoError(t, err, "Test %s failed, could not create run directory", d.testName)
 
-			err := loader.loadPackage(d.pkg)
+			_, err = loader.loadPackage(context.Background(), d.pkg)
 
 			if d.expectedError != nil {
 				assert.Errorf(t, err, "Test %s failed, should have resulted in an error", d.testName)
@@ -187,7 +195,7 @@ func Test_LoadPackage(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 
-				pm, err := dbClient.GetPackageMetadata(d.pkg)
+				pm, err := dbClient.GetPackageMetadata(context.Background(), d.pkg)
 				assert.NoError(t, err, "Test %s failed, could not retrieve metadata for package with error: ", d.testName, err)
 				assert.Equal(t, d.expectedSchemaFeedVersion, pm.SchemaVersion.FeedVersion, "Test %s failed, schema feed version was not updated", d.testName)
 				assert.Equal(t, d.expectedSchemaSequence, pm.SchemaVersion.Sequence, "Test %s failed, schema sequence was not updated", d.testName)
@@ -197,6 +205,267 @@ func Test_LoadPackage(t *testing.T) {
 	}
 }
 
+func Test_LoadPackages(t *testing.T) {
+	testCases := []struct {
+		testName                string
+		existingPackageMetadata *factset.PackageMetadata
+		storeErrs               map[string]error
+		expectLoaded            bool
+		expectedPackageSequence int
+		expectedRows            int
+		// table and outcome of each load history record, in the order written
+		expectedHistory [][2]string
+	}{
+		{
+			testName:                "Loads schema and data into an empty store",
+			expectLoaded:            true,
+			expectedPackageSequence: 1234,
+			expectedRows:            5,
+			expectedHistory:         [][2]string{{"ppl_names", rds.OutcomeSucceeded}, {"", rds.OutcomeSucceeded}},
+		},
+		{
+			testName:                "Does not reload data that is already up to date",
+			existingPackageMetadata: &freshPackageMetadata,
+			expectLoaded:            true,
+			expectedPackageSequence: 1250,
+			expectedHistory:         [][2]string{{"", rds.OutcomeUpToDate}},
+		},
+		{
+			testName:        "Does not record a version when a table fails to load",
+			storeErrs:       map[string]error{"LoadTable": errors.New("could not load table")},
+			expectLoaded:    false,
+			expectedHistory: [][2]string{{"ppl_names", rds.OutcomeFailed}, {"", rds.OutcomeFailed}},
+		},
+		{
+			testName:                "Still loads when the load history can not be written",
+			storeErrs:               map[string]error{"RecordLoadHistory": errors.New("could not record history")},
+			expectLoaded:            true,
+			expectedPackageSequence: 1234,
+			expectedRows:            5,
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(root)
+			workspace, err := OpenWorkspace(root, false)
+			assert.NoError(t, err)
+
+			store := newMemoryStore()
+			for method, err := range d.storeErrs {
+				store.errs[method] = err
+			}
+			if d.existingPackageMetadata != nil {
+				store.UpdateLoadedPackageVersion(context.Background(), d.existingPackageMetadata)
+			}
+
+			loader := NewService(Config{packages: []factset.Package{standardPkg}}, store, getFactsetService(filesInDirectory, standardSchema, nil), workspace)
+			loader.LoadPackages(context.Background())
+
+			var history [][2]string
+			for _, entry := range store.history {
+				history = append(history, [2]string{entry.Table, entry.Outcome})
+				assert.Equal(t, store.history[0].RunID, entry.RunID, "Test %s failed, history records should share the run id", d.testName)
+				assert.Equal(t, standardPkg, entry.Package, "Test %s failed, history recorded against the wrong package", d.testName)
+			}
+			assert.Equal(t, d.expectedHistory, history, "Test %s failed, unexpected load history", d.testName)
+			if len(store.history) > 0 {
+				last := store.history[len(store.history)-1]
+				assert.True(t, strings.HasPrefix(last.RunID, runDirPrefix), "Test %s failed, run id should name the run directory", d.testName)
+				assert.Equal(t, int64(d.expectedRows), last.RowCount, "Test %s failed, unexpected rows in package history", d.testName)
+			}
+
+			pm, err := store.GetPackageMetadata(context.Background(), standardPkg)
+			if !d.expectLoaded {
+				assert.Equal(t, sql.ErrNoRows, err, "Test %s failed, package version should not have been recorded", d.testName)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, d.expectedPackageSequence, pm.PackageVersion.Sequence, "Test %s failed, unexpected package sequence", d.testName)
+			if d.expectedRows > 0 {
+				assert.Equal(t, d.expectedRows, store.tables["ppl_names"].rows, "Test %s failed, unexpected rows loaded", d.testName)
+				assert.Equal(t, filesInDirectory[0].Version, store.tables["ppl_names"].version, "Test %s failed, unexpected table version", d.testName)
+			}
+
+			names, err := readDirNames(root)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{workspaceMarker}, names, "Test %s failed, run directory was not cleaned up", d.testName)
+		})
+	}
+}
+
+func Test_UnzipFile(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		entries       []string
+		maxEntries    int
+		maxBytes      int64
+		expectedFiles []string
+		expectedError string
+	}{
+		{
+			testName:      "Extracts files and creates directory entries",
+			entries:       []string{"ppl_v1_schema_12/", "ppl_v1_schema_12/ppl_v1_schema.sql", "ppl_names.txt"},
+			expectedFiles: []string{"ppl_v1_schema_12/ppl_v1_schema.sql", "ppl_names.txt"},
+		},
+		{
+			testName:      "Skips files without an extension",
+			entries:       []string{"README", "ppl_v1_schema_12/LICENSE", "ppl_names.txt"},
+			expectedFiles: []string{"ppl_names.txt"},
+		},
+		{
+			testName:      "Rejects entries that escape the workspace",
+			entries:       []string{"../../evil.txt"},
+			expectedError: "resolves outside of the workspace",
+		},
+		{
+			testName:      "Rejects entries with an absolute path",
+			entries:       []string{"/tmp/evil.txt"},
+			expectedError: "absolute or empty path",
+		},
+		{
+			testName:      "Rejects archives with too many entries",
+			entries:       []string{"a.txt", "b.txt", "c.txt"},
+			maxEntries:    2,
+			expectedError: "exceeds the limit of 2",
+		},
+		{
+			testName:      "Rejects archives that exceed the uncompressed size limit",
+			entries:       []string{"a.txt", "b.txt"},
+			maxBytes:      20,
+			expectedError: "uncompressed size limit",
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "factset-unzip")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			workspace := filepath.Join(dir, "factset")
+			assert.NoError(t, os.Mkdir(workspace, 0755))
+
+			if d.maxEntries > 0 {
+				defer func(v int) { maxArchiveEntries = v }(maxArchiveEntries)
+				maxArchiveEntries = d.maxEntries
+			}
+			if d.maxBytes > 0 {
+				defer func(v int64) { maxArchiveUncompressedBytes = v }(maxArchiveUncompressedBytes)
+				maxArchiveUncompressedBytes = d.maxBytes
+			}
+
+			archive := createTestArchive(t, filepath.Join(dir, "test.zip"), d.entries)
+			defer archive.Close()
+
+			loader := &Service{run: &Run{dir: workspace}}
+			files, err := loader.unzipFile(context.Background(), archive, "ppl_test")
+			if d.expectedError != "" {
+				assert.Error(t, err, "Test %s failed, should have resulted in an error", d.testName)
+				assert.Contains(t, err.Error(), d.expectedError, "Test %s failed, returned unexpected error", d.testName)
+				_, statErr := os.Stat(filepath.Join(dir, "evil.txt"))
+				assert.True(t, os.IsNotExist(statErr), "Test %s failed, file was written outside of the workspace", d.testName)
+				return
+			}
+			assert.NoError(t, err)
+			var expected []string
+			for _, f := range d.expectedFiles {
+				expected = append(expected, filepath.Join(workspace, f))
+			}
+			assert.Equal(t, expected, files, "Test %s failed, unexpected files extracted", d.testName)
+			for _, f := range expected {
+				_, err := os.Stat(f)
+				assert.NoError(t, err, "Test %s failed, extracted file is missing", d.testName)
+			}
+		})
+	}
+}
+
+func Test_GetTableFromFilename(t *testing.T) {
+	testCases := []struct {
+		filename      string
+		expectedTable string
+	}{
+		{"/vol/factset/run-1/ppl_names.txt", "ppl_names"},
+		{"ppl_names.txt", "ppl_names"},
+		{"/vol/factset/run-1/ppl_v1_full_1234/ppl_names.v2.txt", "ppl_names.v2"},
+		{"/vol/factset/run-1/ppl_names", "ppl_names"},
+	}
+	for _, d := range testCases {
+		assert.Equal(t, d.expectedTable, getTableFromFilename(d.filename), "Test %s failed, unexpected table name", d.filename)
+	}
+}
+
+func Test_UnzipFile_InsufficientSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factset-unzip")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(f func(string) (uint64, error)) { freeSpace = f }(freeSpace)
+	freeSpace = func(string) (uint64, error) { return workspaceReserveBytes + 10, nil }
+
+	archive := createTestArchive(t, filepath.Join(dir, "test.zip"), []string{"ppl_names.txt"})
+	defer archive.Close()
+
+	loader := &Service{run: &Run{dir: dir}}
+	_, err = loader.unzipFile(context.Background(), archive, "ppl_test")
+	assert.Error(t, err)
+	assert.True(t, isInsufficientSpace(err), "Expected an insufficient space error but got: %v", err)
+	_, statErr := os.Stat(filepath.Join(dir, "ppl_names.txt"))
+	assert.True(t, os.IsNotExist(statErr), "Nothing should be extracted when the archive does not fit")
+}
+
+func Test_Download_InsufficientSpace(t *testing.T) {
+	defer func(f func(string) (uint64, error)) { freeSpace = f }(freeSpace)
+	freeSpace = func(string) (uint64, error) { return workspaceReserveBytes + 10, nil }
+
+	file := filesInDirectory[0]
+	file.Size = 100
+	loader := &Service{run: &Run{dir: "../fixtures/tmp"}, factset: getFactsetService(filesInDirectory, standardSchema, nil)}
+	_, err := loader.download(context.Background(), file, "ppl_test")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient space in workspace ../fixtures/tmp to download ppl_test_v1_full_1234.zip")
+
+	file.Size = 10
+	f, err := loader.download(context.Background(), file, "ppl_test")
+	assert.NoError(t, err)
+	f.Close()
+}
+
+func createTestArchive(t *testing.T, path string, entries []string) *os.File {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	w := zip.NewWriter(f)
+	for _, name := range entries {
+		entry, err := w.Create(name)
+		assert.NoError(t, err)
+		if !strings.HasSuffix(name, "/") {
+			entry.Write([]byte("FACTSET_PERSON_ID|PEOPLE_NAME_TYPE\n"))
+		}
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, f.Close())
+
+	archive, err := os.Open(path)
+	assert.NoError(t, err)
+	return archive
+}
+
+// newTestLoader - a loader of the configured packages from the fixtures into a memory store, which returns the errors
+// by method, working in a new temporary workspace. The returned function removes the workspace.
+func newTestLoader(t *testing.T, config Config, storeErrs map[string]error) (*Service, *MemoryStore, func()) {
+	root, err := ioutil.TempDir("", "factset-workspace")
+	assert.NoError(t, err)
+	workspace, err := OpenWorkspace(root, false)
+	assert.NoError(t, err)
+
+	store := newMemoryStore()
+	for method, err := range storeErrs {
+		store.errs[method] = err
+	}
+	loader := NewService(config, store, getFactsetService(filesInDirectory, standardSchema, nil), workspace)
+	return loader, store, func() { os.RemoveAll(root) }
+}
+
 func getFactsetService(fileList []factset.FSFile, packageVersion factset.PackageVersion, err error) factset.Servicer {
 	return &MockFactsetService{
 		fileList:   fileList,
@@ -209,13 +478,23 @@ type MockFactsetService struct {
 	fileList   []factset.FSFile
 	schemaInfo factset.PackageVersion
 	err        error
+	// local files to download in place of the fixtures, by FactSet path
+	files map[string]string
 }
 
-func (s *MockFactsetService) GetSchemaInfo(pkg factset.Package) (*factset.PackageVersion, error) {
+func (s *MockFactsetService) GetSchemaInfo(ctx context.Context, pkg factset.Package) (*factset.PackageVersion, error) {
 	return &s.schemaInfo, s.err
 }
 
-func (s *MockFactsetService) GetLatestFile(pkg factset.Package, isFullLoad bool) (factset.FSFile, error) {
+func (s *MockFactsetService) Discover(ctx context.Context) ([]factset.RemoteBundle, error) {
+	return nil, s.err
+}
+
+func (s *MockFactsetService) CheckConnectivity(ctx context.Context) error {
+	return s.err
+}
+
+func (s *MockFactsetService) GetLatestFile(ctx context.Context, pkg factset.Package, isFullLoad bool) (factset.FSFile, error) {
 
 	var latestFile factset.FSFile
 
@@ -225,7 +504,13 @@ func (s *MockFactsetService) GetLatestFile(pkg factset.Package, isFullLoad bool)
 	return latestFile, nil
 }
 
-func (s *MockFactsetService) Download(file factset.FSFile, product string) (*os.File, error) {
+func (s *MockFactsetService) Download(ctx context.Context, file factset.FSFile, dest string, product string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if local, ok := s.files[file.Path]; ok {
+		return os.Open(local)
+	}
 	wd, _ := os.Getwd()
 	log.Info(wd)
 	return os.Open("../fixtures" + file.Path)
@@ -247,13 +532,16 @@ func pickLatestFile(f1 factset.FSFile, f2 factset.FSFile, pkg factset.Package) f
 	return factset.FSFile{}
 }
 
+// createDBClient connects to RDS_DSN when it is set, otherwise to an SQLite database in a temporary
+// directory so the tests can run without a database server
 func createDBClient() *rds.Client {
 	var testDSN string
 
 	if os.Getenv("RDS_DSN") != "" {
 		testDSN = os.Getenv("RDS_DSN")
 	} else {
-		testDSN = "root:@/test"
+		dir, _ := ioutil.TempDir("", "factset-loader")
+		testDSN = "sqlite://" + filepath.Join(dir, "test.db")
 	}
 
 	dbClient, _ := rds.NewClient(testDSN)
@@ -261,15 +549,13 @@ func createDBClient() *rds.Client {
 }
 
 func dropTable(dbClient *rds.Client, tables ...string) {
-	if len(tables) == 0 {
-		return
+	for _, table := range tables {
+		dbClient.DB.Exec("DROP TABLE IF EXISTS " + table)
 	}
-	query := "DROP TABLE " + strings.Join(tables, ", ")
-	dbClient.DB.Exec(query)
 }
 
 func removeMetadataTables(dbClient *rds.Client) {
-	dbClient.DB.Exec(`DROP TABLE IF EXISTS metadata_package_version, metadata_table_version`)
+	dropTable(dbClient, "metadata_package_version", "metadata_table_version", "metadata_schema_version", "metadata_load_history", "metadata_lock", "metadata_table_schema")
 }
 
 func createPplNamesTable(dbClient *rds.Client) error {
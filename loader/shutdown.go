@@ -0,0 +1,25 @@
+package loader
+
+import (
+	"context"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+)
+
+// isInterrupted reports whether err was returned because ctx is done, i.e. the load was stopped because the
+// uploader is shutting down rather than because it failed. A load stopped because its lock was lost has failed.
+func isInterrupted(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && !rds.IsLockLost(ctx.Err())
+}
+
+// interruptedResults marks every package as interrupted before it was started
+func interruptedResults(packages []factset.Package, err error) []PackageResult {
+	var results []PackageResult
+	for _, pkg := range packages {
+		loadInterrupted.WithLabelValues(pkg.Product).Inc()
+		results = append(results, PackageResult{Package: pkg, Status: PackageInterrupted, Started: time.Now(), Error: err.Error()})
+	}
+	return results
+}
@@ -0,0 +1,66 @@
+package loader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadPackagesInterrupted(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		cancelBefore     bool
+		cancelAtTable    string
+		expectedStatuses []string
+		expectedStages   []string
+		expectedHistory  []string
+	}{
+		{
+			testName:         "Loads nothing once already shutting down",
+			cancelBefore:     true,
+			expectedStatuses: []string{PackageInterrupted, PackageInterrupted},
+			expectedStages:   []string{"", ""},
+		},
+		{
+			testName:         "Stops loading a table and the packages after it",
+			cancelAtTable:    "ppl_names",
+			expectedStatuses: []string{PackageInterrupted, PackageInterrupted},
+			expectedStages:   []string{stageLoad, ""},
+			expectedHistory:  []string{rds.OutcomeInterrupted, rds.OutcomeInterrupted},
+		},
+	}
+	for _, d := range testCases {
+		ctx, cancel := context.WithCancel(context.Background())
+		if d.cancelBefore {
+			cancel()
+		}
+		loader, store, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg, otherPkg}}, nil)
+		store.beforeLoad = func(_ context.Context, tableName string) {
+			if tableName == d.cancelAtTable {
+				cancel()
+			}
+		}
+		summary := loader.LoadPackages(ctx)
+		cancel()
+
+		assert.Equal(t, RunInterrupted, summary.Outcome, "Test %s failed, wrong outcome", d.testName)
+		var statuses, stages []string
+		for _, p := range summary.Packages {
+			statuses = append(statuses, p.Status)
+			stages = append(stages, p.ErrorStage)
+			assert.Equal(t, context.Canceled.Error(), p.Error, "Test %s failed, wrong error", d.testName)
+		}
+		assert.Equal(t, d.expectedStatuses, statuses, "Test %s failed, wrong statuses", d.testName)
+		assert.Equal(t, d.expectedStages, stages, "Test %s failed, wrong stages", d.testName)
+		var history []string
+		for _, h := range store.history {
+			history = append(history, h.Outcome)
+		}
+		assert.Equal(t, d.expectedHistory, history, "Test %s failed, wrong load history", d.testName)
+		assert.Empty(t, store.packages, "Test %s failed, no package version should be recorded", d.testName)
+		cleanup()
+	}
+}
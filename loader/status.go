@@ -0,0 +1,125 @@
+package loader
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+)
+
+// How the loaded data of a package compares with the latest on the Factset server
+const (
+	StatusUpToDate  = "up-to-date"
+	StatusBehind    = "behind"
+	StatusNotLoaded = "not-loaded"
+	StatusError     = "error"
+)
+
+// PackageStatus - the loaded versions of a package against the latest versions on the Factset server
+type PackageStatus struct {
+	Package       factset.Package        `json:"package"`
+	Status        string                 `json:"status"`
+	LoadedSchema  factset.PackageVersion `json:"loadedSchema"`
+	LatestSchema  factset.PackageVersion `json:"latestSchema"`
+	LoadedData    factset.PackageVersion `json:"loadedData"`
+	LatestData    factset.PackageVersion `json:"latestData"`
+	LatestArchive string                 `json:"latestArchive,omitempty"`
+	// SchemaLoaded and DataLoaded are zero if the package has not been loaded
+	SchemaLoaded time.Time `json:"schemaLoaded"`
+	DataLoaded   time.Time `json:"dataLoaded"`
+	Published    time.Time `json:"published"`
+	// SchemaSequencesBehind and SequencesBehind count the schema and data versions published since those loaded
+	SchemaSequencesBehind int `json:"schemaSequencesBehind"`
+	SequencesBehind       int `json:"sequencesBehind"`
+	// DaysBehind is how many whole days ago the latest data archive was published, if it has not been loaded
+	DaysBehind int    `json:"daysBehind"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Status - compares the versions loaded of each configured package with the latest on the Factset server.
+// A package that can not be compared is reported with StatusError rather than failing the whole report.
+func (s *Service) Status(ctx context.Context) []PackageStatus {
+	now := time.Now()
+	var statuses []PackageStatus
+	for _, pkg := range s.config.packages {
+		statuses = append(statuses, s.statusAt(ctx, pkg, now))
+	}
+	return statuses
+}
+
+// PackageStatus - compares the versions loaded of the package with the latest on the Factset server
+func (s *Service) PackageStatus(ctx context.Context, pkg factset.Package) PackageStatus {
+	return s.statusAt(ctx, pkg, time.Now())
+}
+
+func (s *Service) statusAt(ctx context.Context, pkg factset.Package, now time.Time) PackageStatus {
+	status, err := s.packageStatus(ctx, pkg, now)
+	if err != nil {
+		status.Status = StatusError
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (s *Service) packageStatus(ctx context.Context, pkg factset.Package, now time.Time) (PackageStatus, error) {
+	status := PackageStatus{Package: pkg}
+
+	loaded, err := s.db.GetPackageMetadata(ctx, pkg)
+	if err != nil && err != sql.ErrNoRows {
+		return status, err
+	}
+	status.LoadedSchema = loaded.SchemaVersion
+	status.LoadedData = loaded.PackageVersion
+	status.SchemaLoaded = loaded.SchemaLoadedDate
+	status.DataLoaded = loaded.PackageLoadedDate
+
+	latestSchema, err := s.factset.GetSchemaInfo(ctx, pkg)
+	if err != nil {
+		return status, err
+	}
+	status.LatestSchema = *latestSchema
+
+	latestData, err := s.factset.GetLatestFile(ctx, pkg, true)
+	if err != nil {
+		return status, err
+	}
+	status.LatestData = latestData.Version
+	status.LatestArchive = latestData.Name
+	status.Published = latestData.ModTime
+
+	if loaded.PackageVersion.FeedVersion == 0 {
+		status.Status = StatusNotLoaded
+		status.DaysBehind = daysBetween(latestData.ModTime, now)
+		return status, nil
+	}
+
+	if loaded.SchemaVersion.FeedVersion == latestSchema.FeedVersion {
+		status.SchemaSequencesBehind = sequencesBehind(loaded.SchemaVersion.Sequence, latestSchema.Sequence)
+	}
+	status.SequencesBehind = sequencesBehind(loaded.PackageVersion.Sequence, latestData.Version.Sequence)
+	if status.SequencesBehind == 0 && status.SchemaSequencesBehind == 0 && !isSchemaOutOfDate(latestSchema, loaded) {
+		status.Status = StatusUpToDate
+		return status, nil
+	}
+	status.Status = StatusBehind
+	if status.SequencesBehind > 0 {
+		status.DaysBehind = daysBetween(latestData.ModTime, now)
+	}
+	return status, nil
+}
+
+func sequencesBehind(loaded, latest int) int {
+	if latest > loaded {
+		return latest - loaded
+	}
+	return 0
+}
+
+// daysBetween returns the whole days from to until, or 0 if either is unknown or until is earlier
+func daysBetween(from, until time.Time) int {
+	if from.IsZero() || until.IsZero() || until.Before(from) {
+		return 0
+	}
+	return int(until.Sub(from) / (24 * time.Hour))
+}
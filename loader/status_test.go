@@ -0,0 +1,91 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Status(t *testing.T) {
+	published := time.Now().Add(-36 * time.Hour)
+	latestFile := factset.FSFile{
+		Name:    "ppl_test_v1_full_1236.zip",
+		Version: factset.PackageVersion{FeedVersion: 1, Sequence: 1236},
+		IsFull:  true,
+		ModTime: published,
+	}
+
+	testCases := []struct {
+		testName          string
+		loaded            *factset.PackageMetadata
+		err               error
+		expectedStatus    string
+		expectedSchema    int
+		expectedSequences int
+		expectedDays      int
+	}{
+		{
+			testName:       "Not loaded",
+			expectedStatus: StatusNotLoaded,
+			expectedDays:   1,
+		},
+		{
+			testName: "Up to date",
+			loaded: &factset.PackageMetadata{Package: standardPkg, SchemaVersion: updatedSequenceSchema, PackageVersion: latestFile.Version,
+				PackageLoadedDate: published.Add(time.Hour)},
+			expectedStatus: StatusUpToDate,
+		},
+		{
+			testName: "Data behind",
+			loaded: &factset.PackageMetadata{Package: standardPkg, SchemaVersion: updatedSequenceSchema, PackageVersion: filesInDirectory[0].Version,
+				PackageLoadedDate: published.Add(-73 * time.Hour)},
+			expectedStatus:    StatusBehind,
+			expectedSequences: 2,
+			expectedDays:      1,
+		},
+		{
+			testName: "Schema behind",
+			loaded: &factset.PackageMetadata{Package: standardPkg, SchemaVersion: standardSchema, PackageVersion: latestFile.Version,
+				PackageLoadedDate: published.Add(time.Hour)},
+			expectedStatus: StatusBehind,
+			expectedSchema: 1,
+		},
+		{
+			testName:       "Factset error",
+			err:            errors.New("connection refused"),
+			expectedStatus: StatusError,
+		},
+	}
+	for _, d := range testCases {
+		store := newMemoryStore()
+		if d.loaded != nil {
+			store.UpdateLoadedPackageVersion(context.Background(), d.loaded)
+		}
+		service := &MockFactsetService{
+			fileList:   []factset.FSFile{filesInDirectory[0], latestFile},
+			schemaInfo: updatedSequenceSchema,
+			err:        d.err,
+		}
+		loader := NewService(Config{packages: []factset.Package{standardPkg}}, store, service, nil)
+		statuses := loader.Status(context.Background())
+		if !assert.Len(t, statuses, 1, "Test %s failed, expected a status for each package", d.testName) {
+			continue
+		}
+		status := statuses[0]
+		assert.Equal(t, standardPkg, status.Package)
+		assert.Equal(t, d.expectedStatus, status.Status, "Test %s failed, wrong status: %s", d.testName, status.Error)
+		assert.Equal(t, d.expectedSchema, status.SchemaSequencesBehind, "Test %s failed, wrong schema sequences behind", d.testName)
+		assert.Equal(t, d.expectedSequences, status.SequencesBehind, "Test %s failed, wrong sequences behind", d.testName)
+		assert.Equal(t, d.expectedDays, status.DaysBehind, "Test %s failed, wrong days behind", d.testName)
+		if d.err != nil {
+			assert.Equal(t, d.err.Error(), status.Error)
+		} else {
+			assert.Equal(t, latestFile.Version, status.LatestData, "Test %s failed, wrong latest data", d.testName)
+			assert.Equal(t, latestFile.Name, status.LatestArchive)
+		}
+	}
+}
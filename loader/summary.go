@@ -0,0 +1,165 @@
+package loader
+
+import (
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/rds"
+)
+
+// The outcome of loading a package, as recorded in its load history
+const (
+	PackageSucceeded = rds.OutcomeSucceeded
+	PackageUpToDate  = rds.OutcomeUpToDate
+	PackageFailed    = rds.OutcomeFailed
+	// PackageSkipped packages were not loaded because another uploader held their lock
+	PackageSkipped = "skipped"
+	// PackageInterrupted packages were not loaded, or not finished, because the uploader was shutting down
+	PackageInterrupted = rds.OutcomeInterrupted
+)
+
+// The outcome of a run as a whole
+const (
+	RunSucceeded      = "succeeded"
+	RunPartialFailure = "partial-failure"
+	RunFailed         = "failed"
+	// RunInterrupted runs were stopped before every package had been loaded
+	RunInterrupted = "interrupted"
+)
+
+// How the tables of a package were brought up to the latest schema
+const (
+	SchemaMigrated = "migrated"
+	SchemaRebuilt  = "rebuilt"
+)
+
+// stageWorkspace is the stage packages fail at when the run directory can not be created
+const stageWorkspace = "workspace"
+
+// RunSummary - the outcome of loading each package in a run
+type RunSummary struct {
+	RunID    string          `json:"runId,omitempty"`
+	Outcome  string          `json:"outcome"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished"`
+	Packages []PackageResult `json:"packages"`
+}
+
+// PackageResult - what loading a package did. The versions after are the versions before unless the load succeeded.
+type PackageResult struct {
+	Package factset.Package `json:"package"`
+	Status  string          `json:"status"`
+	Archive string          `json:"archive,omitempty"`
+	// SchemaChange is SchemaMigrated or SchemaRebuilt if the schema was out of date, otherwise empty
+	SchemaChange string                 `json:"schemaChange,omitempty"`
+	SchemaBefore factset.PackageVersion `json:"schemaBefore"`
+	SchemaAfter  factset.PackageVersion `json:"schemaAfter"`
+	DataBefore   factset.PackageVersion `json:"dataBefore"`
+	DataAfter    factset.PackageVersion `json:"dataAfter"`
+	// LastLoaded is when the package data was last loaded successfully, which is zero if it never has been
+	LastLoaded      time.Time     `json:"lastLoaded"`
+	Tables          []TableResult `json:"tables,omitempty"`
+	Rows            int64         `json:"rows"`
+	Started         time.Time     `json:"started"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	// ErrorStage is the stage the load failed or was interrupted at: lock, workspace, metadata, schema, download,
+	// unzip, load or update. It is empty for packages interrupted before they were started.
+	ErrorStage string `json:"errorStage,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TableResult - the outcome of loading a table from the data archive
+type TableResult struct {
+	Table           string  `json:"table"`
+	Rows            int64   `json:"rows"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Failed - the packages that failed to load
+func (r RunSummary) Failed() []PackageResult {
+	var failed []PackageResult
+	for _, p := range r.Packages {
+		if p.Status == PackageFailed {
+			failed = append(failed, p)
+		}
+	}
+	return failed
+}
+
+// Interrupted - the packages that were not loaded, or not finished, because the uploader was shutting down
+func (r RunSummary) Interrupted() []PackageResult {
+	var interrupted []PackageResult
+	for _, p := range r.Packages {
+		if p.Status == PackageInterrupted {
+			interrupted = append(interrupted, p)
+		}
+	}
+	return interrupted
+}
+
+// outcome is RunInterrupted if any package was interrupted, RunFailed if every package failed, RunPartialFailure
+// if some did and otherwise RunSucceeded
+func (r RunSummary) outcome() string {
+	failed := len(r.Failed())
+	switch {
+	case len(r.Interrupted()) > 0:
+		return RunInterrupted
+	case failed == 0:
+		return RunSucceeded
+	case failed == len(r.Packages):
+		return RunFailed
+	}
+	return RunPartialFailure
+}
+
+// packageResult builds the result of the package from what happened whilst loading it
+func (s *Service) packageResult(pkg factset.Package, started time.Time, err error) PackageResult {
+	result := PackageResult{
+		Package:         pkg,
+		Status:          PackageSucceeded,
+		Archive:         s.load.archive,
+		SchemaChange:    s.load.schemaChange,
+		SchemaBefore:    s.load.before.SchemaVersion,
+		SchemaAfter:     s.load.before.SchemaVersion,
+		DataBefore:      s.load.before.PackageVersion,
+		DataAfter:       s.load.before.PackageVersion,
+		LastLoaded:      s.load.before.PackageLoadedDate,
+		Tables:          s.load.tables,
+		Rows:            s.load.rows,
+		Started:         started,
+		DurationSeconds: time.Since(started).Seconds(),
+	}
+	if err != nil {
+		result.Status = PackageFailed
+		if s.load.interrupted {
+			result.Status = PackageInterrupted
+		}
+		result.ErrorStage = s.load.stage
+		result.Error = err.Error()
+		return result
+	}
+	result.SchemaAfter = s.load.after.SchemaVersion
+	result.DataAfter = s.load.after.PackageVersion
+	result.LastLoaded = s.load.after.PackageLoadedDate
+	if s.load.upToDate {
+		result.Status = PackageUpToDate
+	}
+	return result
+}
+
+// failedResults marks every package as failed at the stage with the error
+func failedResults(packages []factset.Package, stage string, err error) []PackageResult {
+	var results []PackageResult
+	for _, pkg := range packages {
+		loadFailures.WithLabelValues(pkg.Product, stage).Inc()
+		results = append(results, PackageResult{
+			Package:    pkg,
+			Status:     PackageFailed,
+			Started:    time.Now(),
+			ErrorStage: stage,
+			Error:      err.Error(),
+		})
+	}
+	return results
+}
@@ -0,0 +1,107 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadPackagesSummary(t *testing.T) {
+	testCases := []struct {
+		testName         string
+		existing         *factset.PackageMetadata
+		storeErrs        map[string]error
+		expectedOutcome  string
+		expectedStatus   string
+		expectedSchema   string
+		expectedBefore   factset.PackageVersion
+		expectedAfter    factset.PackageVersion
+		expectedTables   []string
+		expectedRows     int64
+		expectedStage    string
+		expectedErrorMsg string
+	}{
+		{
+			testName:        "Summarises a package loaded into an empty store",
+			expectedOutcome: RunSucceeded,
+			expectedStatus:  PackageSucceeded,
+			expectedSchema:  SchemaRebuilt,
+			expectedAfter:   factset.PackageVersion{FeedVersion: 1, Sequence: 1234},
+			expectedTables:  []string{"ppl_names"},
+			expectedRows:    5,
+		},
+		{
+			testName:        "Summarises a package that is up to date",
+			existing:        &freshPackageMetadata,
+			expectedOutcome: RunSucceeded,
+			expectedStatus:  PackageUpToDate,
+			expectedBefore:  freshPackageMetadata.PackageVersion,
+			expectedAfter:   freshPackageMetadata.PackageVersion,
+		},
+		{
+			testName:         "Summarises a package that failed to load a table",
+			existing:         &stalePackageMetadata,
+			storeErrs:        map[string]error{"LoadTable": errors.New("could not load table")},
+			expectedOutcome:  RunFailed,
+			expectedStatus:   PackageFailed,
+			expectedBefore:   stalePackageMetadata.PackageVersion,
+			expectedAfter:    stalePackageMetadata.PackageVersion,
+			expectedTables:   []string{"ppl_names"},
+			expectedStage:    stageLoad,
+			expectedErrorMsg: "could not load table",
+		},
+	}
+	for _, d := range testCases {
+		loader, store, cleanup := newTestLoader(t, Config{packages: []factset.Package{standardPkg}}, d.storeErrs)
+		if d.existing != nil {
+			store.UpdateLoadedPackageVersion(context.Background(), d.existing)
+		}
+		summary := loader.LoadPackages(context.Background())
+
+		assert.Equal(t, d.expectedOutcome, summary.Outcome, "Test %s failed, wrong run outcome", d.testName)
+		assert.NotEmpty(t, summary.RunID, "Test %s failed, run id missing", d.testName)
+		assert.False(t, summary.Finished.Before(summary.Started), "Test %s failed, run finished before it started", d.testName)
+		if assert.Len(t, summary.Packages, 1, "Test %s failed, wrong number of packages", d.testName) {
+			result := summary.Packages[0]
+			assert.Equal(t, standardPkg, result.Package, "Test %s failed, wrong package", d.testName)
+			assert.Equal(t, d.expectedStatus, result.Status, "Test %s failed, wrong status", d.testName)
+			assert.Equal(t, d.expectedSchema, result.SchemaChange, "Test %s failed, wrong schema change", d.testName)
+			assert.Equal(t, d.expectedBefore, result.DataBefore, "Test %s failed, wrong version before", d.testName)
+			assert.Equal(t, d.expectedAfter, result.DataAfter, "Test %s failed, wrong version after", d.testName)
+			var tables []string
+			for _, table := range result.Tables {
+				tables = append(tables, table.Table)
+			}
+			assert.Equal(t, d.expectedTables, tables, "Test %s failed, wrong tables", d.testName)
+			assert.Equal(t, d.expectedRows, result.Rows, "Test %s failed, wrong rows", d.testName)
+			assert.Equal(t, d.expectedStage, result.ErrorStage, "Test %s failed, wrong error stage", d.testName)
+			assert.Equal(t, d.expectedErrorMsg, result.Error, "Test %s failed, wrong error", d.testName)
+		}
+		cleanup()
+	}
+}
+
+func TestRunSummaryOutcome(t *testing.T) {
+	succeeded := PackageResult{Status: PackageSucceeded}
+	upToDate := PackageResult{Status: PackageUpToDate}
+	failed := PackageResult{Status: PackageFailed}
+	interrupted := PackageResult{Status: PackageInterrupted}
+	testCases := []struct {
+		name     string
+		packages []PackageResult
+		expected string
+	}{
+		{"NoPackages", nil, RunSucceeded},
+		{"AllSucceeded", []PackageResult{succeeded, upToDate}, RunSucceeded},
+		{"SomeFailed", []PackageResult{succeeded, failed}, RunPartialFailure},
+		{"AllFailed", []PackageResult{failed, failed}, RunFailed},
+		{"Interrupted", []PackageResult{succeeded, failed, interrupted}, RunInterrupted},
+	}
+	for _, d := range testCases {
+		summary := RunSummary{Packages: d.packages}
+		assert.Equal(t, d.expected, summary.outcome(), "Test %s failed, wrong outcome", d.name)
+	}
+}
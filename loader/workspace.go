@@ -0,0 +1,265 @@
+package loader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// workspaceMarker - file written to the root of a workspace to prove it is managed by this application
+	workspaceMarker = ".factset-uploader"
+	// keepMarker - file written to a run directory whose artifacts have been kept after a failure
+	keepMarker = ".keep"
+	// heartbeatMarker - file in a run directory whose modification time is renewed while the run is in progress
+	heartbeatMarker = ".heartbeat"
+	runDirPrefix    = "run-"
+	// runHeartbeatInterval - how often a run in progress renews its heartbeat
+	runHeartbeatInterval = time.Minute
+	// staleRunAge - how long a run directory may go without a heartbeat before it is treated as left behind
+	staleRunAge = 10 * runHeartbeatInterval
+)
+
+// Workspace - a directory owned by the uploader which each run downloads and extracts files into
+type Workspace struct {
+	root          string
+	keepOnFailure bool
+}
+
+// OpenWorkspace - Opens the workspace at root, claiming it if it is new or empty. A directory that
+// has content but no marker file is refused so that we never delete files we did not create. Run directories
+// left by previous runs are not touched until RemoveStaleRuns is called.
+func OpenWorkspace(root string, keepOnFailure bool) (*Workspace, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(root, 0755); err != nil {
+		log.WithError(err).Errorf("Could not create workspace %s", root)
+		return nil, err
+	}
+
+	marker := filepath.Join(root, workspaceMarker)
+	if _, err = os.Stat(marker); os.IsNotExist(err) {
+		names, err := readDirNames(root)
+		if err != nil {
+			log.WithError(err).Errorf("Could not read workspace %s", root)
+			return nil, err
+		}
+		if len(names) > 0 {
+			return nil, fmt.Errorf("workspace %s is not empty and has no %s marker file; refusing to manage it", root, workspaceMarker)
+		}
+		contents := fmt.Sprintf("This directory is managed by factset-uploader, run directories within it may be deleted.\nClaimed: %s\n", time.Now().UTC().Format(time.RFC3339))
+		if err = ioutil.WriteFile(marker, []byte(contents), 0644); err != nil {
+			log.WithError(err).Errorf("Could not claim workspace %s", root)
+			return nil, err
+		}
+		log.WithFields(log.Fields{"workspace": root}).Info("Claimed new workspace")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Workspace{root: root, keepOnFailure: keepOnFailure}, nil
+}
+
+// Root - the root directory of the workspace
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// CheckWritable - checks that files can be created in the workspace by writing and removing a temporary file
+func (w *Workspace) CheckWritable() error {
+	f, err := ioutil.TempFile(w.root, ".check-")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, err = f.WriteString("ok")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// RemoveStaleRuns - deletes run directories left behind by runs that did not finish, leaving alone any
+// that were kept for debugging, any whose heartbeat shows they may still be in progress, possibly in another
+// uploader sharing the workspace, and anything else in the workspace.
+func (w *Workspace) RemoveStaleRuns() error {
+	names, err := readDirNames(w.root)
+	if err != nil {
+		log.WithError(err).Errorf("Could not read workspace %s", w.root)
+		return err
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, runDirPrefix) {
+			continue
+		}
+		dir := filepath.Join(w.root, name)
+		if _, err := os.Stat(filepath.Join(dir, keepMarker)); err == nil {
+			log.WithFields(log.Fields{"workspace": w.root, "run": name}).Warn("Leaving run directory kept from a failed run, delete it once it is no longer needed")
+			continue
+		}
+		if alive, err := isRunAlive(dir); err != nil {
+			log.WithError(err).Errorf("Could not check whether run directory %s is in progress", dir)
+			return err
+		} else if alive {
+			log.WithFields(log.Fields{"workspace": w.root, "run": name}).Debug("Leaving run directory of a run in progress")
+			continue
+		}
+		log.WithFields(log.Fields{"workspace": w.root, "run": name}).Info("Removing run directory left by a previous run")
+		if err := os.RemoveAll(dir); err != nil {
+			log.WithError(err).Errorf("Could not remove stale run directory %s", dir)
+			return err
+		}
+	}
+	return nil
+}
+
+// isRunAlive reports whether the run in dir has renewed its heartbeat recently. A directory without a heartbeat
+// is judged by its own modification time, so a run that has only just been created is not mistaken for a stale one.
+func isRunAlive(dir string) (bool, error) {
+	fi, err := os.Stat(filepath.Join(dir, heartbeatMarker))
+	if os.IsNotExist(err) {
+		fi, err = os.Stat(dir)
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(fi.ModTime()) < staleRunAge, nil
+}
+
+// NewRun - Creates a new run directory in the workspace, renewing its heartbeat until the run is finished
+func (w *Workspace) NewRun() (*Run, error) {
+	dir, err := ioutil.TempDir(w.root, runDirPrefix+time.Now().UTC().Format("20060102T150405")+"-")
+	if err != nil {
+		log.WithError(err).Errorf("Could not create run directory in workspace %s", w.root)
+		return nil, err
+	}
+	heartbeat := filepath.Join(dir, heartbeatMarker)
+	host, _ := os.Hostname()
+	contents := fmt.Sprintf("Run in progress on %s, process %d\n", host, os.Getpid())
+	if err = ioutil.WriteFile(heartbeat, []byte(contents), 0644); err != nil {
+		log.WithError(err).Errorf("Could not write heartbeat of run directory %s", dir)
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	log.WithFields(log.Fields{"workspace": w.root, "run": filepath.Base(dir)}).Info("Created run directory")
+	r := &Run{dir: dir, keepOnFailure: w.keepOnFailure, done: make(chan struct{})}
+	go r.heartbeat(heartbeat)
+	return r, nil
+}
+
+// Run - a run directory and the files created within it
+type Run struct {
+	dir           string
+	keepOnFailure bool
+	created       []string
+	failed        bool
+	done          chan struct{}
+}
+
+// heartbeat renews the modification time of the heartbeat file until the run is finished
+func (r *Run) heartbeat(path string) {
+	ticker := time.NewTicker(runHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(path, now, now); err != nil {
+				log.WithError(err).WithFields(log.Fields{"run": r.dir}).Warn("Could not renew run heartbeat")
+			}
+		}
+	}
+}
+
+// Dir - the directory files for this run should be written to
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+// ID - identifies the run in the load history; it is the name of the run directory so kept runs can be found
+func (r *Run) ID() string {
+	return filepath.Base(r.dir)
+}
+
+// Track - record a file created during the run so it is removed by Cleanup
+func (r *Run) Track(path string) {
+	r.created = append(r.created, path)
+}
+
+// MkdirAll - like os.MkdirAll but tracks every directory it creates
+func (r *Run) MkdirAll(path string) error {
+	if fi, err := os.Stat(path); err == nil {
+		if !fi.IsDir() {
+			return fmt.Errorf("%s exists and is not a directory", path)
+		}
+		return nil
+	}
+	if parent := filepath.Dir(path); parent != path {
+		if err := r.MkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	r.Track(path)
+	return nil
+}
+
+// Cleanup - removes the files created since the last clean up. When failed is set and the workspace
+// keeps failed runs, the files are left in place and the run directory is marked as kept instead.
+func (r *Run) Cleanup(failed bool) {
+	if failed && r.keepOnFailure {
+		r.failed = true
+		log.WithFields(log.Fields{"run": r.dir}).Warnf("Keeping %d files from failed load for debugging", len(r.created))
+		r.created = nil
+		return
+	}
+	for i := len(r.created) - 1; i >= 0; i-- {
+		if err := os.Remove(r.created[i]); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).WithFields(log.Fields{"run": r.dir}).Warnf("Could not remove %s", r.created[i])
+		}
+	}
+	r.created = nil
+}
+
+// Finish - cleans up what is left of the run, removing the run directory unless it has been kept
+func (r *Run) Finish() error {
+	close(r.done)
+	if r.failed {
+		contents := fmt.Sprintf("Kept after a failed run: %s\n", time.Now().UTC().Format(time.RFC3339))
+		if err := ioutil.WriteFile(filepath.Join(r.dir, keepMarker), []byte(contents), 0644); err != nil {
+			log.WithError(err).Errorf("Could not mark run directory %s as kept", r.dir)
+			return err
+		}
+		log.WithFields(log.Fields{"run": r.dir}).Warn("Run directory has been kept for debugging")
+		return nil
+	}
+	r.Cleanup(false)
+	if err := os.RemoveAll(r.dir); err != nil {
+		log.WithError(err).Errorf("Could not remove run directory %s", r.dir)
+		return err
+	}
+	return nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	return d.Readdirnames(-1)
+}
@@ -0,0 +1,164 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OpenWorkspace(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		setup         func(t *testing.T, root string)
+		expectedError string
+	}{
+		{
+			testName: "Claims a workspace that does not exist yet",
+			setup: func(t *testing.T, root string) {
+				assert.NoError(t, os.RemoveAll(root))
+			},
+		},
+		{
+			testName: "Claims an empty workspace",
+		},
+		{
+			testName: "Refuses a workspace with content and no marker",
+			setup: func(t *testing.T, root string) {
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "important.txt"), []byte("not ours"), 0644))
+			},
+			expectedError: "refusing to manage it",
+		},
+		{
+			testName: "Opens a workspace that has already been claimed",
+			setup: func(t *testing.T, root string) {
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(root, workspaceMarker), []byte{}, 0644))
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "important.txt"), []byte("not ours"), 0644))
+			},
+		},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			root := filepath.Join(dir, "factset")
+			assert.NoError(t, os.Mkdir(root, 0755))
+			if d.setup != nil {
+				d.setup(t, root)
+			}
+
+			ws, err := OpenWorkspace(root, false)
+			if d.expectedError != "" {
+				assert.Error(t, err, "Test %s failed, should have resulted in an error", d.testName)
+				assert.Contains(t, err.Error(), d.expectedError, "Test %s failed, returned unexpected error", d.testName)
+				_, err = os.Stat(filepath.Join(root, "important.txt"))
+				assert.NoError(t, err, "Test %s failed, existing content was touched", d.testName)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, root, ws.Root())
+			_, err = os.Stat(filepath.Join(root, workspaceMarker))
+			assert.NoError(t, err, "Test %s failed, workspace marker is missing", d.testName)
+		})
+	}
+}
+
+func Test_Workspace_RemoveStaleRuns(t *testing.T) {
+	root, err := ioutil.TempDir("", "factset-workspace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, workspaceMarker), []byte{}, 0644))
+	old := time.Now().Add(-2 * staleRunAge)
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "run-stale", "ppl_v1_schema_12"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "run-stale", heartbeatMarker), []byte{}, 0644))
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "run-stale", heartbeatMarker), old, old))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "run-old"), 0755))
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "run-old"), old, old))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "run-kept"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(root, "run-kept", keepMarker), []byte{}, 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "notes"), 0755))
+
+	ws, err := OpenWorkspace(root, false)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(root, "run-stale"))
+	assert.NoError(t, err, "Opening the workspace should not remove run directories")
+
+	live, err := ws.NewRun()
+	assert.NoError(t, err)
+	defer live.Finish()
+	assert.NoError(t, ws.RemoveStaleRuns())
+
+	_, err = os.Stat(filepath.Join(root, "run-stale"))
+	assert.True(t, os.IsNotExist(err), "Stale run directory should have been removed")
+	_, err = os.Stat(filepath.Join(root, "run-old"))
+	assert.True(t, os.IsNotExist(err), "Old run directory without a heartbeat should have been removed")
+	_, err = os.Stat(live.Dir())
+	assert.NoError(t, err, "Run directory in progress should have been left in place")
+	_, err = os.Stat(filepath.Join(root, "run-kept"))
+	assert.NoError(t, err, "Kept run directory should have been left in place")
+	_, err = os.Stat(filepath.Join(root, "notes"))
+	assert.NoError(t, err, "Directories not created by a run should have been left in place")
+}
+
+func Test_Run_Cleanup(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		keepOnFailure bool
+		failed        bool
+		expectKept    bool
+	}{
+		{"Removes created files after a successful load", false, false, false},
+		{"Removes created files after a failed load by default", false, true, false},
+		{"Keeps created files after a failed load when asked to", true, true, true},
+		{"Removes created files after a successful load when keeping failures", true, false, false},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "factset-workspace")
+			assert.NoError(t, err)
+			defer os.RemoveAll(root)
+
+			ws, err := OpenWorkspace(root, d.keepOnFailure)
+			assert.NoError(t, err)
+			run, err := ws.NewRun()
+			assert.NoError(t, err)
+
+			extracted := filepath.Join(run.Dir(), "ppl_v1_schema_12", "ppl_v1_schema.sql")
+			assert.NoError(t, run.MkdirAll(filepath.Dir(extracted)))
+			run.Track(extracted)
+			assert.NoError(t, ioutil.WriteFile(extracted, []byte("CREATE TABLE ppl_names"), 0644))
+
+			run.Cleanup(d.failed)
+			_, err = os.Stat(filepath.Dir(extracted))
+			assert.Equal(t, d.expectKept, err == nil, "Test %s failed, unexpected state of extracted files", d.testName)
+
+			assert.NoError(t, run.Finish())
+			_, err = os.Stat(run.Dir())
+			assert.Equal(t, d.expectKept, err == nil, "Test %s failed, unexpected state of run directory", d.testName)
+			if d.expectKept {
+				_, err = os.Stat(filepath.Join(run.Dir(), keepMarker))
+				assert.NoError(t, err, "Test %s failed, kept run directory was not marked", d.testName)
+			}
+		})
+	}
+}
+
+func Test_Workspace_CheckWritable(t *testing.T) {
+	root, err := ioutil.TempDir("", "factset-workspace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	w, err := OpenWorkspace(root, false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.CheckWritable())
+	names, err := readDirNames(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{workspaceMarker}, names, "the check should leave nothing behind")
+
+	assert.NoError(t, os.RemoveAll(root))
+	assert.Error(t, w.CheckWritable(), "a workspace that has gone should not be writable")
+}
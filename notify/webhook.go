@@ -0,0 +1,79 @@
+// Package notify posts the notifications of load runs to webhooks.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/loader"
+)
+
+// The formats a webhook can post notifications in
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Webhook - posts notifications to a URL, either as the loader.Notification in JSON or as a Slack message
+type Webhook struct {
+	url    string
+	format string
+	name   string
+	client *http.Client
+}
+
+// NewWebhook - creates a webhook posting to the url in the format. Slack messages are headed with the name of the
+// application.
+func NewWebhook(url, format, name string) (*Webhook, error) {
+	if format != FormatJSON && format != FormatSlack {
+		return nil, fmt.Errorf("notification format %q is not one of %s or %s", format, FormatJSON, FormatSlack)
+	}
+	return &Webhook{
+		url:    url,
+		format: format,
+		name:   name,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Notify - posts the notification, failing if the webhook does not respond with a 2xx status
+func (w *Webhook) Notify(n loader.Notification) error {
+	var body interface{} = n
+	if w.format == FormatSlack {
+		body = slackMessage{Text: w.slackText(n)}
+	}
+	contents, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(contents))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		response, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded %s: %s", resp.Status, strings.TrimSpace(string(response)))
+	}
+	return nil
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// slackText lists the events under a line giving the outcome of the run
+func (w *Webhook) slackText(n loader.Notification) string {
+	lines := []string{fmt.Sprintf("*%s* run %s %s", w.name, n.Summary.RunID, n.Summary.Outcome)}
+	for _, e := range n.Events {
+		lines = append(lines, fmt.Sprintf("• %s: %s", e.Kind, e.Message))
+	}
+	return strings.Join(lines, "\n")
+}
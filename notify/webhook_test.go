@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/Financial-Times/factset-uploader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPkg = factset.Package{Dataset: "ppl", FSPackage: "people", Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 1}
+
+var testNotification = loader.Notification{
+	Events: []loader.Event{
+		{Kind: loader.EventFailure, Package: testPkg, Message: "ppl_premium failed to load at the load stage: could not load table"},
+		{Kind: loader.EventStale, Package: testPkg, Message: "ppl_premium was last loaded 50h0m0s ago, at v1_12"},
+	},
+	Summary: loader.RunSummary{
+		RunID:   "run-20180110T120000-1",
+		Outcome: loader.RunFailed,
+		Packages: []loader.PackageResult{
+			{Package: testPkg, Status: loader.PackageFailed, ErrorStage: "load", Error: "could not load table"},
+		},
+	},
+}
+
+// webhookStandIn records the requests it is sent and responds with status
+type webhookStandIn struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+func (s *webhookStandIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.contentType = req.Header.Get("Content-Type")
+	s.body, _ = ioutil.ReadAll(req.Body)
+	w.WriteHeader(s.status)
+	if s.status >= 400 {
+		w.Write([]byte("no_text\n"))
+	}
+}
+
+func TestWebhookJSON(t *testing.T) {
+	standIn := &webhookStandIn{status: http.StatusOK}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL, FormatJSON, "factset-uploader")
+	assert.NoError(t, err)
+	assert.NoError(t, webhook.Notify(testNotification))
+	assert.Equal(t, "application/json", standIn.contentType)
+
+	var received loader.Notification
+	assert.NoError(t, json.Unmarshal(standIn.body, &received))
+	assert.Equal(t, testNotification.Events, received.Events)
+	assert.Equal(t, testNotification.Summary.RunID, received.Summary.RunID)
+	assert.Equal(t, testNotification.Summary.Packages[0].ErrorStage, received.Summary.Packages[0].ErrorStage)
+}
+
+func TestWebhookSlack(t *testing.T) {
+	standIn := &webhookStandIn{status: http.StatusOK}
+	server := httptest.NewServer(standIn)
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL, FormatSlack, "factset-uploader")
+	assert.NoError(t, err)
+	assert.NoError(t, webhook.Notify(testNotification))
+
+	var received map[string]string
+	assert.NoError(t, json.Unmarshal(standIn.body, &received))
+	assert.Equal(t, map[string]string{"text": "*factset-uploader* run run-20180110T120000-1 failed\n" +
+		"• failure: ppl_premium failed to load at the load stage: could not load table\n" +
+		"• stale: ppl_premium was last loaded 50h0m0s ago, at v1_12"}, received)
+}
+
+func TestWebhookErrors(t *testing.T) {
+	standIn := &webhookStandIn{status: http.StatusBadRequest}
+	server := httptest.NewServer(standIn)
+
+	webhook, err := NewWebhook(server.URL, FormatSlack, "factset-uploader")
+	assert.NoError(t, err)
+	err = webhook.Notify(testNotification)
+	if assert.Error(t, err) {
+		assert.Equal(t, "webhook responded 400 Bad Request: no_text", err.Error())
+	}
+
+	server.Close()
+	assert.Error(t, webhook.Notify(testNotification), "unreachable webhook should fail")
+
+	_, err = NewWebhook(server.URL, "xml", "factset-uploader")
+	if assert.Error(t, err) {
+		assert.Equal(t, `notification format "xml" is not one of json or slack`, err.Error())
+	}
+}
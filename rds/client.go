@@ -1,6 +1,7 @@
 package rds
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -8,8 +9,8 @@ import (
 
 	"strings"
 
+	"github.com/Financial-Times/factset-uploader/ddl"
 	"github.com/Financial-Times/factset-uploader/factset"
-	_ "github.com/go-sql-driver/mysql"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -17,16 +18,20 @@ const (
 	MetadataTableCount = 2
 )
 
+// Client - Storer backed by a MySQL, PostgreSQL or SQLite database
 type Client struct {
-	DB     *sql.DB
-	schema string
+	DB      *sql.DB
+	schema  string
+	dialect Dialect
 }
 
-//
+// NewClient - connects to the database described by the DSN. A postgres:// URL connects to PostgreSQL,
+// anything else is treated as a MySQL DSN of the form user:pass@host/schema.
 func NewClient(dsn string) (*Client, error) {
-	schema := dsn[strings.LastIndex(dsn, "/")+1:]
-	connString := fmt.Sprintf("%s?interpolateParams=true&parseTime=true&allowAllFiles=true", dsn)
-	db, err := sql.Open("mysql", connString)
+	dialect := dialectForDSN(dsn)
+	schema := dialect.Schema(dsn)
+	connString := dialect.ConnString(dsn)
+	db, err := sql.Open(dialect.DriverName(), connString)
 	if err != nil {
 		log.WithError(err).Errorf("Error connecting to db: %s", connString)
 		return nil, err
@@ -37,16 +42,29 @@ func NewClient(dsn string) (*Client, error) {
 		return nil, err
 	}
 
+	log.WithFields(log.Fields{"dialect": dialect.Name(), "schema": schema}).Info("Connected to db")
 	return &Client{
-		DB:     db,
-		schema: schema,
+		DB:      db,
+		schema:  schema,
+		dialect: dialect,
 	}, nil
 }
 
+// CheckConnectivity - checks that the database can be queried
+func (c *Client) CheckConnectivity(ctx context.Context) error {
+	var one int
+	return c.DB.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+}
+
+// Dialect - the dialect of the database the client is connected to
+func (c *Client) Dialect() Dialect {
+	return c.dialect
+}
+
 //TODO in future we should have versioning/namespacing for our schema tables so that they are only dropped after a successful reload
-func (c *Client) DropTablesWithProductAndBundle(product string, bundle string) error {
-	getTableQuery := fmt.Sprintf(`SELECT tablename FROM metadata_table_version WHERE product = '%s' AND bundle = '%s'`, product, bundle)
-	rows, err := c.DB.Query(getTableQuery)
+func (c *Client) DropTablesWithProductAndBundle(ctx context.Context, product string, bundle string) error {
+	getTableQuery := `SELECT tablename FROM metadata_table_version WHERE product = ? AND bundle = ?`
+	rows, err := c.DB.QueryContext(ctx, c.dialect.Rebind(getTableQuery), product, bundle)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error running query to return tables matching: product = %s & bundle = %s", product, bundle)
 		return err
@@ -69,18 +87,33 @@ func (c *Client) DropTablesWithProductAndBundle(product string, bundle string) e
 		log.WithFields(log.Fields{"fs_product": product}).Infof("Db has no tables matching: product = %s and bundle = %s", product, bundle)
 		return nil
 	}
-	dropTableQuery := fmt.Sprintf(`DROP TABLES IF EXISTS %s`, strings.Join(tableNames, ", "))
-	_, err = c.DB.Exec(dropTableQuery)
+	tables, err := newIdentifiers(tableNames)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Error("Refusing to drop tables as metadata_table_version holds an invalid table name")
+		return err
+	}
+	dropTableQuery := c.dialect.DropTablesQuery(tables)
+	_, err = c.DB.ExecContext(ctx, dropTableQuery)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error executing query to drop tables matching: %s", strings.Join(tableNames, ", "))
 		return err
 	}
+	_, err = c.DB.ExecContext(ctx, c.dialect.Rebind(`DELETE FROM metadata_table_schema WHERE product = ? AND bundle = ?`), product, bundle)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error removing schemas of dropped tables matching: product = %s and bundle = %s", product, bundle)
+		return err
+	}
 	return nil
 }
 
-func (c *Client) DropDataFromTable(tableName string, product string) error {
-	deleteRowsQuery := fmt.Sprintf(`DELETE FROM %s`, tableName)
-	_, err := c.DB.Exec(deleteRowsQuery)
+func (c *Client) DropDataFromTable(ctx context.Context, tableName string, product string) error {
+	table, err := NewIdentifier(tableName)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Error("Refusing to clear data from table")
+		return err
+	}
+	deleteRowsQuery := fmt.Sprintf(`DELETE FROM %s`, c.dialect.QuoteIdentifier(table))
+	_, err = c.DB.ExecContext(ctx, deleteRowsQuery)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error executing query to clear data from table: %s", tableName)
 		return err
@@ -88,18 +121,19 @@ func (c *Client) DropDataFromTable(tableName string, product string) error {
 	return nil
 }
 
-func (c *Client) UpdateLoadedTableVersion(tableName string, version factset.PackageVersion, pkg factset.Package) error {
-	updateTableMetadataQueryTemplate := `REPLACE INTO metadata_table_version
-						(tablename, feed_version, sequence, date_loaded, product, bundle)
-						VALUES (?, ?, ?, NOW(), ?, ?)`
-	stmt, err := c.DB.Prepare(updateTableMetadataQueryTemplate)
-	defer stmt.Close()
+func (c *Client) UpdateLoadedTableVersion(ctx context.Context, tableName string, version factset.PackageVersion, pkg factset.Package) error {
+	if _, err := NewIdentifier(tableName); err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Error("Refusing to record metadata for table")
+		return err
+	}
+	stmt, err := c.DB.PrepareContext(ctx, c.dialect.UpsertTableMetadataQuery())
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("error preparing query to update table metadata for table: %s", tableName)
 		return err
 	}
+	defer stmt.Close()
 
-	res, err := stmt.Exec(tableName, version.FeedVersion, version.Sequence, pkg.Product, pkg.Bundle)
+	res, err := stmt.ExecContext(ctx, tableName, version.FeedVersion, version.Sequence, pkg.Product, pkg.Bundle)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("error running query to update table metadata for table: %s", tableName)
 		return err
@@ -114,20 +148,17 @@ func (c *Client) UpdateLoadedTableVersion(tableName string, version factset.Pack
 	return nil
 }
 
-func (c *Client) UpdateLoadedPackageVersion(packageMetadata *factset.PackageMetadata) error {
+func (c *Client) UpdateLoadedPackageVersion(ctx context.Context, packageMetadata *factset.PackageMetadata) error {
 	var product = packageMetadata.Package.Product
 	var bundle = packageMetadata.Package.Bundle
-	updatePackageMetadataQueryTemplate := `REPLACE INTO metadata_package_version
-						(product, bundle, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
-						VALUES (?, ?, ?, ?, ?, ?, ?, NOW())`
-	stmt, err := c.DB.Prepare(updatePackageMetadataQueryTemplate)
-	defer stmt.Close()
+	stmt, err := c.DB.PrepareContext(ctx, c.dialect.UpsertPackageMetadataQuery())
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error preparing query to update package metadata for product: %s, bundle: %s", product, bundle)
 		return err
 	}
+	defer stmt.Close()
 
-	res, err := stmt.Exec(product, bundle, packageMetadata.SchemaVersion.FeedVersion, packageMetadata.SchemaVersion.Sequence, packageMetadata.SchemaLoadedDate, packageMetadata.PackageVersion.FeedVersion, packageMetadata.PackageVersion.Sequence)
+	res, err := stmt.ExecContext(ctx, product, bundle, packageMetadata.Package.FeedVersion, packageMetadata.SchemaVersion.FeedVersion, packageMetadata.SchemaVersion.Sequence, packageMetadata.SchemaLoadedDate, packageMetadata.PackageVersion.FeedVersion, packageMetadata.PackageVersion.Sequence)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error executing query to update package metadata for product: %s, bundle: %s", product, bundle)
 		return err
@@ -140,33 +171,43 @@ func (c *Client) UpdateLoadedPackageVersion(packageMetadata *factset.PackageMeta
 	return nil
 }
 
-func (c *Client) LoadTable(filename, table string) error {
-	queryTemplate := `LOAD DATA LOCAL INFILE '%s' REPLACE INTO TABLE %s FIELDS TERMINATED BY '|'
-	OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '\r\n' IGNORE 1 LINES;`
-
-	_, err := c.DB.Exec(fmt.Sprintf(queryTemplate, filename, table))
-	return err
+// LoadTable - loads the data file into the table, returning the rows loaded and any warnings raised. A load stopped
+// because the context is done is rolled back and returns the context's error.
+func (c *Client) LoadTable(ctx context.Context, filename, table string, format FileFormat) (LoadResult, error) {
+	identifier, err := NewIdentifier(table)
+	if err != nil {
+		log.WithError(err).Errorf("Refusing to load file %s", filename)
+		return LoadResult{}, err
+	}
+	if err := format.Validate(); err != nil {
+		log.WithError(err).Errorf("Refusing to load file %s", filename)
+		return LoadResult{}, err
+	}
+	result, err := c.dialect.LoadTable(ctx, c.DB, filename, format, identifier)
+	if err != nil && ctx.Err() != nil {
+		return LoadResult{}, ctx.Err()
+	}
+	return result, err
 }
 
-func (c *Client) GetPackageMetadata(pkg factset.Package) (factset.PackageMetadata, error) {
+func (c *Client) GetPackageMetadata(ctx context.Context, pkg factset.Package) (factset.PackageMetadata, error) {
 	var pkgMetadata = factset.PackageMetadata{}
 	queryTemplate := `SELECT product, bundle, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded
 						FROM metadata_package_version
-						WHERE product = ? AND bundle = ?`
-	stmt, err := c.DB.Prepare(queryTemplate)
-	defer stmt.Close()
+						WHERE product = ? AND bundle = ? AND feed_version = ?`
+	stmt, err := c.DB.PrepareContext(ctx, c.dialect.Rebind(queryTemplate))
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error preparing query to return package metadata for product: %s", pkg.Product)
 		return pkgMetadata, err
 	}
+	defer stmt.Close()
 
-	stmt.Exec()
 	var product string
 	var bundle string
 	var schemaFeedVersion, schemaSequence, packageFeedVersion, packageSequence int
 	var schemaDateLoaded, packageDateLoaded time.Time
 
-	err = stmt.QueryRow(pkg.Product, pkg.Bundle).Scan(
+	err = stmt.QueryRowContext(ctx, pkg.Product, pkg.Bundle, pkg.FeedVersion).Scan(
 		&product, &bundle, &schemaFeedVersion, &schemaSequence, &schemaDateLoaded,
 		&packageFeedVersion, &packageSequence, &packageDateLoaded)
 
@@ -189,68 +230,59 @@ func (c *Client) GetPackageMetadata(pkg factset.Package) (factset.PackageMetadat
 	}, nil
 }
 
-//
-func (c *Client) LoadMetadataTables() error {
-	query := `CREATE TABLE IF NOT EXISTS metadata_package_version (
-			product varchar(255) NOT NULL,
-			bundle varchar(255) NOT NULL,
-			schema_feed_version INT,
-			schema_sequence INT,
-			schema_date_loaded DATETIME,
-			package_feed_version INT,
-			package_sequence INT,
-			package_date_loaded DATETIME,
-			PRIMARY KEY (product)
-		);`
-	if _, err := c.DB.Exec(query); err != nil {
-		log.WithError(err).Error("Error running query to create metadata_package_version table")
-		return err
-	}
-
-	query2 := `
-		CREATE TABLE IF NOT EXISTS metadata_table_version (
-			tablename varchar(255) NOT NULL,
-			feed_version INT,
-			sequence INT,
-			date_loaded DATETIME,
-			product varchar(255) NOT NULL,
-			bundle  varchar(255) NOT NULL,
-			PRIMARY KEY (tablename)
-		);`
-
-	if _, err := c.DB.Exec(query2); err != nil {
-		log.WithError(err).Error("Error running query to create metadata_table_version table")
+// CreateTablesFromSchema
+// Parses the create table file and runs its statements, recording the tables created and their definitions.
+func (c *Client) CreateTablesFromSchema(ctx context.Context, contents []byte, pkg factset.Package) error {
+	schema, err := ddl.Parse(string(contents))
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error parsing schema for %s", pkg.Product)
 		return err
 	}
-	return nil
+	return c.createTables(ctx, schema, pkg)
 }
 
-// CreateTablesFromSchema
-// Takes the semicolon delimited contents of the create table file and creates the tables.
-func (c *Client) CreateTablesFromSchema(contents []byte, pkg factset.Package) error {
-	statements := strings.Split(string(contents), ";")
+// createTables runs the statements of the schema, skipping tables another package has already created
+func (c *Client) createTables(ctx context.Context, schema ddl.Schema, pkg factset.Package) error {
+	for _, statement := range schema.Statements {
+		if statement.Kind != ddl.Other {
+			if _, err := NewIdentifier(statement.Table); err != nil {
+				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Refusing to run schema statement for %s", pkg.Product)
+				return err
+			}
+		}
+	}
 
-	for _, statement := range statements {
-		statement = strings.TrimSpace(statement)
-		if statement != "" && len(statement) > 10 {
-			statementSplits := strings.Split(statement, " ")
-			_, err := c.DB.Exec(statement)
-			if err != nil {
-				if !(strings.Contains(err.Error(), fmt.Sprintf("Error 1050: Table '%s' already exists", statementSplits[2]))) {
-					log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error running query to create schema for %s", pkg.Product)
-					return err
-				} else {
-					log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Table %s has already been created by a different package", statementSplits[2])
-					continue
-				}
+	var created []ddl.Table
+	existing := make(map[string]bool)
+	for _, statement := range schema.Statements {
+		// indexes of a table another package created are left to that package
+		if statement.Kind != ddl.CreateTable && existing[strings.ToLower(statement.Table)] {
+			continue
+		}
+		_, err := c.DB.ExecContext(ctx, c.dialect.TranslateDDL(statement.Text))
+		if err != nil {
+			if !c.dialect.IsTableExistsError(err) {
+				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error running query to create schema for %s", pkg.Product)
+				return err
 			}
-			// update metadata table on creation of each schema table
-			// if load is unsuccessful schema tables are cleaned up by subsequent loads
-			if statementSplits[0] == "CREATE" && statementSplits[1] == "TABLE" {
-				if err = c.UpdateLoadedTableVersion(statementSplits[2], factset.PackageVersion{0, 0}, pkg); err != nil {
-					return err
-				}
+			log.WithFields(log.Fields{"fs_product": pkg.Product}).Debugf("Table has already been created by a different package: %s", statement.Table)
+			existing[strings.ToLower(statement.Table)] = true
+			continue
+		}
+		// update metadata table on creation of each schema table
+		// if load is unsuccessful schema tables are cleaned up by subsequent loads
+		if statement.Kind == ddl.CreateTable {
+			if err = c.UpdateLoadedTableVersion(ctx, statement.Table, factset.PackageVersion{FeedVersion: 0, Sequence: 0}, pkg); err != nil {
+				return err
 			}
+			table, _ := schema.Table(statement.Table)
+			created = append(created, table)
+		}
+	}
+	// recorded once all statements have run, so the definitions include indexes added after the tables were created
+	for _, table := range created {
+		if err := c.recordTableSchema(ctx, schema, table, pkg); err != nil {
+			return err
 		}
 	}
 	return nil
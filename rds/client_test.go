@@ -1,7 +1,10 @@
 package rds
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"testing"
 	"time"
@@ -19,7 +22,8 @@ func init() {
 	if os.Getenv("RDS_DSN") != "" {
 		dsn = os.Getenv("RDS_DSN")
 	} else {
-		dsn = "root:@/test2"
+		dir, _ := ioutil.TempDir("", "factset-rds")
+		dsn = "sqlite://" + filepath.Join(dir, "test.db")
 	}
 
 	dbClient, _ = NewClient(dsn)
@@ -41,15 +45,15 @@ func TestClientGetLoadedVersionNoTable(t *testing.T) {
 func TestClientUpdateAndGetLoadedVersion(t *testing.T) {
 	defer dropTestTables()
 	defer removeMetadataTables()
-	err := dbClient.LoadMetadataTables()
+	err := dbClient.Migrate()
 	assert.NoError(t, err)
 
-	err = dbClient.UpdateLoadedTableVersion("testTable", factset.PackageVersion{1, 10}, factset.Package{Product: "test", Bundle: "test"})
+	err = dbClient.UpdateLoadedTableVersion(context.Background(), "testTable", factset.PackageVersion{FeedVersion: 1, Sequence: 10}, factset.Package{Product: "test", Bundle: "test"})
 	assert.NoError(t, err)
 
 	version, err := getLoadedVersion("testTable")
 	assert.NoError(t, err)
-	assert.EqualValues(t, factset.PackageVersion{1, 10}, version)
+	assert.EqualValues(t, factset.PackageVersion{FeedVersion: 1, Sequence: 10}, version)
 }
 
 //func TestClientDropTablesWithPrefix(t *testing.T) {
@@ -68,13 +72,13 @@ func TestClientUpdateAndGetLoadedVersion(t *testing.T) {
 //}
 
 func TestClientGetPackageMetadata(t *testing.T) {
-	dbClient.LoadMetadataTables()
+	dbClient.Migrate()
 	defer removeMetadataTables()
 	_, err := dbClient.DB.Exec(`INSERT INTO metadata_package_version (product, bundle, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
 										VALUES ('foo_fooey_advanced', 'foo_fooey_advanced', 2, 1234, '2017-01-02 03:04:05', 2, 5678, '2017-06-07 08:09:10')`)
 	assert.NoError(t, err)
 
-	pkgMetadata, err := dbClient.GetPackageMetadata(factset.Package{
+	pkgMetadata, err := dbClient.GetPackageMetadata(context.Background(), factset.Package{
 		Dataset:   "foo",
 		FSPackage: "fooey",
 		Product:   "foo_fooey_advanced",
@@ -101,21 +105,174 @@ func TestClientGetPackageMetadata(t *testing.T) {
 	}, pkgMetadata)
 }
 
-func verifyMetadata() (bool, error) {
-	queryTemplate := `SELECT count(*)
-						FROM information_schema.TABLES
-						WHERE TABLE_SCHEMA = ?
-						AND TABLE_NAME LIKE "metadata%"`
+func TestClientRejectsInvalidTableNames(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+	createTestTables()
 
-	stmt, err := dbClient.DB.Prepare(queryTemplate)
-	if err != nil {
-		return false, err
+	_, err = dbClient.LoadTable(context.Background(), "ppl_names.txt", "foo_test1; DROP TABLE foo_test2", DefaultFileFormat)
+	assert.Error(t, err)
+	err = dbClient.DropDataFromTable(context.Background(), "foo_test1; DROP TABLE foo_test2", "foo")
+	assert.Error(t, err)
+	err = dbClient.UpdateLoadedTableVersion(context.Background(), "foo_test1`", factset.PackageVersion{FeedVersion: 1, Sequence: 10}, factset.Package{Product: "foo", Bundle: "foo"})
+	assert.Error(t, err)
+	err = dbClient.CreateTablesFromSchema(context.Background(), []byte("CREATE TABLE `foo_test4; DROP TABLE foo_test2` (ID VARCHAR(10) NOT NULL);"), factset.Package{Product: "foo", Bundle: "foo"})
+	assert.Error(t, err)
+
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test2`)
+	assert.NoError(t, err, "foo_test2 should not have been dropped")
+}
+
+func TestClientLoadTableStopsWhenCancelled(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+	createTestTables()
+
+	dir, err := ioutil.TempDir("", "factset-rds")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "foo_test1.txt")
+	err = ioutil.WriteFile(filename, []byte("ID\r\n0001\r\n0002\r\n0003\r\n"), 0644)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = dbClient.LoadTable(ctx, filename, "foo_test1", DefaultFileFormat)
+	assert.Equal(t, context.Canceled, err)
+
+	var rows int
+	assert.NoError(t, dbClient.DB.QueryRow(`SELECT COUNT(*) FROM foo_test1`).Scan(&rows))
+	assert.Equal(t, 0, rows, "No rows should be loaded once cancelled")
+}
+
+func TestClientDropTablesWithProductAndBundleIsParameterised(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+	createTestTables()
+	foo := factset.Package{Product: "foo", Bundle: "foo"}
+	assert.NoError(t, dbClient.UpdateLoadedTableVersion(context.Background(), "foo_test1", factset.PackageVersion{FeedVersion: 1, Sequence: 10}, foo))
+	assert.NoError(t, dbClient.UpdateLoadedTableVersion(context.Background(), "bob_test1", factset.PackageVersion{FeedVersion: 1, Sequence: 10}, factset.Package{Product: "bob", Bundle: "bob"}))
+
+	err = dbClient.DropTablesWithProductAndBundle(context.Background(), "' OR '1'='1", "' OR '1'='1")
+	assert.NoError(t, err)
+	_, err = dbClient.DB.Exec(`SELECT * FROM bob_test1`)
+	assert.NoError(t, err, "bob_test1 should not have been dropped")
+
+	err = dbClient.DropTablesWithProductAndBundle(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test1`)
+	assert.Error(t, err, "foo_test1 should have been dropped")
+}
+
+func TestClientPackageMetadataPerBundleAndFeedVersion(t *testing.T) {
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	packages := []factset.Package{
+		{Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 1},
+		{Product: "ppl_premium", Bundle: "ppl_hub", FeedVersion: 1},
+		{Product: "ppl_premium", Bundle: "ppl_premium", FeedVersion: 2},
 	}
-	var metadataTableCount int
-	err = stmt.QueryRow(dbClient.schema).Scan(&metadataTableCount)
-	defer stmt.Close()
-	if err != nil {
-		return false, err
+	for i, pkg := range packages {
+		err = dbClient.UpdateLoadedPackageVersion(context.Background(), &factset.PackageMetadata{
+			Package:        pkg,
+			SchemaVersion:  factset.PackageVersion{FeedVersion: pkg.FeedVersion, Sequence: 10},
+			PackageVersion: factset.PackageVersion{FeedVersion: pkg.FeedVersion, Sequence: 100 + i},
+		})
+		assert.NoError(t, err)
+	}
+	for i, pkg := range packages {
+		pm, err := dbClient.GetPackageMetadata(context.Background(), pkg)
+		assert.NoError(t, err)
+		assert.Equal(t, 100+i, pm.PackageVersion.Sequence, "Version of %s/%s v%d was overwritten", pkg.Product, pkg.Bundle, pkg.FeedVersion)
+	}
+}
+
+func TestClientMigratesExistingPackageMetadata(t *testing.T) {
+	defer removeMetadataTables()
+	err := dbClient.migrateTo(1)
+	assert.NoError(t, err)
+	_, err = dbClient.DB.Exec(`INSERT INTO metadata_package_version (product, bundle, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
+										VALUES ('foo_fooey_advanced', 'foo_fooey_advanced', 2, 1234, '2017-01-02 03:04:05', 2, 5678, '2017-06-07 08:09:10')`)
+	assert.NoError(t, err)
+
+	err = dbClient.Migrate()
+	assert.NoError(t, err)
+	version, err := dbClient.schemaVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+
+	pkg := factset.Package{Product: "foo_fooey_advanced", Bundle: "foo_fooey_advanced", FeedVersion: 2}
+	pm, err := dbClient.GetPackageMetadata(context.Background(), pkg)
+	assert.NoError(t, err, "Existing package metadata should have been kept")
+	assert.Equal(t, 5678, pm.PackageVersion.Sequence)
+	assert.Equal(t, 1234, pm.SchemaVersion.Sequence)
+
+	err = dbClient.Migrate()
+	assert.NoError(t, err, "Migrations should not be run twice")
+}
+
+func TestClientConcurrentMigrationsDoNotRace(t *testing.T) {
+	defer removeMetadataTables()
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- dbClient.Migrate()
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	var applied int
+	err := dbClient.DB.QueryRow(`SELECT COUNT(*) FROM metadata_schema_version`).Scan(&applied)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), applied, "Each migration should have been applied once")
+}
+
+func TestValidateMigrations(t *testing.T) {
+	statements := map[string][]string{"sqlite": {"SELECT 1"}}
+	testCases := []struct {
+		testName      string
+		migrations    []migration
+		dialects      []string
+		expectedError string
+	}{
+		{"Released migrations are valid", migrations, []string{"mysql", "postgres", "sqlite"}, ""},
+		{"Versions must start at 1", []migration{{2, "second", statements}}, []string{"sqlite"}, "expected 1"},
+		{"Versions must be in order", []migration{{1, "first", statements}, {3, "third", statements}, {2, "second", statements}}, []string{"sqlite"}, "expected 2"},
+		{"Versions must not repeat", []migration{{1, "first", statements}, {1, "again", statements}}, []string{"sqlite"}, "expected 2"},
+		{"Every dialect needs statements", []migration{{1, "first", statements}}, []string{"mysql", "sqlite"}, "no statements for dialect mysql"},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			err := validateMigrations(d.migrations, d.dialects)
+			if d.expectedError == "" {
+				assert.NoError(t, err, "Test %s failed", d.testName)
+				return
+			}
+			assert.Error(t, err, "Test %s failed, should have resulted in an error", d.testName)
+			assert.Contains(t, err.Error(), d.expectedError, "Test %s failed, returned unexpected error", d.testName)
+		})
+	}
+}
+
+func verifyMetadata() (bool, error) {
+	metadataTableCount := 0
+	for _, table := range []string{"metadata_package_version", "metadata_table_version"} {
+		rows, err := dbClient.DB.Query(`SELECT * FROM ` + table)
+		if err != nil {
+			continue
+		}
+		rows.Close()
+		metadataTableCount++
 	}
 	return metadataTableCount == MetadataTableCount, nil
 }
@@ -125,7 +282,7 @@ func getLoadedVersion(tableName string) (factset.PackageVersion, error) {
 						FROM metadata_table_version
 						WHERE tablename = ?
 						`
-	stmt, err := dbClient.DB.Prepare(queryTemplate)
+	stmt, err := dbClient.DB.Prepare(dbClient.dialect.Rebind(queryTemplate))
 	if err != nil {
 		return factset.PackageVersion{}, err
 	}
@@ -140,7 +297,8 @@ func getLoadedVersion(tableName string) (factset.PackageVersion, error) {
 }
 
 func removeMetadataTables() {
-	dbClient.DB.Exec(`DROP TABLE IF EXISTS metadata_package_version, metadata_table_version`)
+	tables, _ := newIdentifiers([]string{"metadata_package_version", "metadata_table_version", "metadata_schema_version", "metadata_lock", "metadata_load_history", "metadata_table_schema"})
+	dbClient.DB.Exec(dbClient.dialect.DropTablesQuery(tables))
 }
 
 func createTestTables() {
@@ -152,7 +310,8 @@ func createTestTables() {
 }
 
 func dropTestTables() {
-	dbClient.DB.Exec(`DROP TABLE IF EXISTS foo_test1, foo_test2, foo_test3, bob_test1, bob_test2`)
+	tables, _ := newIdentifiers([]string{"foo_test1", "foo_test2", "foo_test3", "bob_test1", "bob_test2"})
+	dbClient.DB.Exec(dbClient.dialect.DropTablesQuery(tables))
 
 }
 
@@ -162,3 +321,21 @@ func countTestTables() int {
 
 	return i
 }
+
+func TestClientCheckSchema(t *testing.T) {
+	defer removeMetadataTables()
+	err := dbClient.CheckSchema()
+	assert.Error(t, err, "Schema should not be up to date before the metadata tables are created")
+
+	err = dbClient.migrateTo(1)
+	assert.NoError(t, err)
+	err = dbClient.CheckSchema()
+	assert.IsType(t, &SchemaBehindError{}, err, "Schema should be behind after the first migration")
+	version, verr := dbClient.schemaVersion()
+	assert.NoError(t, verr)
+	assert.Equal(t, 1, version, "Checking the schema should not have migrated it")
+
+	err = dbClient.Migrate()
+	assert.NoError(t, err)
+	assert.NoError(t, dbClient.CheckSchema())
+}
@@ -0,0 +1,95 @@
+package rds
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+)
+
+// nullField - reports whether a field of a data file is to be loaded as NULL
+type nullField func(field string) bool
+
+// emptyIsNull - loads empty fields as NULL, as a Postgres COPY of a CSV file does
+func emptyIsNull(field string) bool {
+	return field == ""
+}
+
+// escapedNull - loads only \N as NULL, as LOAD DATA does, so empty fields are loaded as empty strings
+func escapedNull(field string) bool {
+	return field == `\N`
+}
+
+// readDataFile parses a data file, delimited with optionally quoted fields, calling fn with each data row
+// and skipping the header line if the format has one. Fields that isNull reports are returned as nil so
+// they can be loaded as NULL.
+func readDataFile(filename string, format FileFormat, isNull nullField, fn func(row []interface{}) error) (int, error) {
+	f, err := OpenDataFile(filename, format)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if format.LineEnding == "\r" {
+		r = &crReader{f}
+	}
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comma = format.Delimiter
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	if format.Header {
+		if _, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		row := make([]interface{}, len(record))
+		for i, field := range record {
+			if !isNull(field) {
+				row[i] = field
+			}
+		}
+		if err = fn(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+}
+
+// CountDataRows - counts the data rows in a data file, reading it as it is read for loading so that a quoted field
+// with an embedded line break is part of one row and blank lines are not counted
+func CountDataRows(filename string, format FileFormat) (int64, error) {
+	rows, err := readDataFile(filename, format, emptyIsNull, func(row []interface{}) error {
+		return nil
+	})
+	return int64(rows), err
+}
+
+// crReader - turns the bare carriage return line endings of old Mac files into new lines, which is all
+// encoding/csv understands
+type crReader struct {
+	r io.Reader
+}
+
+func (c *crReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] == '\r' {
+			p[i] = '\n'
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,77 @@
+package rds
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// quoteIdentifiers quotes each identifier and joins them into a comma separated list
+func quoteIdentifiers(d Dialect, identifiers []Identifier) string {
+	quoted := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		quoted[i] = d.QuoteIdentifier(identifier)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Dialect - the database specific parts of the rds client, so the loader can target more than one database
+type Dialect interface {
+	// Name - name of the dialect for logging
+	Name() string
+	// DriverName - name of the database/sql driver to open connections with
+	DriverName() string
+	// ConnString - turns the configured DSN into the connection string handed to the driver
+	ConnString(dsn string) string
+	// Schema - the database or schema name from the DSN
+	Schema(dsn string) string
+	// Rebind - rewrites a query written with ? placeholders into the dialect's placeholder syntax
+	Rebind(query string) string
+	// MetadataTableStatements - statements creating the first version of the uploader's metadata tables
+	MetadataTableStatements() []string
+	// UpsertTableMetadataQuery - query inserting or replacing a row of metadata_table_version
+	UpsertTableMetadataQuery() string
+	// UpsertPackageMetadataQuery - query inserting or replacing a row of metadata_package_version
+	UpsertPackageMetadataQuery() string
+	// QuoteIdentifier - quotes a table or column name for use in a query
+	QuoteIdentifier(identifier Identifier) string
+	// DropTablesQuery - query dropping all of the given tables
+	DropTablesQuery(tables []Identifier) string
+	// TranslateDDL - rewrites a FactSet table creation statement for the dialect
+	TranslateDDL(statement string) string
+	// IsTableExistsError - whether the error was caused by creating a table that already exists
+	IsTableExistsError(err error) bool
+	// LoadTable - bulk loads the data file, which has been validated to be in a supported format, into the table,
+	// rolling the load back if the context is done before it has finished
+	LoadTable(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error)
+}
+
+// dialectForDSN picks the dialect from the form of the DSN; URLs with a postgres scheme select
+// PostgreSQL, sqlite:// selects an SQLite file and anything else is treated as a MySQL DSN.
+func dialectForDSN(dsn string) Dialect {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		return &postgresDialect{}
+	case strings.HasPrefix(dsn, sqlitePrefix):
+		return &sqliteDialect{}
+	}
+	return &mysqlDialect{}
+}
+
+// rebindNumbered replaces each ? placeholder with a numbered one using the given prefix, e.g. $1, $2
+func rebindNumbered(query string, prefix string) string {
+	var b bytes.Buffer
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(prefix)
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
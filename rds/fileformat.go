@@ -0,0 +1,301 @@
+package rds
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings of data files
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1"
+)
+
+// FileFormat - how the rows of a data file are laid out. Fields may always be enclosed in double quotes.
+type FileFormat struct {
+	Delimiter  rune
+	LineEnding string
+	Encoding   string
+	// Header is set when the first line holds the column names rather than data
+	Header bool
+}
+
+// DefaultFileFormat - the format of the FactSet data feeds
+var DefaultFileFormat = FileFormat{Delimiter: '|', LineEnding: "\r\n", Encoding: EncodingUTF8, Header: true}
+
+// Supported delimiters and line endings, with how they are written in a MySQL string literal
+var (
+	fileDelimiters  = map[rune]string{'|': `|`, '\t': `\t`, ',': `,`}
+	fileLineEndings = map[string]string{"\r\n": `\r\n`, "\n": `\n`, "\r": `\r`}
+	fileEncodings   = map[string]bool{EncodingUTF8: true, EncodingUTF16LE: true, EncodingUTF16BE: true, EncodingLatin1: true}
+)
+
+// Validate - checks the format is one that can be loaded
+func (f FileFormat) Validate() error {
+	if _, ok := fileDelimiters[f.Delimiter]; !ok {
+		return fmt.Errorf("unsupported delimiter %q", f.Delimiter)
+	}
+	if _, ok := fileLineEndings[f.LineEnding]; !ok {
+		return fmt.Errorf("unsupported line ending %q", f.LineEnding)
+	}
+	if !fileEncodings[f.Encoding] {
+		return fmt.Errorf("unsupported encoding %q", f.Encoding)
+	}
+	return nil
+}
+
+func (f FileFormat) String() string {
+	header := "no header"
+	if f.Header {
+		header = "header"
+	}
+	return fmt.Sprintf("delimiter %q, line ending %q, %s, %s", f.Delimiter, f.LineEnding, f.Encoding, header)
+}
+
+// OpenDataFile - opens a data file for reading as UTF-8, dropping any byte order mark
+func OpenDataFile(filename string, format FileFormat) (io.ReadCloser, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{newDecoder(bufio.NewReader(f), format.Encoding), f}, nil
+}
+
+var byteOrderMarks = map[string][]byte{
+	EncodingUTF8:    {0xEF, 0xBB, 0xBF},
+	EncodingUTF16LE: {0xFF, 0xFE},
+	EncodingUTF16BE: {0xFE, 0xFF},
+}
+
+// needsDecoding reports whether a file has to go through OpenDataFile to be read as UTF-8 or Latin-1
+func needsDecoding(filename string, format FileFormat) (bool, error) {
+	if format.Encoding == EncodingUTF16LE || format.Encoding == EncodingUTF16BE {
+		return true, nil
+	}
+	bom, ok := byteOrderMarks[format.Encoding]
+	if !ok {
+		return false, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, len(bom))
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.Equal(head[:n], bom), nil
+}
+
+// newDecoder returns a reader of r as UTF-8
+func newDecoder(r *bufio.Reader, encoding string) io.Reader {
+	if bom, ok := byteOrderMarks[encoding]; ok {
+		if head, err := r.Peek(len(bom)); err == nil && bytes.Equal(head, bom) {
+			r.Discard(len(bom))
+		}
+	}
+	if encoding == EncodingUTF8 {
+		return r
+	}
+	return &decoder{r: r, encoding: encoding}
+}
+
+// decoder - converts Latin-1 or UTF-16 to UTF-8
+type decoder struct {
+	r        *bufio.Reader
+	encoding string
+	pending  []byte
+	err      error
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.pending) < len(p) && d.err == nil {
+		var r rune
+		r, d.err = d.decodeRune()
+		if d.err == nil {
+			var buf [utf8.UTFMax]byte
+			n := utf8.EncodeRune(buf[:], r)
+			d.pending = append(d.pending, buf[:n]...)
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
+
+func (d *decoder) decodeRune() (rune, error) {
+	if d.encoding == EncodingLatin1 {
+		b, err := d.r.ReadByte()
+		return rune(b), err
+	}
+	u, err := d.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(u)) {
+		return rune(u), nil
+	}
+	u2, err := d.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	return utf16.DecodeRune(rune(u), rune(u2)), nil
+}
+
+func (d *decoder) readUnit() (uint16, error) {
+	var b [2]byte
+	n, err := io.ReadFull(d.r, b[:])
+	if n == 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d.encoding == EncodingUTF16BE {
+		return uint16(b[0])<<8 | uint16(b[1]), nil
+	}
+	return uint16(b[1])<<8 | uint16(b[0]), nil
+}
+
+// detectSampleBytes - how much of the head of a file is read to detect its format
+const detectSampleBytes = 64 << 10
+
+var (
+	headerFieldPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ]*$`)
+	columnNamePattern   = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	detectedDelimiters  = []rune{'|', '\t', ','}
+	detectedLineEndings = []string{"\r\n", "\n", "\r"}
+)
+
+// DetectFileFormat - works out the format of a data file from its head. Anything that can not be
+// detected is taken from DefaultFileFormat.
+func DetectFileFormat(filename string) (FileFormat, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return FileFormat{}, err
+	}
+	defer f.Close()
+
+	sample := make([]byte, detectSampleBytes)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return FileFormat{}, err
+	}
+	sample = sample[:n]
+
+	format := DefaultFileFormat
+	format.Encoding = detectEncoding(sample, n == detectSampleBytes)
+	var text bytes.Buffer
+	// The sample may end part way through a character, so a decoding error at the end is expected
+	text.ReadFrom(newDecoder(bufio.NewReader(bytes.NewReader(sample)), format.Encoding))
+
+	lines := strings.SplitN(text.String(), "\n", 3)
+	for _, ending := range detectedLineEndings {
+		if strings.Contains(text.String(), ending) {
+			format.LineEnding = ending
+			if ending == "\r" {
+				lines = strings.SplitN(text.String(), "\r", 3)
+			}
+			break
+		}
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	format.Delimiter = detectDelimiter(lines[0])
+	format.Header = detectHeader(lines, format.Delimiter)
+	return format, nil
+}
+
+func detectEncoding(sample []byte, truncated bool) string {
+	for _, encoding := range []string{EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE} {
+		if bytes.HasPrefix(sample, byteOrderMarks[encoding]) {
+			return encoding
+		}
+	}
+
+	// Mostly ASCII text in UTF-16 has a zero in every other byte
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros > len(sample)/4 && oddZeros > evenZeros:
+		return EncodingUTF16LE
+	case evenZeros > len(sample)/4 && evenZeros > oddZeros:
+		return EncodingUTF16BE
+	}
+
+	if truncated {
+		// Ignore a character cut in two by the end of the sample
+		for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(sample[i]) {
+				sample = sample[:i]
+				break
+			}
+		}
+	}
+	if utf8.Valid(sample) {
+		return EncodingUTF8
+	}
+	return EncodingLatin1
+}
+
+func detectDelimiter(line string) rune {
+	delimiter, most := DefaultFileFormat.Delimiter, 0
+	for _, candidate := range detectedDelimiters {
+		if count := strings.Count(line, string(candidate)); count > most {
+			delimiter, most = candidate, count
+		}
+	}
+	return delimiter
+}
+
+// detectHeader treats the first line as a header if it looks like column names and the second line
+// does not, or if the fields are upper case like the FactSet column names.
+func detectHeader(lines []string, delimiter rune) bool {
+	if lines[0] == "" || !allFieldsMatch(lines[0], delimiter, headerFieldPattern) {
+		return false
+	}
+	if len(lines) < 2 || lines[1] == "" || !allFieldsMatch(lines[1], delimiter, headerFieldPattern) {
+		return true
+	}
+	return allFieldsMatch(lines[0], delimiter, columnNamePattern)
+}
+
+func allFieldsMatch(line string, delimiter rune, pattern *regexp.Regexp) bool {
+	for _, field := range strings.Split(line, string(delimiter)) {
+		if !pattern.MatchString(strings.Trim(field, `"`)) {
+			return false
+		}
+	}
+	return true
+}
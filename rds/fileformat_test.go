@@ -0,0 +1,105 @@
+package rds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeUTF16(s string, bigEndian bool, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	b := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func writeDataFile(t *testing.T, contents []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "factset-fileformat")
+	assert.NoError(t, err)
+	filename := filepath.Join(dir, "ppl_names.txt")
+	assert.NoError(t, ioutil.WriteFile(filename, contents, 0644))
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func TestDetectFileFormat(t *testing.T) {
+	factsetFile := "FACTSET_PERSON_ID|NAME\r\n0001|Zoë\r\n"
+	testCases := []struct {
+		testName string
+		contents []byte
+		expected FileFormat
+	}{
+		{"FactSet default", []byte(factsetFile), DefaultFileFormat},
+		{"Unix line endings", []byte("FACTSET_PERSON_ID|NAME\n0001|Alice\n"), FileFormat{'|', "\n", EncodingUTF8, true}},
+		{"Carriage return line endings", []byte("FACTSET_PERSON_ID|NAME\r0001|Alice\r"), FileFormat{'|', "\r", EncodingUTF8, true}},
+		{"Tab delimited", []byte("ISO_CODE\tNAME\r\nGB\tUnited Kingdom\r\n"), FileFormat{'\t', "\r\n", EncodingUTF8, true}},
+		{"No header", []byte("0001|Alice\r\n0002|Bob\r\n"), FileFormat{'|', "\r\n", EncodingUTF8, false}},
+		{"Upper case header above text rows", []byte("ISO_CODE|NAME\r\nGB|Britain\r\n"), DefaultFileFormat},
+		{"Text rows without a header", []byte("gb|Britain\r\nfr|France\r\n"), FileFormat{'|', "\r\n", EncodingUTF8, false}},
+		{"Latin-1", []byte("FACTSET_PERSON_ID|NAME\r\n0001|Zo\xeb\r\n"), FileFormat{'|', "\r\n", EncodingLatin1, true}},
+		{"UTF-16LE with byte order mark", encodeUTF16(factsetFile, false, true), FileFormat{'|', "\r\n", EncodingUTF16LE, true}},
+		{"UTF-16BE with byte order mark", encodeUTF16(factsetFile, true, true), FileFormat{'|', "\r\n", EncodingUTF16BE, true}},
+		{"UTF-16LE without byte order mark", encodeUTF16(factsetFile, false, false), FileFormat{'|', "\r\n", EncodingUTF16LE, true}},
+		{"Empty file", []byte{}, FileFormat{'|', "\r\n", EncodingUTF8, false}},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			filename, cleanup := writeDataFile(t, d.contents)
+			defer cleanup()
+
+			format, err := DetectFileFormat(filename)
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			assert.Equal(t, d.expected, format, "Test %s failed, detected %s", d.testName, format)
+		})
+	}
+}
+
+func TestReadDataFileFormats(t *testing.T) {
+	expected := [][]interface{}{{"0001", "Zoë"}, {"0002", nil}}
+	testCases := []struct {
+		testName string
+		contents []byte
+		format   FileFormat
+	}{
+		{"FactSet default", []byte("FACTSET_PERSON_ID|NAME\r\n0001|Zoë\r\n0002|\r\n"), DefaultFileFormat},
+		{"UTF-8 byte order mark and no header", []byte("\xef\xbb\xbf0001|Zoë\r\n0002|\r\n"), FileFormat{'|', "\r\n", EncodingUTF8, false}},
+		{"Latin-1", []byte("FACTSET_PERSON_ID|NAME\r\n0001|Zo\xeb\r\n0002|\r\n"), FileFormat{'|', "\r\n", EncodingLatin1, true}},
+		{"UTF-16LE", encodeUTF16("FACTSET_PERSON_ID|NAME\r\n0001|Zoë\r\n0002|\r\n", false, true), FileFormat{'|', "\r\n", EncodingUTF16LE, true}},
+		{"UTF-16BE", encodeUTF16("FACTSET_PERSON_ID|NAME\r\n0001|Zoë\r\n0002|\r\n", true, false), FileFormat{'|', "\r\n", EncodingUTF16BE, true}},
+		{"Tab delimited with carriage returns", []byte("0001\tZoë\r0002\t\r"), FileFormat{'\t', "\r", EncodingUTF8, false}},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			filename, cleanup := writeDataFile(t, d.contents)
+			defer cleanup()
+
+			var rows [][]interface{}
+			count, err := readDataFile(filename, d.format, emptyIsNull, func(row []interface{}) error {
+				rows = append(rows, row)
+				return nil
+			})
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			assert.Equal(t, len(expected), count, "Test %s failed, unexpected row count", d.testName)
+			assert.Equal(t, expected, rows, "Test %s failed, unexpected rows", d.testName)
+		})
+	}
+}
+
+func TestFileFormatValidate(t *testing.T) {
+	assert.NoError(t, DefaultFileFormat.Validate())
+	assert.Error(t, FileFormat{';', "\r\n", EncodingUTF8, true}.Validate())
+	assert.Error(t, FileFormat{'|', "\n\r", EncodingUTF8, true}.Validate())
+	assert.Error(t, FileFormat{'|', "\r\n", "utf-32", true}.Validate())
+}
@@ -0,0 +1,101 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// Outcomes recorded in the load history
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeUpToDate  = "up-to-date"
+	OutcomeFailed    = "failed"
+	// OutcomeInterrupted loads were stopped part way because the uploader was shutting down
+	OutcomeInterrupted = "interrupted"
+)
+
+// LoadHistory - a record of an attempt to load a package, or one of its tables, kept in metadata_load_history.
+// Records are only ever added so earlier loads can be audited after the metadata tables have moved on.
+type LoadHistory struct {
+	RunID   string
+	Package factset.Package
+	Archive string
+	// Table is empty for the record of the package as a whole
+	Table    string
+	Version  factset.PackageVersion
+	RowCount int64
+	Duration time.Duration
+	Outcome  string
+	Error    string
+	Started  time.Time
+}
+
+// LoadHistoryQuery - restricts the load history returned; empty fields match everything
+type LoadHistoryQuery struct {
+	RunID   string
+	Product string
+	Bundle  string
+	// Limit is the maximum number of records to return, the most recent first
+	Limit int
+}
+
+// RecordLoadHistory - appends a record to the load history
+func (c *Client) RecordLoadHistory(ctx context.Context, entry LoadHistory) error {
+	queryTemplate := `INSERT INTO metadata_load_history
+						(run_id, product, bundle, feed_version, archive, tablename, version_feed_version, version_sequence, row_count, duration_ms, outcome, error_message, started)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	var errorMessage sql.NullString
+	if entry.Error != "" {
+		errorMessage = sql.NullString{String: entry.Error, Valid: true}
+	}
+	_, err := c.DB.ExecContext(ctx, c.dialect.Rebind(queryTemplate),
+		entry.RunID, entry.Package.Product, entry.Package.Bundle, entry.Package.FeedVersion, entry.Archive, entry.Table,
+		entry.Version.FeedVersion, entry.Version.Sequence, entry.RowCount, int64(entry.Duration/time.Millisecond),
+		entry.Outcome, errorMessage, entry.Started.UTC())
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": entry.Package.Product}).Errorf("Error recording load history for product: %s", entry.Package.Product)
+		return err
+	}
+	return nil
+}
+
+// GetLoadHistory - returns the load history matching the query, the most recent first
+func (c *Client) GetLoadHistory(ctx context.Context, query LoadHistoryQuery) ([]LoadHistory, error) {
+	queryTemplate := `SELECT run_id, product, bundle, feed_version, archive, tablename, version_feed_version, version_sequence, row_count, duration_ms, outcome, error_message, started
+						FROM metadata_load_history
+						WHERE (? = '' OR run_id = ?) AND (? = '' OR product = ?) AND (? = '' OR bundle = ?)
+						ORDER BY id DESC`
+	args := []interface{}{query.RunID, query.RunID, query.Product, query.Product, query.Bundle, query.Bundle}
+	if query.Limit > 0 {
+		queryTemplate += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	rows, err := c.DB.QueryContext(ctx, c.dialect.Rebind(queryTemplate), args...)
+	if err != nil {
+		log.WithError(err).Error("Error querying load history")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []LoadHistory
+	for rows.Next() {
+		var entry LoadHistory
+		var durationMs int64
+		var errorMessage sql.NullString
+		err := rows.Scan(&entry.RunID, &entry.Package.Product, &entry.Package.Bundle, &entry.Package.FeedVersion, &entry.Archive, &entry.Table,
+			&entry.Version.FeedVersion, &entry.Version.Sequence, &entry.RowCount, &durationMs, &entry.Outcome, &errorMessage, &entry.Started)
+		if err != nil {
+			log.WithError(err).Error("Error scanning load history")
+			return nil, err
+		}
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		entry.Error = errorMessage.String
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
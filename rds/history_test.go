@@ -0,0 +1,53 @@
+package rds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRecordAndGetLoadHistory(t *testing.T) {
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	names := factset.Package{Product: "ppl_test", Bundle: "ppl_test", FeedVersion: 1}
+	entities := factset.Package{Product: "ent_test", Bundle: "ent_test", FeedVersion: 1}
+	started := time.Date(2017, 6, 7, 8, 9, 10, 0, time.UTC)
+	entries := []LoadHistory{
+		{RunID: "run-1", Package: names, Archive: "ppl_test_v1_full_1234.zip", Table: "ppl_names", Version: factset.PackageVersion{FeedVersion: 1, Sequence: 1234}, RowCount: 5, Duration: 1500 * time.Millisecond, Outcome: OutcomeSucceeded, Started: started},
+		{RunID: "run-1", Package: names, Archive: "ppl_test_v1_full_1234.zip", Version: factset.PackageVersion{FeedVersion: 1, Sequence: 1234}, RowCount: 5, Duration: 2 * time.Second, Outcome: OutcomeSucceeded, Started: started},
+		{RunID: "run-1", Package: entities, Archive: "ent_test_v1_full_1234.zip", Table: "ent_names", Outcome: OutcomeFailed, Error: "could not load table", Started: started},
+		{RunID: "run-2", Package: names, Version: factset.PackageVersion{FeedVersion: 1, Sequence: 1234}, Outcome: OutcomeUpToDate, Started: started.Add(time.Hour)},
+	}
+	for _, entry := range entries {
+		assert.NoError(t, dbClient.RecordLoadHistory(context.Background(), entry))
+	}
+
+	testCases := []struct {
+		testName string
+		query    LoadHistoryQuery
+		expected []LoadHistory
+	}{
+		{"Returns everything, most recent first", LoadHistoryQuery{}, []LoadHistory{entries[3], entries[2], entries[1], entries[0]}},
+		{"Filters by run", LoadHistoryQuery{RunID: "run-2"}, []LoadHistory{entries[3]}},
+		{"Filters by product", LoadHistoryQuery{Product: "ent_test"}, []LoadHistory{entries[2]}},
+		{"Filters by product and bundle", LoadHistoryQuery{Product: "ppl_test", Bundle: "ppl_test"}, []LoadHistory{entries[3], entries[1], entries[0]}},
+		{"Limits the records returned", LoadHistoryQuery{Limit: 2}, []LoadHistory{entries[3], entries[2]}},
+	}
+	for _, d := range testCases {
+		t.Run(d.testName, func(t *testing.T) {
+			history, err := dbClient.GetLoadHistory(context.Background(), d.query)
+			assert.NoError(t, err, "Test %s failed", d.testName)
+			assert.Equal(t, len(d.expected), len(history), "Test %s failed, unexpected number of records", d.testName)
+			for i := range history {
+				assert.True(t, d.expected[i].Started.Equal(history[i].Started), "Test %s failed, unexpected start time", d.testName)
+				history[i].Started = d.expected[i].Started
+			}
+			assert.Equal(t, d.expected, history, "Test %s failed, unexpected records", d.testName)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package rds
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// FactSet table and column names are plain lower case identifiers, anything else is refused rather than escaped
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// Identifier - a validated table or column name that is safe to quote into a query
+type Identifier struct {
+	name string
+}
+
+// NewIdentifier - validates name, which will often come from an archive member or schema file
+func NewIdentifier(name string) (Identifier, error) {
+	if !identifierPattern.MatchString(name) {
+		return Identifier{}, fmt.Errorf("%q is not a valid table or column name", name)
+	}
+	return Identifier{name: name}, nil
+}
+
+func newIdentifiers(names []string) ([]Identifier, error) {
+	identifiers := make([]Identifier, 0, len(names))
+	for _, name := range names {
+		identifier, err := NewIdentifier(name)
+		if err != nil {
+			return nil, err
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	return identifiers, nil
+}
+
+func (i Identifier) String() string {
+	return i.name
+}
@@ -0,0 +1,48 @@
+package rds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIdentifier(t *testing.T) {
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{"ppl_names", true},
+		{"PPL_NAMES", true},
+		{"_tmp1", true},
+		{"", false},
+		{"1ppl", false},
+		{"ppl_names; DROP TABLE metadata_package_version", false},
+		{"ppl_names`", false},
+		{"ppl.names", false},
+		{"../ppl_names", false},
+		{"ppl names", false},
+	}
+	for _, d := range testCases {
+		identifier, err := NewIdentifier(d.name)
+		if d.valid {
+			assert.NoError(t, err, "%q should be a valid identifier", d.name)
+			assert.Equal(t, d.name, identifier.String())
+		} else {
+			assert.Error(t, err, "%q should not be a valid identifier", d.name)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	identifier, err := NewIdentifier("PPL_Names")
+	assert.NoError(t, err)
+	assert.Equal(t, "`PPL_Names`", (&mysqlDialect{}).QuoteIdentifier(identifier))
+	assert.Equal(t, `"ppl_names"`, (&postgresDialect{}).QuoteIdentifier(identifier))
+	assert.Equal(t, `"PPL_Names"`, (&sqliteDialect{}).QuoteIdentifier(identifier))
+
+	tables, err := newIdentifiers([]string{"ppl_names", "ppl_jobs"})
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLES IF EXISTS `ppl_names`, `ppl_jobs`", (&mysqlDialect{}).DropTablesQuery(tables))
+	assert.Equal(t, `DROP TABLE IF EXISTS "ppl_names", "ppl_jobs"`, (&postgresDialect{}).DropTablesQuery(tables))
+	assert.Equal(t, `DROP TABLE IF EXISTS "ppl_names"; DROP TABLE IF EXISTS "ppl_jobs"`, (&sqliteDialect{}).DropTablesQuery(tables))
+}
@@ -0,0 +1,121 @@
+package rds
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DefaultInsertBatchSize - rows written per INSERT when a data file can not be bulk loaded
+const DefaultInsertBatchSize = 500
+
+// maxPlaceholders - the most parameters a MySQL prepared statement can take
+const maxPlaceholders = 65535
+
+// batchInserter - a dialect that can write data files with batched INSERTs when it can not bulk load them
+type batchInserter interface {
+	setInsertBatchSize(size int)
+}
+
+// SetInsertBatchSize - sets how many rows are written per INSERT when a data file can not be bulk loaded
+func (c *Client) SetInsertBatchSize(size int) {
+	if inserter, ok := c.dialect.(batchInserter); ok && size > 0 {
+		inserter.setInsertBatchSize(size)
+	}
+}
+
+// insertDataFile writes the rows of a data file with multi-row prepared INSERTs of up to batchSize rows, in a
+// single transaction. insert is the start of the statement up to VALUES, e.g. REPLACE INTO `table`, and isNull picks
+// the fields written as NULL. afterBatch, if set, is called on the transaction after each batch is written.
+func insertDataFile(ctx context.Context, db *sql.DB, insert string, columnCount, batchSize int, filename string, format FileFormat, isNull nullField, afterBatch func(tx *sql.Tx) error) (int64, error) {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if batchSize*columnCount > maxPlaceholders {
+		batchSize = maxPlaceholders / columnCount
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	// Only the full batch size and the final partial batch need a statement each
+	statements := make(map[int]*sql.Stmt)
+	defer func() {
+		for _, stmt := range statements {
+			stmt.Close()
+		}
+	}()
+
+	values := make([]interface{}, 0, batchSize*columnCount)
+	batched := 0
+	flush := func() error {
+		if batched == 0 {
+			return nil
+		}
+		stmt, ok := statements[batched]
+		if !ok {
+			stmt, err = tx.PrepareContext(ctx, insertQuery(insert, columnCount, batched))
+			if err != nil {
+				return err
+			}
+			statements[batched] = stmt
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+		values = values[:0]
+		batched = 0
+		if afterBatch != nil {
+			return afterBatch(tx)
+		}
+		return nil
+	}
+
+	rows, err := readDataFile(filename, format, isNull, func(row []interface{}) error {
+		values = append(values, fitRow(row, columnCount)...)
+		batched++
+		if batched == batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(rows), nil
+}
+
+// insertQuery builds an INSERT of rows rows of columnCount placeholders
+func insertQuery(insert string, columnCount, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columnCount), ", ") + ")"
+	var b bytes.Buffer
+	b.WriteString(insert)
+	b.WriteString(" VALUES ")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(row)
+	}
+	return b.String()
+}
+
+// fitRow pads or truncates a row from a data file to the number of columns in its table, as LOAD DATA does
+func fitRow(row []interface{}, columnCount int) []interface{} {
+	if len(row) > columnCount {
+		return row[:columnCount]
+	}
+	for len(row) < columnCount {
+		row = append(row, nil)
+	}
+	return row
+}
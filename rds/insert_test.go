@@ -0,0 +1,90 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertDataFile(t *testing.T) {
+	if dbClient.dialect.Name() != "sqlite" {
+		t.Skip("INSERT batches are exercised against SQLite, which accepts the same multi-row syntax as MySQL")
+	}
+	filename, cleanup := writeDataFile(t, []byte("ID\r\n0001\r\n0002\r\n0003\r\n0004\r\n0005\r\n0005\r\n"))
+	defer cleanup()
+
+	for _, batchSize := range []int{1, 2, 5, 6, 1000} {
+		t.Run(fmt.Sprintf("Batches of %d rows", batchSize), func(t *testing.T) {
+			defer dropTestTables()
+			_, err := dbClient.DB.Exec(`CREATE TABLE foo_test1 (ID VARCHAR(10) NOT NULL, NAME VARCHAR(10), PRIMARY KEY (ID))`)
+			assert.NoError(t, err)
+
+			batches := 0
+			rows, err := insertDataFile(context.Background(), dbClient.DB, `INSERT OR REPLACE INTO "foo_test1"`, 2, batchSize, filename, DefaultFileFormat, emptyIsNull, func(tx *sql.Tx) error {
+				batches++
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, int64(6), rows, "Every row in the file should have been written")
+			assert.Equal(t, (6+batchSize-1)/batchSize, batches, "Unexpected number of batches")
+
+			var count int
+			assert.NoError(t, dbClient.DB.QueryRow(`SELECT COUNT(*) FROM foo_test1`).Scan(&count))
+			assert.Equal(t, 5, count, "Rows with the same key should have been replaced")
+		})
+	}
+}
+
+func TestInsertDataFileRollsBackOnError(t *testing.T) {
+	if dbClient.dialect.Name() != "sqlite" {
+		t.Skip("INSERT batches are exercised against SQLite")
+	}
+	defer dropTestTables()
+	_, err := dbClient.DB.Exec(`CREATE TABLE foo_test1 (ID VARCHAR(10) NOT NULL)`)
+	assert.NoError(t, err)
+	filename, cleanup := writeDataFile(t, []byte("ID\r\n0001\r\n0002\r\n\r\n"))
+	defer cleanup()
+
+	_, err = insertDataFile(context.Background(), dbClient.DB, `INSERT INTO "foo_test1"`, 1, 2, filename, FileFormat{'|', "\r\n", EncodingUTF8, true}, emptyIsNull, func(tx *sql.Tx) error {
+		return errors.New("could not read warnings")
+	})
+	assert.Error(t, err)
+
+	var count int
+	assert.NoError(t, dbClient.DB.QueryRow(`SELECT COUNT(*) FROM foo_test1`).Scan(&count))
+	assert.Equal(t, 0, count, "No rows should have been written")
+}
+
+func TestInsertQuery(t *testing.T) {
+	assert.Equal(t, "REPLACE INTO `t` VALUES (?, ?, ?)", insertQuery("REPLACE INTO `t`", 3, 1))
+	assert.Equal(t, "REPLACE INTO `t` VALUES (?, ?), (?, ?), (?, ?)", insertQuery("REPLACE INTO `t`", 2, 3))
+}
+
+func TestFitRow(t *testing.T) {
+	assert.Equal(t, []interface{}{"a", nil, nil}, fitRow([]interface{}{"a"}, 3))
+	assert.Equal(t, []interface{}{"a", "b"}, fitRow([]interface{}{"a", "b", "c"}, 2))
+}
+
+func TestIsLocalInfileRejected(t *testing.T) {
+	assert.True(t, isLocalInfileRejected(&mysql.MySQLError{Number: 1148, Message: "The used command is not allowed with this MySQL version"}))
+	assert.True(t, isLocalInfileRejected(&mysql.MySQLError{Number: 3948, Message: "Loading local data is disabled; this must be enabled on both the client and server sides"}))
+	assert.False(t, isLocalInfileRejected(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	assert.False(t, isLocalInfileRejected(errors.New("Error 1148: The used command is not allowed")))
+	assert.False(t, isLocalInfileRejected(nil))
+}
+
+func TestClientSetInsertBatchSize(t *testing.T) {
+	dialect := &mysqlDialect{}
+	client := &Client{dialect: dialect}
+	client.SetInsertBatchSize(250)
+	assert.Equal(t, 250, dialect.insertBatchSize)
+	client.SetInsertBatchSize(0)
+	assert.Equal(t, 250, dialect.insertBatchSize, "A batch size of 0 should be ignored")
+
+	(&Client{dialect: &postgresDialect{}}).SetInsertBatchSize(250)
+}
@@ -0,0 +1,254 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Locks are rows in metadata_lock rather than database specific locks so they behave the same on every dialect
+// and do not depend on holding a single connection open. A lock that outlives its expiry is assumed to belong to
+// a run that died while holding it and is taken over.
+var (
+	lockExpiry        = 15 * time.Minute
+	lockRetryInterval = time.Second
+)
+
+// LockHeldError - returned when a lock is still held by another uploader once the wait for it is over
+type LockHeldError struct {
+	Name   string
+	Holder string
+	Waited time.Duration
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for lock %s held by %s", e.Waited, e.Name, e.Holder)
+}
+
+// IsLockHeld - reports whether the error is a LockHeldError
+func IsLockHeld(err error) bool {
+	_, ok := err.(*LockHeldError)
+	return ok
+}
+
+// LockLostError - reported by a lease whose lock could not be renewed, after which another uploader may take it
+type LockLostError struct {
+	Name string
+	Err  error
+}
+
+func (e *LockLostError) Error() string {
+	return fmt.Sprintf("lost lock %s: %s", e.Name, e.Err)
+}
+
+// IsLockLost - reports whether the error is a LockLostError
+func IsLockLost(err error) bool {
+	_, ok := err.(*LockLostError)
+	return ok
+}
+
+// Lease - a lock that is renewed in the background until it is released. If a renewal fails the lock is no longer
+// renewed, Lost is closed and Err returns a LockLostError, so the holder can stop before another uploader takes it.
+type Lease interface {
+	Lost() <-chan struct{}
+	Err() error
+	Release() error
+}
+
+// lockTableStatements - the table holding the locks taken by running uploaders
+var lockTableStatements = map[string]string{
+	"mysql": `CREATE TABLE IF NOT EXISTS metadata_lock (
+			lock_name varchar(255) NOT NULL,
+			owner varchar(255) NOT NULL,
+			acquired DATETIME NOT NULL,
+			expires DATETIME NOT NULL,
+			PRIMARY KEY (lock_name)
+		);`,
+	"postgres": `CREATE TABLE IF NOT EXISTS metadata_lock (
+			lock_name varchar(255) NOT NULL,
+			owner varchar(255) NOT NULL,
+			acquired TIMESTAMP NOT NULL,
+			expires TIMESTAMP NOT NULL,
+			PRIMARY KEY (lock_name)
+		);`,
+	"sqlite": `CREATE TABLE IF NOT EXISTS metadata_lock (
+			lock_name varchar(255) NOT NULL,
+			owner varchar(255) NOT NULL,
+			acquired DATETIME NOT NULL,
+			expires DATETIME NOT NULL,
+			PRIMARY KEY (lock_name)
+		);`,
+}
+
+// lockOwner - identifies this process as the holder of a lock
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// acquireLock waits up to timeout for the named lock to be free and takes it for owner, giving up early if the
+// context is done
+func (c *Client) acquireLock(ctx context.Context, name, owner string, timeout time.Duration) error {
+	if _, err := c.DB.ExecContext(ctx, lockTableStatements[c.dialect.Name()]); err != nil {
+		log.WithError(err).Error("Error running query to create metadata_lock table")
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		holder, err := c.tryLock(ctx, name, owner)
+		if err != nil {
+			log.WithError(err).Errorf("Error taking lock %s", name)
+			return err
+		}
+		if holder == owner {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &LockHeldError{Name: name, Holder: holder, Waited: timeout}
+		}
+		log.Infof("Waiting for lock %s held by %s", name, holder)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// tryLock attempts to take the named lock once, returning the owner of the lock afterwards. An insert that fails
+// while nobody holds the lock is retried once, in case the lock was released in between, and is then reported as an
+// error so that the caller does not wait on a lock that can never be taken.
+func (c *Client) tryLock(ctx context.Context, name, owner string) (string, error) {
+	now := time.Now().UTC()
+	if _, err := c.DB.ExecContext(ctx, c.dialect.Rebind(`DELETE FROM metadata_lock WHERE lock_name = ? AND expires < ?`), name, now); err != nil {
+		return "", err
+	}
+
+	var insertErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		_, insertErr = c.DB.ExecContext(ctx, c.dialect.Rebind(`INSERT INTO metadata_lock (lock_name, owner, acquired, expires) VALUES (?, ?, ?, ?)`),
+			name, owner, now, now.Add(lockExpiry))
+		if insertErr == nil {
+			return owner, nil
+		}
+
+		var holder string
+		err := c.DB.QueryRowContext(ctx, c.dialect.Rebind(`SELECT owner FROM metadata_lock WHERE lock_name = ?`), name).Scan(&holder)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", insertErr
+		}
+		return holder, nil
+	}
+	return "", insertErr
+}
+
+// releaseLock gives up the named lock if it is still held by owner
+func (c *Client) releaseLock(name, owner string) error {
+	_, err := c.DB.Exec(c.dialect.Rebind(`DELETE FROM metadata_lock WHERE lock_name = ? AND owner = ?`), name, owner)
+	return err
+}
+
+// renewLock extends the expiry of the named lock if it is still held by owner
+func (c *Client) renewLock(name, owner string) error {
+	result, err := c.DB.Exec(c.dialect.Rebind(`UPDATE metadata_lock SET expires = ? WHERE lock_name = ? AND owner = ?`),
+		time.Now().UTC().Add(lockExpiry), name, owner)
+	if err != nil {
+		return err
+	}
+	if renewed, err := result.RowsAffected(); err == nil && renewed > 0 {
+		return nil
+	}
+	// MySQL counts rows changed rather than matched, so check the lock is really gone
+	var holder string
+	err = c.DB.QueryRow(c.dialect.Rebind(`SELECT owner FROM metadata_lock WHERE lock_name = ?`), name).Scan(&holder)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if holder != owner {
+		return fmt.Errorf("lock %s is no longer held by %s", name, owner)
+	}
+	return nil
+}
+
+// AcquireLease - waits up to wait for the named lock to be free and takes it, returning a LockHeldError if it is
+// still held by another uploader. The lock is renewed until it is released so it does not expire during long loads;
+// the context only bounds the wait, so a lease outlives it and must always be released.
+func (c *Client) AcquireLease(ctx context.Context, name string, wait time.Duration) (Lease, error) {
+	owner := lockOwner()
+	if err := c.acquireLock(ctx, name, owner, wait); err != nil {
+		return nil, err
+	}
+	l := &lease{client: c, name: name, owner: owner, stop: make(chan struct{}), done: make(chan struct{}), lostCh: make(chan struct{})}
+	go l.renew(lockExpiry / 3)
+	return l, nil
+}
+
+type lease struct {
+	client *Client
+	name   string
+	owner  string
+	stop   chan struct{}
+	done   chan struct{}
+	lostCh chan struct{}
+
+	mu   sync.Mutex
+	lost error
+}
+
+func (l *lease) renew(interval time.Duration) {
+	defer close(l.done)
+	if interval <= 0 {
+		interval = lockRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.client.renewLock(l.name, l.owner); err != nil {
+				log.WithError(err).Errorf("Could not renew lock %s", l.name)
+				l.mu.Lock()
+				l.lost = &LockLostError{Name: l.name, Err: err}
+				l.mu.Unlock()
+				close(l.lostCh)
+				return
+			}
+		}
+	}
+}
+
+// Lost - closed once a renewal of the lock fails
+func (l *lease) Lost() <-chan struct{} {
+	return l.lostCh
+}
+
+// Err - the LockLostError once a renewal of the lock has failed, otherwise nil
+func (l *lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// Release - stops renewing the lock and gives it up, returning the LockLostError if a renewal failed
+func (l *lease) Release() error {
+	close(l.stop)
+	<-l.done
+	if err := l.client.releaseLock(l.name, l.owner); err != nil {
+		return err
+	}
+	return l.Err()
+}
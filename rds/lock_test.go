@@ -0,0 +1,128 @@
+package rds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockIsExclusive(t *testing.T) {
+	defer removeMetadataTables()
+	defer func(interval time.Duration) { lockRetryInterval = interval }(lockRetryInterval)
+	lockRetryInterval = 10 * time.Millisecond
+
+	err := dbClient.acquireLock(context.Background(), "test", "first", time.Second)
+	assert.NoError(t, err)
+
+	err = dbClient.acquireLock(context.Background(), "test", "second", 50*time.Millisecond)
+	assert.Error(t, err, "Lock should not be taken while it is held")
+	assert.Contains(t, err.Error(), "held by first")
+
+	err = dbClient.acquireLock(context.Background(), "other", "second", 50*time.Millisecond)
+	assert.NoError(t, err, "Locks with different names should not block each other")
+
+	assert.NoError(t, dbClient.releaseLock("test", "second"))
+	err = dbClient.acquireLock(context.Background(), "test", "second", 50*time.Millisecond)
+	assert.Error(t, err, "Lock should only be released by its owner")
+
+	assert.NoError(t, dbClient.releaseLock("test", "first"))
+	err = dbClient.acquireLock(context.Background(), "test", "second", 50*time.Millisecond)
+	assert.NoError(t, err, "Lock should be taken once released")
+}
+
+func TestLockTakesOverExpiredLock(t *testing.T) {
+	defer removeMetadataTables()
+	defer func(expiry time.Duration) { lockExpiry = expiry }(lockExpiry)
+	lockExpiry = -time.Minute
+
+	err := dbClient.acquireLock(context.Background(), "test", "crashed", time.Second)
+	assert.NoError(t, err)
+
+	err = dbClient.acquireLock(context.Background(), "test", "second", 0)
+	assert.NoError(t, err, "Expired lock should have been taken over")
+}
+
+func TestLockStopsWaitingWhenCancelled(t *testing.T) {
+	defer removeMetadataTables()
+	defer func(interval time.Duration) { lockRetryInterval = interval }(lockRetryInterval)
+	lockRetryInterval = 10 * time.Millisecond
+
+	err := dbClient.acquireLock(context.Background(), "test", "first", time.Second)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = dbClient.acquireLock(ctx, "test", "second", time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(started) < 10*time.Second, "Should have stopped waiting once cancelled")
+}
+
+func TestLeaseIsRenewedUntilReleased(t *testing.T) {
+	defer removeMetadataTables()
+	defer func(expiry, interval time.Duration) {
+		lockExpiry = expiry
+		lockRetryInterval = interval
+	}(lockExpiry, lockRetryInterval)
+	lockExpiry = 3 * time.Second
+	lockRetryInterval = 10 * time.Millisecond
+
+	lease, err := dbClient.AcquireLease(context.Background(), "load", 0)
+	assert.NoError(t, err)
+
+	// without renewal the lease would have expired and been taken over
+	time.Sleep(4 * time.Second)
+	_, err = dbClient.AcquireLease(context.Background(), "load", 0)
+	if assert.Error(t, err, "Lease should still be held") {
+		assert.True(t, IsLockHeld(err), "Contention should be reported as a LockHeldError")
+	}
+
+	assert.NoError(t, lease.Release())
+	second, err := dbClient.AcquireLease(context.Background(), "load", 0)
+	assert.NoError(t, err, "Lease should be taken once released")
+	assert.NoError(t, second.Release())
+}
+
+func TestLeaseReportsLostLock(t *testing.T) {
+	defer removeMetadataTables()
+	defer func(expiry time.Duration) { lockExpiry = expiry }(lockExpiry)
+	lockExpiry = 3 * time.Second
+
+	lease, err := dbClient.AcquireLease(context.Background(), "load", 0)
+	assert.NoError(t, err)
+	_, err = dbClient.DB.Exec(`DELETE FROM metadata_lock`)
+	assert.NoError(t, err)
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Lease should report the lock was lost while it is held")
+	}
+	assert.True(t, IsLockLost(lease.Err()), "Loss should be reported as a LockLostError")
+	err = lease.Release()
+	if assert.Error(t, err, "Release should report the lock was lost") {
+		assert.True(t, IsLockLost(err), "Loss should be reported as a LockLostError")
+		assert.Contains(t, err.Error(), "lock load is no longer held")
+	}
+}
+
+func TestLockReportsFailedInsert(t *testing.T) {
+	if dbClient.dialect.Name() != "sqlite" {
+		t.Skip("Inserts into the lock table are made to fail with an SQLite trigger")
+	}
+	defer removeMetadataTables()
+	err := dbClient.acquireLock(context.Background(), "test", "first", 0)
+	assert.NoError(t, err)
+	assert.NoError(t, dbClient.releaseLock("test", "first"))
+	_, err = dbClient.DB.Exec(`CREATE TRIGGER metadata_lock_readonly BEFORE INSERT ON metadata_lock BEGIN SELECT RAISE(ABORT, 'attempt to write a readonly database'); END`)
+	assert.NoError(t, err)
+
+	started := time.Now()
+	err = dbClient.acquireLock(context.Background(), "test", "second", time.Minute)
+	if assert.Error(t, err, "Lock should not be taken when it can not be inserted") {
+		assert.Contains(t, err.Error(), "readonly")
+	}
+	assert.True(t, time.Since(started) < 10*time.Second, "Should not have waited for a lock nobody holds")
+}
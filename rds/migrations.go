@@ -0,0 +1,325 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// migration - a forward change to the uploader's own metadata tables
+type migration struct {
+	version     int
+	description string
+	// statements to run for each dialect, keyed by Dialect.Name()
+	statements map[string][]string
+}
+
+// migrations - applied in order, and never edited once released; add a new migration instead
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Create metadata_package_version and metadata_table_version",
+		statements: map[string][]string{
+			"mysql":    (&mysqlDialect{}).MetadataTableStatements(),
+			"postgres": (&postgresDialect{}).MetadataTableStatements(),
+			"sqlite":   (&sqliteDialect{}).MetadataTableStatements(),
+		},
+	},
+	{
+		version:     2,
+		description: "Key metadata_package_version on product, bundle and feed version",
+		statements: map[string][]string{
+			"mysql": {
+				`ALTER TABLE metadata_package_version ADD COLUMN feed_version INT NOT NULL DEFAULT 0 AFTER bundle`,
+				`UPDATE metadata_package_version SET feed_version = package_feed_version WHERE package_feed_version IS NOT NULL`,
+				`ALTER TABLE metadata_package_version DROP PRIMARY KEY, ADD PRIMARY KEY (product, bundle, feed_version)`,
+			},
+			"postgres": {
+				`ALTER TABLE metadata_package_version ADD COLUMN feed_version INT NOT NULL DEFAULT 0`,
+				`UPDATE metadata_package_version SET feed_version = package_feed_version WHERE package_feed_version IS NOT NULL`,
+				`ALTER TABLE metadata_package_version DROP CONSTRAINT metadata_package_version_pkey`,
+				`ALTER TABLE metadata_package_version ADD PRIMARY KEY (product, bundle, feed_version)`,
+			},
+			// SQLite can not change a primary key so the table is rebuilt
+			"sqlite": {
+				`CREATE TABLE metadata_package_version_new (
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					feed_version INT NOT NULL DEFAULT 0,
+					schema_feed_version INT,
+					schema_sequence INT,
+					schema_date_loaded DATETIME,
+					package_feed_version INT,
+					package_sequence INT,
+					package_date_loaded DATETIME,
+					PRIMARY KEY (product, bundle, feed_version)
+				)`,
+				`INSERT INTO metadata_package_version_new
+					(product, bundle, feed_version, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
+					SELECT product, bundle, COALESCE(package_feed_version, 0), schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded
+					FROM metadata_package_version`,
+				`DROP TABLE metadata_package_version`,
+				`ALTER TABLE metadata_package_version_new RENAME TO metadata_package_version`,
+			},
+		},
+	},
+	{
+		version:     3,
+		description: "Create metadata_load_history",
+		statements: map[string][]string{
+			"mysql": {
+				`CREATE TABLE metadata_load_history (
+					id BIGINT NOT NULL AUTO_INCREMENT,
+					run_id varchar(64) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					feed_version INT NOT NULL,
+					archive varchar(255) NOT NULL,
+					tablename varchar(255) NOT NULL,
+					version_feed_version INT NOT NULL,
+					version_sequence INT NOT NULL,
+					row_count BIGINT NOT NULL,
+					duration_ms BIGINT NOT NULL,
+					outcome varchar(32) NOT NULL,
+					error_message TEXT,
+					started DATETIME NOT NULL,
+					PRIMARY KEY (id)
+				)`,
+				`CREATE INDEX metadata_load_history_product ON metadata_load_history (product, bundle, started)`,
+			},
+			"postgres": {
+				`CREATE TABLE metadata_load_history (
+					id BIGSERIAL PRIMARY KEY,
+					run_id varchar(64) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					feed_version INT NOT NULL,
+					archive varchar(255) NOT NULL,
+					tablename varchar(255) NOT NULL,
+					version_feed_version INT NOT NULL,
+					version_sequence INT NOT NULL,
+					row_count BIGINT NOT NULL,
+					duration_ms BIGINT NOT NULL,
+					outcome varchar(32) NOT NULL,
+					error_message TEXT,
+					started TIMESTAMP NOT NULL
+				)`,
+				`CREATE INDEX metadata_load_history_product ON metadata_load_history (product, bundle, started)`,
+			},
+			"sqlite": {
+				`CREATE TABLE metadata_load_history (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					run_id varchar(64) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					feed_version INT NOT NULL,
+					archive varchar(255) NOT NULL,
+					tablename varchar(255) NOT NULL,
+					version_feed_version INT NOT NULL,
+					version_sequence INT NOT NULL,
+					row_count BIGINT NOT NULL,
+					duration_ms BIGINT NOT NULL,
+					outcome varchar(32) NOT NULL,
+					error_message TEXT,
+					started DATETIME NOT NULL
+				)`,
+				`CREATE INDEX metadata_load_history_product ON metadata_load_history (product, bundle, started)`,
+			},
+		},
+	},
+	{
+		version:     4,
+		description: "Record row counts of the last load in metadata_table_version",
+		statements: map[string][]string{
+			"mysql": {
+				`ALTER TABLE metadata_table_version ADD COLUMN expected_rows BIGINT, ADD COLUMN loaded_rows BIGINT, ADD COLUMN warning_count BIGINT`,
+			},
+			"postgres": {
+				`ALTER TABLE metadata_table_version ADD COLUMN expected_rows BIGINT, ADD COLUMN loaded_rows BIGINT, ADD COLUMN warning_count BIGINT`,
+			},
+			// SQLite only adds one column per statement
+			"sqlite": {
+				`ALTER TABLE metadata_table_version ADD COLUMN expected_rows BIGINT`,
+				`ALTER TABLE metadata_table_version ADD COLUMN loaded_rows BIGINT`,
+				`ALTER TABLE metadata_table_version ADD COLUMN warning_count BIGINT`,
+			},
+		},
+	},
+	{
+		version:     5,
+		description: "Create metadata_table_schema",
+		statements: map[string][]string{
+			"mysql": {
+				`CREATE TABLE metadata_table_schema (
+					tablename varchar(255) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					column_count INT NOT NULL,
+					table_ddl TEXT NOT NULL,
+					date_created DATETIME NOT NULL,
+					PRIMARY KEY (tablename)
+				)`,
+			},
+			"postgres": {
+				`CREATE TABLE metadata_table_schema (
+					tablename varchar(255) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					column_count INT NOT NULL,
+					table_ddl TEXT NOT NULL,
+					date_created TIMESTAMP NOT NULL,
+					PRIMARY KEY (tablename)
+				)`,
+			},
+			"sqlite": {
+				`CREATE TABLE metadata_table_schema (
+					tablename varchar(255) NOT NULL,
+					product varchar(255) NOT NULL,
+					bundle varchar(255) NOT NULL,
+					column_count INT NOT NULL,
+					table_ddl TEXT NOT NULL,
+					date_created DATETIME NOT NULL,
+					PRIMARY KEY (tablename)
+				)`,
+			},
+		},
+	},
+}
+
+// schemaVersionStatements - the table recording which migrations have been applied
+var schemaVersionStatements = map[string]string{
+	"mysql": `CREATE TABLE IF NOT EXISTS metadata_schema_version (
+			version INT NOT NULL,
+			description varchar(255) NOT NULL,
+			date_applied DATETIME NOT NULL,
+			PRIMARY KEY (version)
+		);`,
+	"postgres": `CREATE TABLE IF NOT EXISTS metadata_schema_version (
+			version INT NOT NULL,
+			description varchar(255) NOT NULL,
+			date_applied TIMESTAMP NOT NULL,
+			PRIMARY KEY (version)
+		);`,
+	"sqlite": `CREATE TABLE IF NOT EXISTS metadata_schema_version (
+			version INT NOT NULL,
+			description varchar(255) NOT NULL,
+			date_applied DATETIME NOT NULL,
+			PRIMARY KEY (version)
+		);`,
+}
+
+// migrationLockName - the lock held while migrations are applied so that concurrent runs do not race
+const migrationLockName = "migrations"
+
+// migrationLockTimeout - how long to wait for another run to finish migrating
+var migrationLockTimeout = 5 * time.Minute
+
+// Migrate - creates the uploader's metadata tables, or migrates them to the latest version.
+// It should be called once at startup, before any packages are loaded.
+func (c *Client) Migrate() error {
+	return c.migrateTo(migrations[len(migrations)-1].version)
+}
+
+// SchemaBehindError - returned when the metadata tables have not been migrated to the version this uploader needs
+type SchemaBehindError struct {
+	Current int
+	Latest  int
+}
+
+func (e *SchemaBehindError) Error() string {
+	return fmt.Sprintf("metadata tables are at version %d but this uploader needs version %d; run load, daemon or reset to migrate them", e.Current, e.Latest)
+}
+
+// CheckSchema - checks that the metadata tables have been migrated to the latest version without changing them,
+// for commands that only read the database and may be run by a user without rights to migrate it.
+func (c *Client) CheckSchema() error {
+	latest := migrations[len(migrations)-1].version
+	current, err := c.schemaVersion()
+	if err != nil {
+		log.WithError(err).Error("Error reading metadata schema version")
+		return fmt.Errorf("could not read the metadata schema version, the metadata tables may not have been created; run load, daemon or reset to create them: %v", err)
+	}
+	if current < latest {
+		return &SchemaBehindError{Current: current, Latest: latest}
+	}
+	return nil
+}
+
+// migrateTo applies migrations up to and including the target version
+func (c *Client) migrateTo(target int) error {
+	if err := validateMigrations(migrations, []string{c.dialect.Name()}); err != nil {
+		log.WithError(err).Error("Metadata migrations are not valid")
+		return err
+	}
+
+	owner := lockOwner()
+	if err := c.acquireLock(context.Background(), migrationLockName, owner, migrationLockTimeout); err != nil {
+		log.WithError(err).Error("Error taking lock to migrate metadata tables")
+		return err
+	}
+	defer func() {
+		if err := c.releaseLock(migrationLockName, owner); err != nil {
+			log.WithError(err).Error("Error releasing lock after migrating metadata tables")
+		}
+	}()
+
+	if _, err := c.DB.Exec(schemaVersionStatements[c.dialect.Name()]); err != nil {
+		log.WithError(err).Error("Error running query to create metadata_schema_version table")
+		return err
+	}
+
+	// Read only once the lock is held, as another run may have just migrated
+	current, err := c.schemaVersion()
+	if err != nil {
+		log.WithError(err).Error("Error reading metadata schema version")
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current || m.version > target {
+			continue
+		}
+		statements := m.statements[c.dialect.Name()]
+		log.WithFields(log.Fields{"version": m.version}).Infof("Migrating metadata tables: %s", m.description)
+		for _, statement := range statements {
+			if _, err := c.DB.Exec(statement); err != nil {
+				log.WithError(err).WithFields(log.Fields{"version": m.version}).Errorf("Error running metadata migration: %s", m.description)
+				return err
+			}
+		}
+		_, err := c.DB.Exec(c.dialect.Rebind(`INSERT INTO metadata_schema_version (version, description, date_applied) VALUES (?, ?, ?)`),
+			m.version, m.description, time.Now().UTC())
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"version": m.version}).Error("Error recording metadata migration")
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaVersion - the most recent migration applied, or 0 if there are none
+func (c *Client) schemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := c.DB.QueryRow(`SELECT MAX(version) FROM metadata_schema_version`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// validateMigrations checks migrations are numbered in order from 1 and have statements for each dialect
+func validateMigrations(ms []migration, dialects []string) error {
+	for i, m := range ms {
+		if m.version != i+1 {
+			return fmt.Errorf("migration %q has version %d, expected %d", m.description, m.version, i+1)
+		}
+		for _, dialect := range dialects {
+			if len(m.statements[dialect]) == 0 {
+				return fmt.Errorf("migration %d has no statements for dialect %s", m.version, dialect)
+			}
+		}
+	}
+	return nil
+}
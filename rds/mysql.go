@@ -0,0 +1,259 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+// mysqlDialect - MySQL and Aurora, loading data with LOAD DATA LOCAL INFILE, or with batched INSERTs
+// where the server does not allow it
+type mysqlDialect struct {
+	insertBatchSize int
+	// localInfileRejected is set once the server has refused LOAD DATA LOCAL INFILE
+	localInfileRejected int32
+}
+
+func (d *mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (d *mysqlDialect) DriverName() string {
+	return "mysql"
+}
+
+func (d *mysqlDialect) ConnString(dsn string) string {
+	return fmt.Sprintf("%s?interpolateParams=true&parseTime=true&allowAllFiles=true", dsn)
+}
+
+func (d *mysqlDialect) Schema(dsn string) string {
+	return dsn[strings.LastIndex(dsn, "/")+1:]
+}
+
+func (d *mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+// MetadataTableStatements - the original metadata tables, which later migrations build on
+func (d *mysqlDialect) MetadataTableStatements() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS metadata_package_version (
+			product varchar(255) NOT NULL,
+			bundle varchar(255) NOT NULL,
+			schema_feed_version INT,
+			schema_sequence INT,
+			schema_date_loaded DATETIME,
+			package_feed_version INT,
+			package_sequence INT,
+			package_date_loaded DATETIME,
+			PRIMARY KEY (product)
+		);`,
+		`CREATE TABLE IF NOT EXISTS metadata_table_version (
+			tablename varchar(255) NOT NULL,
+			feed_version INT,
+			sequence INT,
+			date_loaded DATETIME,
+			product varchar(255) NOT NULL,
+			bundle  varchar(255) NOT NULL,
+			PRIMARY KEY (tablename)
+		);`,
+	}
+}
+
+func (d *mysqlDialect) UpsertTableMetadataQuery() string {
+	return `REPLACE INTO metadata_table_version
+						(tablename, feed_version, sequence, date_loaded, product, bundle)
+						VALUES (?, ?, ?, NOW(), ?, ?)`
+}
+
+func (d *mysqlDialect) UpsertPackageMetadataQuery() string {
+	return `REPLACE INTO metadata_package_version
+						(product, bundle, feed_version, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW())`
+}
+
+func (d *mysqlDialect) QuoteIdentifier(identifier Identifier) string {
+	return "`" + strings.Replace(identifier.String(), "`", "``", -1) + "`"
+}
+
+func (d *mysqlDialect) DropTablesQuery(tables []Identifier) string {
+	return fmt.Sprintf(`DROP TABLES IF EXISTS %s`, quoteIdentifiers(d, tables))
+}
+
+// TranslateDDL - FactSet supply MySQL compatible DDL so it is run as is
+func (d *mysqlDialect) TranslateDDL(statement string) string {
+	return statement
+}
+
+func (d *mysqlDialect) IsTableExistsError(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1050
+}
+
+// mysqlCharsets - the character sets LOAD DATA reads each encoding as. MySQL can not load UTF-16 files,
+// so they are decoded to UTF-8 as they are sent, as are files with a byte order mark.
+var mysqlCharsets = map[string]string{
+	EncodingUTF8:    "utf8mb4",
+	EncodingLatin1:  "latin1",
+	EncodingUTF16LE: "utf8mb4",
+	EncodingUTF16BE: "utf8mb4",
+}
+
+// mysqlReaderCount - makes the names of the reader handlers used to send decoded files unique
+var mysqlReaderCount uint64
+
+func (d *mysqlDialect) setInsertBatchSize(size int) {
+	d.insertBatchSize = size
+}
+
+// LoadTable - bulk loads the data file with LOAD DATA LOCAL INFILE. Many managed servers turn LOCAL INFILE off,
+// so once the server has rejected it the file is written with batched INSERTs instead.
+func (d *mysqlDialect) LoadTable(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error) {
+	if atomic.LoadInt32(&d.localInfileRejected) == 0 {
+		result, err := d.loadDataLocalInfile(ctx, db, filename, format, table)
+		if !isLocalInfileRejected(err) {
+			return result, err
+		}
+		atomic.StoreInt32(&d.localInfileRejected, 1)
+		log.WithError(err).Warn("LOAD DATA LOCAL INFILE is not allowed by the server; loading data files with batched INSERTs instead")
+	}
+	return d.insertTable(ctx, db, filename, format, table)
+}
+
+// isLocalInfileRejected - whether the server refused LOAD DATA LOCAL INFILE, with ER_NOT_ALLOWED_COMMAND before
+// MySQL 8 or ER_CLIENT_LOCAL_FILES_DISABLED from MySQL 8
+func isLocalInfileRejected(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && (mysqlErr.Number == 1148 || mysqlErr.Number == 3948)
+}
+
+// loadDataLocalInfile - the file name is passed as a parameter, which the driver interpolates and escapes client
+// side as the connection string sets interpolateParams; LOAD DATA can not be a server side prepared statement.
+// It runs in a transaction so that SHOW WARNINGS is read from the same connection as the load.
+func (d *mysqlDialect) loadDataLocalInfile(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error) {
+	queryTemplate := `LOAD DATA LOCAL INFILE ? REPLACE INTO TABLE %s CHARACTER SET %s FIELDS TERMINATED BY '%s'
+	OPTIONALLY ENCLOSED BY '"' LINES TERMINATED BY '%s' IGNORE %d LINES;`
+	ignoreLines := 0
+	if format.Header {
+		ignoreLines = 1
+	}
+	query := fmt.Sprintf(queryTemplate, d.QuoteIdentifier(table), mysqlCharsets[format.Encoding],
+		fileDelimiters[format.Delimiter], fileLineEndings[format.LineEnding], ignoreLines)
+
+	source := filename
+	decode, err := needsDecoding(filename, format)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if decode {
+		f, err := OpenDataFile(filename, format)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		defer f.Close()
+		source = fmt.Sprintf("factset-%d", atomic.AddUint64(&mysqlReaderCount, 1))
+		// The driver closes readers that are io.Closers, so hide Close and leave it to the defer above
+		mysql.RegisterReaderHandler(source, func() io.Reader { return struct{ io.Reader }{f} })
+		defer mysql.DeregisterReaderHandler(source)
+		source = "Reader::" + source
+	}
+
+	var result LoadResult
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	res, err := tx.ExecContext(ctx, query, source)
+	if err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if result.Rows, err = res.RowsAffected(); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if err = d.readWarnings(tx, &result); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	if err = tx.Commit(); err != nil {
+		return LoadResult{}, err
+	}
+	return result, nil
+}
+
+// insertTable writes the data file with batched REPLACE statements, which replace rows with the same key as
+// LOAD DATA ... REPLACE does. As with LOAD DATA, only \N is written as NULL and empty fields as empty strings.
+func (d *mysqlDialect) insertTable(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error) {
+	var columnCount int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?`, table.String()).Scan(&columnCount)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if columnCount == 0 {
+		return LoadResult{}, fmt.Errorf("table %s does not exist or has no columns", table)
+	}
+
+	batchSize := d.insertBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultInsertBatchSize
+	}
+	var result LoadResult
+	result.Rows, err = insertDataFile(ctx, db, "REPLACE INTO "+d.QuoteIdentifier(table), columnCount, batchSize, filename, format, escapedNull,
+		func(tx *sql.Tx) error {
+			return d.readWarnings(tx, &result)
+		})
+	if err != nil {
+		return LoadResult{}, err
+	}
+	return result, nil
+}
+
+// readWarnings adds the warnings left by the last statement to the result, keeping the first few messages. The
+// count is read separately as the server only keeps max_error_count of them.
+func (d *mysqlDialect) readWarnings(tx *sql.Tx, result *LoadResult) error {
+	var count int64
+	if err := tx.QueryRow(`SHOW COUNT(*) WARNINGS`).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	result.Warnings += count
+	if len(result.WarningMessages) >= maxWarningMessages {
+		return nil
+	}
+	messages, err := d.warnings(tx, maxWarningMessages-len(result.WarningMessages))
+	if err != nil {
+		return err
+	}
+	result.WarningMessages = append(result.WarningMessages, messages...)
+	return nil
+}
+
+// warnings reads up to limit of the warnings left by the last statement
+func (d *mysqlDialect) warnings(tx *sql.Tx, limit int) ([]string, error) {
+	rows, err := tx.Query(`SHOW WARNINGS LIMIT ` + strconv.Itoa(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var level, message string
+		var code int
+		if err := rows.Scan(&level, &code, &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, fmt.Sprintf("%s %d: %s", level, code, message))
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,191 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgresDialect - PostgreSQL, loading data with COPY FROM STDIN
+type postgresDialect struct{}
+
+// FactSet DDL types that PostgreSQL does not understand and their replacements
+var postgresTypeTranslations = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\bDATETIME\b`), "TIMESTAMP"},
+	{regexp.MustCompile(`(?i)\bDOUBLE\b(\s+PRECISION\b)?`), "DOUBLE PRECISION"},
+	{regexp.MustCompile(`(?i)\bTINYINT\b(\s*\(\d+\))?`), "SMALLINT"},
+	{regexp.MustCompile(`(?i)\b(TINY|MEDIUM|LONG)TEXT\b`), "TEXT"},
+	{regexp.MustCompile(`(?i)\b(TINY|MEDIUM|LONG)?BLOB\b`), "BYTEA"},
+	{regexp.MustCompile(`(?i)\s+UNSIGNED\b`), ""},
+	{regexp.MustCompile(`(?i)\)\s*ENGINE\s*=.*$`), ")"},
+}
+
+// backtickIdentifier - a MySQL quoted name in the FactSet DDL
+var backtickIdentifier = regexp.MustCompile("`[^`]*`")
+
+func (d *postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (d *postgresDialect) DriverName() string {
+	return "postgres"
+}
+
+func (d *postgresDialect) ConnString(dsn string) string {
+	return dsn
+}
+
+func (d *postgresDialect) Schema(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+func (d *postgresDialect) Rebind(query string) string {
+	return rebindNumbered(query, "$")
+}
+
+// MetadataTableStatements - the original metadata tables, which later migrations build on
+func (d *postgresDialect) MetadataTableStatements() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS metadata_package_version (
+			product varchar(255) NOT NULL,
+			bundle varchar(255) NOT NULL,
+			schema_feed_version INT,
+			schema_sequence INT,
+			schema_date_loaded TIMESTAMP,
+			package_feed_version INT,
+			package_sequence INT,
+			package_date_loaded TIMESTAMP,
+			PRIMARY KEY (product)
+		);`,
+		`CREATE TABLE IF NOT EXISTS metadata_table_version (
+			tablename varchar(255) NOT NULL,
+			feed_version INT,
+			sequence INT,
+			date_loaded TIMESTAMP,
+			product varchar(255) NOT NULL,
+			bundle  varchar(255) NOT NULL,
+			PRIMARY KEY (tablename)
+		);`,
+	}
+}
+
+func (d *postgresDialect) UpsertTableMetadataQuery() string {
+	return `INSERT INTO metadata_table_version
+						(tablename, feed_version, sequence, date_loaded, product, bundle)
+						VALUES ($1, $2, $3, NOW(), $4, $5)
+						ON CONFLICT (tablename) DO UPDATE SET
+						feed_version = EXCLUDED.feed_version, sequence = EXCLUDED.sequence, date_loaded = EXCLUDED.date_loaded,
+						product = EXCLUDED.product, bundle = EXCLUDED.bundle`
+}
+
+func (d *postgresDialect) UpsertPackageMetadataQuery() string {
+	return `INSERT INTO metadata_package_version
+						(product, bundle, feed_version, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
+						VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+						ON CONFLICT (product, bundle, feed_version) DO UPDATE SET
+						schema_feed_version = EXCLUDED.schema_feed_version, schema_sequence = EXCLUDED.schema_sequence,
+						schema_date_loaded = EXCLUDED.schema_date_loaded, package_feed_version = EXCLUDED.package_feed_version,
+						package_sequence = EXCLUDED.package_sequence, package_date_loaded = EXCLUDED.package_date_loaded`
+}
+
+// QuoteIdentifier - names are lower cased before quoting to match the unquoted names in the FactSet DDL,
+// which PostgreSQL folds to lower case
+func (d *postgresDialect) QuoteIdentifier(identifier Identifier) string {
+	return pq.QuoteIdentifier(strings.ToLower(identifier.String()))
+}
+
+func (d *postgresDialect) DropTablesQuery(tables []Identifier) string {
+	return fmt.Sprintf(`DROP TABLE IF EXISTS %s`, quoteIdentifiers(d, tables))
+}
+
+// TranslateDDL - rewrites the MySQL flavoured types and syntax of the FactSet DDL. Quoted names are lower cased
+// like QuoteIdentifier, as quoted names are case sensitive in PostgreSQL and are otherwise looked up in lower case.
+func (d *postgresDialect) TranslateDDL(statement string) string {
+	statement = backtickIdentifier.ReplaceAllStringFunc(statement, func(name string) string {
+		return `"` + strings.ToLower(strings.Trim(name, "`")) + `"`
+	})
+	for _, t := range postgresTypeTranslations {
+		statement = t.pattern.ReplaceAllString(statement, t.replacement)
+	}
+	return statement
+}
+
+func (d *postgresDialect) IsTableExistsError(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "42P07"
+}
+
+// LoadTable - streams the data file to the table with COPY FROM STDIN. Columns are matched by position,
+// as with LOAD DATA, so the column order is read from the catalogue rather than the file header.
+func (d *postgresDialect) LoadTable(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error) {
+	columns, err := d.tableColumns(ctx, db, table)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if len(columns) == 0 {
+		return LoadResult{}, fmt.Errorf("table %s does not exist or has no columns", table)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(strings.ToLower(table.String()), columns...))
+	if err != nil {
+		tx.Rollback()
+		return LoadResult{}, err
+	}
+
+	rows, err := readDataFile(filename, format, emptyIsNull, func(row []interface{}) error {
+		_, err := stmt.ExecContext(ctx, fitRow(row, len(columns))...)
+		return err
+	})
+	if err == nil {
+		_, err = stmt.ExecContext(ctx)
+	}
+	if err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return LoadResult{}, err
+	}
+	if err = stmt.Close(); err != nil {
+		tx.Rollback()
+		return LoadResult{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return LoadResult{}, err
+	}
+	return LoadResult{Rows: int64(rows)}, nil
+}
+
+func (d *postgresDialect) tableColumns(ctx context.Context, db *sql.DB, table Identifier) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT column_name FROM information_schema.columns
+						WHERE table_schema = current_schema() AND table_name = $1
+						ORDER BY ordinal_position`, strings.ToLower(table.String()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
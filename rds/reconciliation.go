@@ -0,0 +1,41 @@
+package rds
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxWarningMessages - how many of the warnings raised by a load are kept for logging
+const maxWarningMessages = 10
+
+// LoadResult - what the database reported after loading a data file
+type LoadResult struct {
+	// Rows is the number of rows the database reports as affected by the load
+	Rows int64
+	// Warnings is the number of warnings raised, e.g. for truncated or invalid values
+	Warnings int64
+	// WarningMessages holds the first few warnings raised
+	WarningMessages []string
+}
+
+// TableRowCounts - the rows expected from a data file compared with what was loaded into its table
+type TableRowCounts struct {
+	Expected int64
+	Loaded   int64
+	Warnings int64
+}
+
+// UpdateLoadedTableRowCounts - records the row counts of the last load of the table in its metadata
+func (c *Client) UpdateLoadedTableRowCounts(ctx context.Context, tableName string, counts TableRowCounts) error {
+	if _, err := NewIdentifier(tableName); err != nil {
+		log.WithError(err).Error("Refusing to record row counts for table")
+		return err
+	}
+	queryTemplate := `UPDATE metadata_table_version SET expected_rows = ?, loaded_rows = ?, warning_count = ? WHERE tablename = ?`
+	// Rows affected is not checked as MySQL reports 0 when the counts are the same as the last load
+	if _, err := c.DB.ExecContext(ctx, c.dialect.Rebind(queryTemplate), counts.Expected, counts.Loaded, counts.Warnings, tableName); err != nil {
+		log.WithError(err).Errorf("error running query to update row counts for table: %s", tableName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientLoadTableAndRecordRowCounts(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+	createTestTables()
+
+	dir, err := ioutil.TempDir("", "factset-rds")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "foo_test1.txt")
+	err = ioutil.WriteFile(filename, []byte("ID\r\n0001\r\n0002\r\n0003\r\n"), 0644)
+	assert.NoError(t, err)
+
+	result, err := dbClient.LoadTable(context.Background(), filename, "foo_test1", DefaultFileFormat)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.Rows)
+	assert.Equal(t, int64(0), result.Warnings)
+
+	pkg := factset.Package{Product: "foo", Bundle: "foo"}
+	err = dbClient.UpdateLoadedTableVersion(context.Background(), "foo_test1", factset.PackageVersion{FeedVersion: 1, Sequence: 10}, pkg)
+	assert.NoError(t, err)
+	counts := TableRowCounts{Expected: 3, Loaded: 3}
+	assert.NoError(t, dbClient.UpdateLoadedTableRowCounts(context.Background(), "foo_test1", counts))
+	assert.NoError(t, dbClient.UpdateLoadedTableRowCounts(context.Background(), "foo_test1", counts), "Recording the same counts twice should not fail")
+
+	var expected, loaded, warnings sql.NullInt64
+	err = dbClient.DB.QueryRow(dbClient.dialect.Rebind(`SELECT expected_rows, loaded_rows, warning_count FROM metadata_table_version WHERE tablename = ?`), "foo_test1").
+		Scan(&expected, &loaded, &warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, counts, TableRowCounts{Expected: expected.Int64, Loaded: loaded.Int64, Warnings: warnings.Int64})
+
+	err = dbClient.UpdateLoadedTableRowCounts(context.Background(), "foo_test1; DROP TABLE foo_test2", counts)
+	assert.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/ddl"
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// tableDDL - the statements of the schema that define the table: the CREATE TABLE statement
+// followed by any statements adding indexes or keys to it
+func tableDDL(schema ddl.Schema, tableName string) string {
+	var statements []string
+	for _, statement := range schema.Statements {
+		if statement.Kind != ddl.Other && strings.EqualFold(statement.Table, tableName) {
+			statements = append(statements, statement.Text+";")
+		}
+	}
+	return strings.Join(statements, "\n")
+}
+
+// recordTableSchema - records the definition of a table created from a package schema in metadata_table_schema,
+// so the tables can be compared with those of a later schema
+func (c *Client) recordTableSchema(ctx context.Context, schema ddl.Schema, table ddl.Table, pkg factset.Package) error {
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error starting transaction to record schema of table: %s", table.Name)
+		return err
+	}
+	if _, err = tx.Exec(c.dialect.Rebind(`DELETE FROM metadata_table_schema WHERE tablename = ?`), table.Name); err == nil {
+		_, err = tx.Exec(c.dialect.Rebind(`INSERT INTO metadata_table_schema
+							(tablename, product, bundle, column_count, table_ddl, date_created)
+							VALUES (?, ?, ?, ?, ?, ?)`),
+			table.Name, pkg.Product, pkg.Bundle, len(table.Columns), tableDDL(schema, table.Name), time.Now().UTC())
+	}
+	if err != nil {
+		tx.Rollback()
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error running query to record schema of table: %s", table.Name)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error committing schema of table: %s", table.Name)
+		return err
+	}
+	return nil
+}
+
+// GetTableSchemas - the definitions of the tables last created from the schema of the product and bundle,
+// parsed from the statements recorded when they were created
+func (c *Client) GetTableSchemas(ctx context.Context, product string, bundle string) ([]ddl.Table, error) {
+	rows, err := c.DB.QueryContext(ctx, c.dialect.Rebind(`SELECT tablename, table_ddl FROM metadata_table_schema WHERE product = ? AND bundle = ? ORDER BY tablename`), product, bundle)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error querying table schemas for product: %s, bundle: %s", product, bundle)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []ddl.Table
+	for rows.Next() {
+		var tableName, statements string
+		if err := rows.Scan(&tableName, &statements); err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Error("Error scanning table schemas")
+			return nil, err
+		}
+		schema, err := ddl.Parse(statements)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": product}).Errorf("Error parsing recorded schema of table: %s", tableName)
+			return nil, err
+		}
+		table, ok := schema.Table(tableName)
+		if !ok {
+			log.WithFields(log.Fields{"fs_product": product}).Errorf("Recorded schema of table %s does not create it", tableName)
+			return nil, fmt.Errorf("recorded schema of table %s does not create it", tableName)
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// ApplySchemaChanges - migrates the tables of the package in place to the new schema, creating added tables,
+// appending added columns and creating added indexes. Destructive changes are refused, as the tables must
+// be dropped and recreated from the schema to make them.
+func (c *Client) ApplySchemaChanges(ctx context.Context, changes ddl.SchemaDiff, schema ddl.Schema, pkg factset.Package) error {
+	if changes.IsDestructive() {
+		err := fmt.Errorf("schema changes can not be made in place: %s", changes)
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Error("Refusing to migrate schema")
+		return err
+	}
+
+	if len(changes.AddedTables) > 0 {
+		if err := c.createTables(ctx, schemaOf(schema, changes.AddedTables), pkg); err != nil {
+			return err
+		}
+	}
+
+	for _, tableChanges := range changes.ChangedTables {
+		table, err := NewIdentifier(tableChanges.Table.Name)
+		if err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Error("Refusing to migrate table")
+			return err
+		}
+		var statements []string
+		for _, column := range tableChanges.AddedColumns {
+			statement, err := c.addColumnStatement(table, column)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Refusing to add column to table: %s", table)
+				return err
+			}
+			statements = append(statements, statement)
+		}
+		for _, index := range tableChanges.AddedIndexes {
+			statement, err := c.createIndexStatement(table, index)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Refusing to add index to table: %s", table)
+				return err
+			}
+			statements = append(statements, statement)
+		}
+		for _, statement := range statements {
+			if _, err := c.DB.ExecContext(ctx, c.dialect.TranslateDDL(statement)); err != nil {
+				log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error running query to migrate table: %s", table)
+				return err
+			}
+		}
+		if err := c.recordTableSchema(ctx, schema, tableChanges.Table, pkg); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Migrated table %s: %s", table, strings.Join(tableChanges.Changes(), ", "))
+	}
+	return nil
+}
+
+// schemaOf - the part of the schema that creates the tables, and adds their indexes
+func schemaOf(schema ddl.Schema, tables []ddl.Table) ddl.Schema {
+	var part ddl.Schema
+	for _, table := range tables {
+		part.Tables = append(part.Tables, table)
+		for _, statement := range schema.Statements {
+			if statement.Kind != ddl.Other && strings.EqualFold(statement.Table, table.Name) {
+				part.Statements = append(part.Statements, statement)
+			}
+		}
+	}
+	return part
+}
+
+func (c *Client) addColumnStatement(table Identifier, column ddl.Column) (string, error) {
+	name, err := NewIdentifier(column.Name)
+	if err != nil {
+		return "", err
+	}
+	statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.dialect.QuoteIdentifier(table), c.dialect.QuoteIdentifier(name), column.Type)
+	if !column.Nullable {
+		statement += " NOT NULL"
+	}
+	if column.Default != "" {
+		statement += " DEFAULT " + column.Default
+	}
+	return statement, nil
+}
+
+// createIndexStatement - indexes declared without a name are named after the table and their columns
+func (c *Client) createIndexStatement(table Identifier, index ddl.Index) (string, error) {
+	indexName := index.Name
+	if indexName == "" {
+		indexName = table.String() + "_" + strings.Join(index.Columns, "_")
+	}
+	name, err := NewIdentifier(indexName)
+	if err != nil {
+		return "", err
+	}
+	columns, err := newIdentifiers(index.Columns)
+	if err != nil {
+		return "", err
+	}
+	create := "CREATE INDEX"
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX"
+	}
+	return fmt.Sprintf("%s %s ON %s (%s)", create, c.dialect.QuoteIdentifier(name), c.dialect.QuoteIdentifier(table), quoteIdentifiers(c.dialect, columns)), nil
+}
@@ -0,0 +1,144 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/ddl"
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSchema = `-- FactSet test schema; generated
+CREATE TABLE IF NOT EXISTS ` + "`foo_test1`" + ` (
+	ID VARCHAR(10) NOT NULL, -- the id; primary
+	NAME VARCHAR(100) DEFAULT 'n/a;',
+	PRIMARY KEY (ID)
+);
+/* indexes follow */
+CREATE INDEX foo_test1_name ON foo_test1 (NAME);
+CREATE    TABLE
+	foo_test2 (ID VARCHAR(10) NOT NULL);`
+
+func TestClientCreateTablesFromSchema(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	foo := factset.Package{Product: "foo", Bundle: "foo"}
+	err = dbClient.CreateTablesFromSchema(context.Background(), []byte(testSchema), foo)
+	assert.NoError(t, err)
+
+	_, err = dbClient.DB.Exec(`INSERT INTO foo_test1 (ID) VALUES ('a')`)
+	assert.NoError(t, err)
+	var name string
+	assert.NoError(t, dbClient.DB.QueryRow(`SELECT NAME FROM foo_test1`).Scan(&name))
+	assert.Equal(t, "n/a;", name)
+	for _, table := range []string{"foo_test1", "foo_test2"} {
+		version, err := getLoadedVersion(table)
+		assert.NoError(t, err)
+		assert.Equal(t, factset.PackageVersion{}, version)
+	}
+
+	tables, err := dbClient.GetTableSchemas(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	if assert.Len(t, tables, 2) {
+		assert.Equal(t, "foo_test1", tables[0].Name)
+		assert.Equal(t, []string{"ID", "NAME"}, tables[0].ColumnNames())
+		assert.Equal(t, []string{"ID"}, tables[0].PrimaryKey)
+		if assert.Len(t, tables[0].Indexes, 1) {
+			assert.Equal(t, "foo_test1_name", tables[0].Indexes[0].Name)
+		}
+		assert.Equal(t, "foo_test2", tables[1].Name)
+		assert.Equal(t, []string{"ID"}, tables[1].ColumnNames())
+	}
+
+	// a package sharing a table leaves it, and its indexes, to the package that created it
+	bob := factset.Package{Product: "bob", Bundle: "bob"}
+	err = dbClient.CreateTablesFromSchema(context.Background(), []byte("CREATE TABLE foo_test2 (ID VARCHAR(10) NOT NULL);\nCREATE INDEX foo_test2_id ON foo_test2 (ID);"), bob)
+	assert.NoError(t, err)
+	tables, err = dbClient.GetTableSchemas(context.Background(), "bob", "bob")
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+
+	err = dbClient.DropTablesWithProductAndBundle(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	tables, err = dbClient.GetTableSchemas(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+}
+
+func TestClientCreateTablesFromSchemaRejectsInvalidScripts(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+	createTestTables()
+
+	testCases := []struct {
+		name   string
+		schema string
+	}{
+		{"UnclosedString", "CREATE TABLE foo_test4 (ID VARCHAR(10) DEFAULT 'a);"},
+		{"InvalidIndexTable", "CREATE INDEX foo_test2_id ON `foo_test2; DROP TABLE foo_test2` (ID);"},
+		{"DuplicateTable", "CREATE TABLE foo_test4 (ID INT);\nCREATE TABLE foo_test4 (ID INT);"},
+	}
+	for _, d := range testCases {
+		err := dbClient.CreateTablesFromSchema(context.Background(), []byte(d.schema), factset.Package{Product: "foo", Bundle: "foo"})
+		assert.Error(t, err, "Test %s failed, expected an error", d.name)
+	}
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test4`)
+	assert.Error(t, err, "foo_test4 should not have been created")
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test2`)
+	assert.NoError(t, err, "foo_test2 should not have been dropped")
+}
+
+func TestClientApplySchemaChanges(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	foo := factset.Package{Product: "foo", Bundle: "foo"}
+	assert.NoError(t, dbClient.CreateTablesFromSchema(context.Background(), []byte(testSchema), foo))
+	_, err = dbClient.DB.Exec(`INSERT INTO foo_test1 (ID, NAME) VALUES ('a', 'b')`)
+	assert.NoError(t, err)
+
+	newSchema, err := ddl.Parse(testSchema + `
+ALTER TABLE foo_test1 ADD COLUMN CODE CHAR(2), ADD COLUMN RANK INT NOT NULL DEFAULT 1, ADD UNIQUE (CODE);
+CREATE TABLE foo_test3 (ID VARCHAR(10) NOT NULL);
+CREATE INDEX foo_test3_id ON foo_test3 (ID);`)
+	assert.NoError(t, err)
+	current, err := dbClient.GetTableSchemas(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	changes := ddl.Diff(current, newSchema.Tables)
+	assert.False(t, changes.IsDestructive())
+
+	err = dbClient.ApplySchemaChanges(context.Background(), changes, newSchema, foo)
+	assert.NoError(t, err)
+
+	var id, name string
+	var code sql.NullString
+	var rank int
+	err = dbClient.DB.QueryRow(`SELECT ID, NAME, CODE, RANK FROM foo_test1`).Scan(&id, &name, &code, &rank)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", id, "existing rows should be kept")
+	assert.False(t, code.Valid)
+	assert.Equal(t, 1, rank)
+	_, err = dbClient.DB.Exec(`INSERT INTO foo_test3 (ID) VALUES ('a')`)
+	assert.NoError(t, err, "foo_test3 should have been created")
+	version, err := getLoadedVersion("foo_test3")
+	assert.NoError(t, err)
+	assert.Equal(t, factset.PackageVersion{}, version)
+
+	tables, err := dbClient.GetTableSchemas(context.Background(), "foo", "foo")
+	assert.NoError(t, err)
+	assert.Empty(t, ddl.Diff(tables, newSchema.Tables).Changes(), "recorded definitions should match the new schema")
+
+	removed, err := ddl.Parse(`CREATE TABLE foo_test1 (ID VARCHAR(10) NOT NULL, PRIMARY KEY (ID));`)
+	assert.NoError(t, err)
+	err = dbClient.ApplySchemaChanges(context.Background(), ddl.Diff(tables, removed.Tables), removed, foo)
+	assert.Error(t, err, "destructive changes should be refused")
+}
@@ -0,0 +1,145 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect - an embedded SQLite database file, for local development and hermetic tests
+type sqliteDialect struct{}
+
+const sqlitePrefix = "sqlite://"
+
+var sqliteTableOptions = regexp.MustCompile(`(?i)\)\s*ENGINE\s*=.*$`)
+
+func (d *sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (d *sqliteDialect) DriverName() string {
+	return "sqlite3"
+}
+
+// ConnString - the path to the database file, which is created if it does not exist
+func (d *sqliteDialect) ConnString(dsn string) string {
+	return "file:" + strings.TrimPrefix(dsn, sqlitePrefix) + "?_busy_timeout=5000"
+}
+
+func (d *sqliteDialect) Schema(dsn string) string {
+	name := filepath.Base(strings.TrimPrefix(dsn, sqlitePrefix))
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func (d *sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+// MetadataTableStatements - the original metadata tables, which later migrations build on
+func (d *sqliteDialect) MetadataTableStatements() []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS metadata_package_version (
+			product varchar(255) NOT NULL,
+			bundle varchar(255) NOT NULL,
+			schema_feed_version INT,
+			schema_sequence INT,
+			schema_date_loaded DATETIME,
+			package_feed_version INT,
+			package_sequence INT,
+			package_date_loaded DATETIME,
+			PRIMARY KEY (product)
+		);`,
+		`CREATE TABLE IF NOT EXISTS metadata_table_version (
+			tablename varchar(255) NOT NULL,
+			feed_version INT,
+			sequence INT,
+			date_loaded DATETIME,
+			product varchar(255) NOT NULL,
+			bundle  varchar(255) NOT NULL,
+			PRIMARY KEY (tablename)
+		);`,
+	}
+}
+
+func (d *sqliteDialect) UpsertTableMetadataQuery() string {
+	return `INSERT OR REPLACE INTO metadata_table_version
+						(tablename, feed_version, sequence, date_loaded, product, bundle)
+						VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?, ?)`
+}
+
+func (d *sqliteDialect) UpsertPackageMetadataQuery() string {
+	return `INSERT OR REPLACE INTO metadata_package_version
+						(product, bundle, feed_version, schema_feed_version, schema_sequence, schema_date_loaded, package_feed_version, package_sequence, package_date_loaded)
+						VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+}
+
+func (d *sqliteDialect) QuoteIdentifier(identifier Identifier) string {
+	return `"` + strings.Replace(identifier.String(), `"`, `""`, -1) + `"`
+}
+
+// DropTablesQuery - SQLite can only drop one table per statement, so this is a statement per table
+func (d *sqliteDialect) DropTablesQuery(tables []Identifier) string {
+	var statements []string
+	for _, table := range tables {
+		statements = append(statements, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, d.QuoteIdentifier(table)))
+	}
+	return strings.Join(statements, "; ")
+}
+
+// TranslateDDL - SQLite accepts the FactSet column types as they are, only the MySQL table options are removed
+func (d *sqliteDialect) TranslateDDL(statement string) string {
+	return sqliteTableOptions.ReplaceAllString(statement, ")")
+}
+
+func (d *sqliteDialect) IsTableExistsError(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.Code == sqlite3.ErrError && strings.HasSuffix(sqliteErr.Error(), "already exists")
+}
+
+// LoadTable - parses the data file and inserts the rows in a single transaction, replacing rows with
+// the same key as LOAD DATA ... REPLACE does
+func (d *sqliteDialect) LoadTable(ctx context.Context, db *sql.DB, filename string, format FileFormat, table Identifier) (LoadResult, error) {
+	columnCount, err := d.columnCount(ctx, db, table)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if columnCount == 0 {
+		return LoadResult{}, fmt.Errorf("table %s does not exist or has no columns", table)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", columnCount), ", ")
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT OR REPLACE INTO %s VALUES (%s)`, d.QuoteIdentifier(table), placeholders))
+	if err != nil {
+		tx.Rollback()
+		return LoadResult{}, err
+	}
+	defer stmt.Close()
+
+	rows, err := readDataFile(filename, format, emptyIsNull, func(row []interface{}) error {
+		_, err := stmt.ExecContext(ctx, fitRow(row, columnCount)...)
+		return err
+	})
+	if err != nil {
+		tx.Rollback()
+		return LoadResult{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return LoadResult{}, err
+	}
+	return LoadResult{Rows: int64(rows)}, nil
+}
+
+func (d *sqliteDialect) columnCount(ctx context.Context, db *sql.DB, table Identifier) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM pragma_table_info(?)`, table.String()).Scan(&count)
+	return count, err
+}
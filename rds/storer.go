@@ -0,0 +1,28 @@
+package rds
+
+import (
+	"context"
+	"time"
+
+	"github.com/Financial-Times/factset-uploader/ddl"
+	"github.com/Financial-Times/factset-uploader/factset"
+)
+
+// Storer - storage interface used by the loader, to be able to mock for testing. Statements stop when their context is done.
+type Storer interface {
+	GetPackageMetadata(ctx context.Context, pkg factset.Package) (factset.PackageMetadata, error)
+	UpdateLoadedPackageVersion(ctx context.Context, packageMetadata *factset.PackageMetadata) error
+	UpdateLoadedTableVersion(ctx context.Context, tableName string, version factset.PackageVersion, pkg factset.Package) error
+	CreateTablesFromSchema(ctx context.Context, contents []byte, pkg factset.Package) error
+	GetTableSchemas(ctx context.Context, product string, bundle string) ([]ddl.Table, error)
+	ApplySchemaChanges(ctx context.Context, changes ddl.SchemaDiff, schema ddl.Schema, pkg factset.Package) error
+	DropDataFromTable(ctx context.Context, tableName string, product string) error
+	LoadTable(ctx context.Context, filename, table string, format FileFormat) (LoadResult, error)
+	UpdateLoadedTableRowCounts(ctx context.Context, tableName string, counts TableRowCounts) error
+	DropTablesWithProductAndBundle(ctx context.Context, product string, bundle string) error
+	ResetPackage(ctx context.Context, pkg factset.Package, dropTables bool) error
+	RecordLoadHistory(ctx context.Context, entry LoadHistory) error
+	AcquireLease(ctx context.Context, name string, wait time.Duration) (Lease, error)
+}
+
+var _ Storer = (*Client)(nil)
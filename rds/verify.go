@@ -0,0 +1,110 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	log "github.com/sirupsen/logrus"
+)
+
+// TableCheck - the state of a table recorded in metadata_table_version, compared with the table itself
+type TableCheck struct {
+	Table   string
+	Version factset.PackageVersion
+	Exists  bool
+	// Rows is the number of rows now in the table
+	Rows int64
+	// LoadedRows is the number of rows recorded by the last load, or -1 if none were recorded
+	LoadedRows int64
+	// Problems describes what looks wrong with the table; it is empty if the table looks sane
+	Problems []string
+}
+
+// VerifyPackage - checks that each table recorded for the package exists, holds rows, holds the rows recorded by its
+// last load and was loaded from the version of the package recorded in metadata_package_version
+func (c *Client) VerifyPackage(ctx context.Context, pkg factset.Package) ([]TableCheck, error) {
+	pkgMetadata, err := c.GetPackageMetadata(ctx, pkg)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	loaded := err == nil
+
+	rows, err := c.DB.QueryContext(ctx, c.dialect.Rebind(`SELECT tablename, feed_version, sequence, loaded_rows FROM metadata_table_version WHERE product = ? AND bundle = ? ORDER BY tablename`), pkg.Product, pkg.Bundle)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error querying table metadata for product: %s", pkg.Product)
+		return nil, err
+	}
+	var checks []TableCheck
+	for rows.Next() {
+		var check TableCheck
+		var feedVersion, sequence, loadedRows sql.NullInt64
+		if err := rows.Scan(&check.Table, &feedVersion, &sequence, &loadedRows); err != nil {
+			rows.Close()
+			log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Error("Error scanning table metadata")
+			return nil, err
+		}
+		check.Version = factset.PackageVersion{FeedVersion: int(feedVersion.Int64), Sequence: int(sequence.Int64)}
+		check.LoadedRows = -1
+		if loadedRows.Valid {
+			check.LoadedRows = loadedRows.Int64
+		}
+		checks = append(checks, check)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range checks {
+		check := &checks[i]
+		table, err := NewIdentifier(check.Table)
+		if err != nil {
+			check.Problems = append(check.Problems, err.Error())
+			continue
+		}
+		// a failed count is taken to mean the table does not exist
+		if err := c.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s`, c.dialect.QuoteIdentifier(table))).Scan(&check.Rows); err != nil {
+			check.Problems = append(check.Problems, "table does not exist")
+			continue
+		}
+		check.Exists = true
+		if check.Version.Sequence == 0 {
+			check.Problems = append(check.Problems, "table has not been loaded")
+			continue
+		}
+		if check.Rows == 0 {
+			check.Problems = append(check.Problems, "table is empty")
+		}
+		if check.LoadedRows >= 0 && check.Rows != check.LoadedRows {
+			check.Problems = append(check.Problems, fmt.Sprintf("table has %d rows but %d were loaded", check.Rows, check.LoadedRows))
+		}
+		if loaded && check.Version != pkgMetadata.PackageVersion {
+			check.Problems = append(check.Problems, fmt.Sprintf("table was loaded from v%d_%d but the package is at v%d_%d",
+				check.Version.FeedVersion, check.Version.Sequence, pkgMetadata.PackageVersion.FeedVersion, pkgMetadata.PackageVersion.Sequence))
+		}
+	}
+	return checks, nil
+}
+
+// ResetPackage - removes the package's metadata so that the next load reloads its data. When dropTables is set its
+// tables are also dropped and their metadata removed, so they are recreated from the schema.
+func (c *Client) ResetPackage(ctx context.Context, pkg factset.Package, dropTables bool) error {
+	if dropTables {
+		if err := c.DropTablesWithProductAndBundle(ctx, pkg.Product, pkg.Bundle); err != nil {
+			return err
+		}
+		if _, err := c.DB.ExecContext(ctx, c.dialect.Rebind(`DELETE FROM metadata_table_version WHERE product = ? AND bundle = ?`), pkg.Product, pkg.Bundle); err != nil {
+			log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error removing table metadata for product: %s", pkg.Product)
+			return err
+		}
+	}
+	_, err := c.DB.ExecContext(ctx, c.dialect.Rebind(`DELETE FROM metadata_package_version WHERE product = ? AND bundle = ? AND feed_version = ?`), pkg.Product, pkg.Bundle, pkg.FeedVersion)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{"fs_product": pkg.Product}).Errorf("Error removing package metadata for product: %s", pkg.Product)
+		return err
+	}
+	log.WithFields(log.Fields{"fs_product": pkg.Product}).Infof("Reset metadata for product %s, bundle %s", pkg.Product, pkg.Bundle)
+	return nil
+}
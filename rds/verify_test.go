@@ -0,0 +1,80 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Financial-Times/factset-uploader/factset"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientVerifyPackage(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	foo := factset.Package{Product: "foo", Bundle: "foo", FeedVersion: 1}
+	loaded := factset.PackageVersion{FeedVersion: 1, Sequence: 2}
+	assert.NoError(t, dbClient.CreateTablesFromSchema(context.Background(), []byte(`CREATE TABLE foo_test1 (ID VARCHAR(10) NOT NULL);
+CREATE TABLE foo_test2 (ID VARCHAR(10) NOT NULL);
+CREATE TABLE foo_test3 (ID VARCHAR(10) NOT NULL);`), foo))
+	assert.NoError(t, dbClient.UpdateLoadedPackageVersion(context.Background(), &factset.PackageMetadata{Package: foo, SchemaVersion: loaded, PackageVersion: loaded}))
+
+	// foo_test1 is sane, foo_test2 lost rows and is a version behind and foo_test3 has gone
+	_, err = dbClient.DB.Exec(`INSERT INTO foo_test1 (ID) VALUES ('a'), ('b')`)
+	assert.NoError(t, err)
+	assert.NoError(t, dbClient.UpdateLoadedTableVersion(context.Background(), "foo_test1", loaded, foo))
+	assert.NoError(t, dbClient.UpdateLoadedTableRowCounts(context.Background(), "foo_test1", TableRowCounts{Expected: 2, Loaded: 2}))
+	_, err = dbClient.DB.Exec(`INSERT INTO foo_test2 (ID) VALUES ('a')`)
+	assert.NoError(t, err)
+	assert.NoError(t, dbClient.UpdateLoadedTableVersion(context.Background(), "foo_test2", factset.PackageVersion{FeedVersion: 1, Sequence: 1}, foo))
+	assert.NoError(t, dbClient.UpdateLoadedTableRowCounts(context.Background(), "foo_test2", TableRowCounts{Expected: 2, Loaded: 2}))
+	_, err = dbClient.DB.Exec(`DROP TABLE foo_test3`)
+	assert.NoError(t, err)
+
+	checks, err := dbClient.VerifyPackage(context.Background(), foo)
+	assert.NoError(t, err)
+	if assert.Len(t, checks, 3) {
+		assert.Equal(t, "foo_test1", checks[0].Table)
+		assert.True(t, checks[0].Exists)
+		assert.Equal(t, int64(2), checks[0].Rows)
+		assert.Empty(t, checks[0].Problems)
+
+		assert.Equal(t, "foo_test2", checks[1].Table)
+		assert.Equal(t, []string{"table has 1 rows but 2 were loaded", "table was loaded from v1_1 but the package is at v1_2"}, checks[1].Problems)
+
+		assert.Equal(t, "foo_test3", checks[2].Table)
+		assert.False(t, checks[2].Exists)
+		assert.Equal(t, []string{"table does not exist"}, checks[2].Problems)
+	}
+}
+
+func TestClientResetPackage(t *testing.T) {
+	defer dropTestTables()
+	defer removeMetadataTables()
+	err := dbClient.Migrate()
+	assert.NoError(t, err)
+
+	foo := factset.Package{Product: "foo", Bundle: "foo", FeedVersion: 1}
+	loaded := factset.PackageVersion{FeedVersion: 1, Sequence: 2}
+	assert.NoError(t, dbClient.CreateTablesFromSchema(context.Background(), []byte(`CREATE TABLE foo_test1 (ID VARCHAR(10) NOT NULL);`), foo))
+	assert.NoError(t, dbClient.UpdateLoadedPackageVersion(context.Background(), &factset.PackageMetadata{Package: foo, SchemaVersion: loaded, PackageVersion: loaded}))
+
+	assert.NoError(t, dbClient.ResetPackage(context.Background(), foo, false))
+	_, err = dbClient.GetPackageMetadata(context.Background(), foo)
+	assert.Equal(t, sql.ErrNoRows, err, "package metadata should have been removed")
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test1`)
+	assert.NoError(t, err, "tables should be kept")
+
+	assert.NoError(t, dbClient.UpdateLoadedPackageVersion(context.Background(), &factset.PackageMetadata{Package: foo, SchemaVersion: loaded, PackageVersion: loaded}))
+	assert.NoError(t, dbClient.ResetPackage(context.Background(), foo, true))
+	_, err = dbClient.GetPackageMetadata(context.Background(), foo)
+	assert.Equal(t, sql.ErrNoRows, err, "package metadata should have been removed")
+	_, err = dbClient.DB.Exec(`SELECT * FROM foo_test1`)
+	assert.Error(t, err, "tables should have been dropped")
+	checks, err := dbClient.VerifyPackage(context.Background(), foo)
+	assert.NoError(t, err)
+	assert.Empty(t, checks, "table metadata should have been removed")
+}